@@ -13,7 +13,11 @@ func ProcessMenu() {
 
 		switch choice {
 		case 1:
-			viewProcess()
+			if ui.ProcessViewMenu() == 2 {
+				viewProcessTree()
+			} else {
+				viewProcess()
+			}
 
 		case 2:
 			action, pid, name := ui.CompletionMenu()
@@ -26,6 +30,12 @@ func ProcessMenu() {
 				filteredProcess(pid, "")
 			case 4:
 				filteredProcess(0, name)
+			case 5:
+				signalProcess(pid, name)
+			case 6:
+				gracefulStopProcess(pid)
+			case 7:
+				killProcessGroup(pid)
 			case 0:
 
 			default:
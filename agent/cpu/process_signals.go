@@ -0,0 +1,101 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+	"github.com/RZhurakovskiy/agent/utils"
+)
+
+/* Отправляет процессу сигнал по названию и печатает результат */
+func signalProcess(pid int32, signalName string) {
+	if pid == 0 || signalName == "" {
+		return
+	}
+	signal, err := services.SendProcessSignal(pid, signalName)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		return
+	}
+	fmt.Printf("Процессу с PID %d отправлен сигнал %s.\n", pid, signal)
+}
+
+/* Спрашивает время ожидания и мягко завершает процесс: SIGTERM, ожидание, затем SIGKILL */
+func gracefulStopProcess(pid int32) {
+	if pid == 0 {
+		return
+	}
+	var graceSec int
+	fmt.Print("Сколько секунд ждать завершения после SIGTERM (0 - по умолчанию 10): ")
+	if _, err := fmt.Scan(&graceSec); err != nil {
+		fmt.Println("Ошибка! Введите число секунд.")
+		utils.ClearScanBuffer()
+		return
+	}
+
+	fmt.Printf("Отправка SIGTERM процессу %d...\n", pid)
+	result, err := services.GracefulStopProcess(pid, graceSec)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		return
+	}
+	fmt.Printf("%s (%.1f с).\n", result.Message, result.ElapsedSec)
+}
+
+/* Показывает процессы дерева, группы или сессии, которые будут завершены, и после подтверждения завершает их */
+func killProcessGroup(pid int32) {
+	if pid == 0 {
+		return
+	}
+	var choice int
+	fmt.Print("Что завершить: 1 - процесс и потомков, 2 - группу процессов, 3 - сессию: ")
+	if _, err := fmt.Scan(&choice); err != nil || choice < 1 || choice > 3 {
+		fmt.Println("Ошибка! Введите 1, 2 или 3.")
+		utils.ClearScanBuffer()
+		return
+	}
+	scope := []string{"tree", "pgroup", "session"}[choice-1]
+
+	plan, err := services.KillProcessGroup(pid, scope, "", true)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		return
+	}
+	printKillPlan(plan)
+	if len(plan.Targets) == 0 {
+		return
+	}
+
+	var answer string
+	fmt.Printf("Отправить %s %d процессам? (y/n): ", plan.Signal, len(plan.Targets))
+	fmt.Scan(&answer)
+	if answer != "y" && answer != "Y" {
+		fmt.Println("Отменено.")
+		return
+	}
+
+	plan, err = services.KillProcessGroup(pid, scope, "", false)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		return
+	}
+	for _, target := range plan.Targets {
+		if target.Error != "" {
+			fmt.Printf("PID %d (%s): %s\n", target.PID, target.Name, target.Error)
+		}
+	}
+	fmt.Printf("Обработано процессов: %d, ошибок: %d.\n", len(plan.Targets), plan.Failed)
+}
+
+/* Печатает процессы плана завершения и пропущенные защищенные процессы */
+func printKillPlan(plan *services.ProcessKillPlan) {
+	fmt.Printf("\n%-10s %-10s %-20s %-15s\n", "PID", "PPID", "Название", "Пользователь")
+	fmt.Printf("%-10s %-10s %-20s %-15s\n", "----------", "----------", "--------------------", "---------------")
+	for _, target := range plan.Targets {
+		fmt.Printf("%-10d %-10d %-20s %-15s\n", target.PID, target.ParentPID, target.Name, target.Username)
+	}
+	for _, skipped := range plan.Skipped {
+		fmt.Printf("Пропущен PID %d (%s): %s\n", skipped.PID, skipped.Name, skipped.Reason)
+	}
+	fmt.Printf("Будет завершено процессов: %d\n", len(plan.Targets))
+}
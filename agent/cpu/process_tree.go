@@ -0,0 +1,47 @@
+package cpu
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Печатает дерево процессов как pstree: у каждого процесса CPU и RSS всего его поддерева */
+func viewProcessTree() {
+	fmt.Println("\n-------------------------------------------------------")
+	fmt.Println("Сканирование процессов... (измерение займёт ~1 секунду)")
+	fmt.Println("-------------------------------------------------------")
+	procs, err := getmetrics.UsageProcess(nil)
+	if err != nil {
+		log.Println("Не удалось получить список процессов:", err)
+		return
+	}
+
+	roots := services.BuildProcessTree(procs)
+	for _, root := range roots {
+		printProcessTreeNode(root, "", "")
+	}
+	fmt.Println("---------------------------------")
+	fmt.Printf("Найдено процессов: %d\n", len(procs))
+	fmt.Println("---------------------------------")
+}
+
+/* Печатает узел дерева и его потомков, prefix - отступ строки узла, childPrefix - отступ строк потомков */
+func printProcessTreeNode(node *services.ProcessTreeNode, prefix, childPrefix string) {
+	line := fmt.Sprintf("%s%s(%d)", prefix, node.Name, node.PID)
+	if node.DescendantCount > 0 {
+		fmt.Printf("%-50s CPU %.1f%% (всего %.1f%%) RSS %s (всего %s)\n", line, node.CPUPercent, node.SubtreeCPU, formatBytes(node.MemoryRSS), formatBytes(node.SubtreeRSS))
+	} else {
+		fmt.Printf("%-50s CPU %.1f%% RSS %s\n", line, node.CPUPercent, formatBytes(node.MemoryRSS))
+	}
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			printProcessTreeNode(child, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			printProcessTreeNode(child, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
@@ -1,54 +1,70 @@
 package cpu
 
 import (
-	"database/sql"
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/RZhurakovskiy/agent/server/api"
 	"github.com/RZhurakovskiy/agent/server/services"
 	"github.com/RZhurakovskiy/agent/server/ws"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/RZhurakovskiy/agent/utils"
 )
 
 func StartRecordingMenu() {
 	fmt.Println("\n=== Запись метрик процессов ===")
-	fmt.Println("Эта функция записывает процессы, превышающие пороги CPU и RAM")
-	fmt.Println("в базу данных для последующего анализа.")
+	fmt.Println("Эта функция записывает процессы, подходящие под условия по CPU, RAM, RSS,")
+	fmt.Println("имени и пользователю, в базу данных для последующего анализа.")
 	fmt.Println()
 
-	var cpuThreshold float64
-	var ramThreshold float64
-	var duration int
+	var criteria services.RecordingCriteria
 
-	fmt.Print("Введите порог загрузки CPU (%): ")
-	_, err := fmt.Scan(&cpuThreshold)
-	if err != nil {
-		fmt.Println("Ошибка! Используйте число (например, 70.5 или 70).")
+	fmt.Print("Записывать процесс, если выполнено любое из условий (1) или все условия (2): ")
+	mode, err := scanRecordingInt()
+	if err != nil || (mode != 1 && mode != 2) {
+		fmt.Println("Ошибка! Введите 1 или 2.")
 		return
 	}
+	criteria.Mode = "any"
+	if mode == 2 {
+		criteria.Mode = "all"
+	}
 
-	if cpuThreshold <= 0 || cpuThreshold > 100 {
-		fmt.Println("Порог CPU должен быть от 0 до 100%")
+	cpuThreshold, ok := scanRecordingThreshold("Введите порог загрузки CPU (%), 0 - не проверять: ", 100)
+	if !ok {
 		return
 	}
+	criteria.CPUPercent = cpuThreshold
 
-	fmt.Print("Введите порог использования RAM (%): ")
-	_, err = fmt.Scan(&ramThreshold)
-	if err != nil {
-		fmt.Println("Ошибка! Используйте число (например, 80.5 или 80).")
+	ramThreshold, ok := scanRecordingThreshold("Введите порог использования RAM (%), 0 - не проверять: ", 100)
+	if !ok {
+		return
+	}
+	criteria.MemoryPercent = ramThreshold
+
+	rssThreshold, ok := scanRecordingThreshold("Введите порог RSS (МБ), 0 - не проверять: ", 0)
+	if !ok {
 		return
 	}
+	if rssThreshold != nil {
+		rssMB := uint64(*rssThreshold)
+		criteria.RSSMB = &rssMB
+	}
+
+	criteria.Names = scanRecordingList("Шаблоны имени процессов через запятую (например, java*,postgres), - для всех: ")
+	criteria.Users = scanRecordingList("Пользователи через запятую, - для всех: ")
 
-	if ramThreshold <= 0 || ramThreshold > 100 {
-		fmt.Println("Порог RAM должен быть от 0 до 100%")
+	if err := criteria.Validate(); err != nil {
+		fmt.Printf("Некорректные условия записи: %v\n", err)
 		return
 	}
 
 	fmt.Print("Введите продолжительность записи в секундах (минимум 60): ")
-	_, err = fmt.Scan(&duration)
+	duration, err := scanRecordingInt()
 	if err != nil {
 		fmt.Println("Ошибка! Используйте число.")
 		return
@@ -60,9 +76,9 @@ func StartRecordingMenu() {
 	}
 
 	fmt.Println("\nИнициализация базы данных...")
-	sqlDB, err := sql.Open("sqlite3", "./monitor.db")
+	sqlDB, err := api.InitDB("./monitor.db")
 	if err != nil {
-		fmt.Printf("Ошибка открытия базы данных: %v\n", err)
+		fmt.Printf("Ошибка инициализации базы данных: %v\n", err)
 		return
 	}
 	defer sqlDB.Close()
@@ -73,7 +89,7 @@ func StartRecordingMenu() {
 	ws.SetMonitoringEnabled(true)
 
 	fmt.Println("Запуск записи метрик...")
-	sessionID, err := services.StartRecording(cpuThreshold, ramThreshold, duration)
+	sessionID, err := services.StartRecording(criteria, duration)
 	if err != nil {
 		fmt.Printf("Ошибка запуска записи: %v\n", err)
 		ws.SetMonitoringEnabled(false)
@@ -82,8 +98,7 @@ func StartRecordingMenu() {
 
 	fmt.Printf("\n✓ Запись метрик запущена!\n")
 	fmt.Printf("  ID сессии: %d\n", sessionID)
-	fmt.Printf("  Порог CPU: %.2f%%\n", cpuThreshold)
-	fmt.Printf("  Порог RAM: %.2f%%\n", ramThreshold)
+	fmt.Printf("  Условия: %s\n", criteria)
 	fmt.Printf("  Продолжительность: %d секунд\n", duration)
 	fmt.Printf("  Завершится в: %s\n", time.Now().Add(time.Duration(duration)*time.Second).Format("2006-01-02 15:04:05"))
 	fmt.Println("\nЗапись будет автоматически остановлена по истечении времени.")
@@ -109,3 +124,56 @@ func StartRecordingMenu() {
 		fmt.Println("✓ Запись остановлена. Мониторинг выключен.")
 	}
 }
+
+/* Запрашивает порог условия записи, 0 отключает условие, max ограничивает значение сверху если больше 0 */
+func scanRecordingThreshold(prompt string, max float64) (*float64, bool) {
+	fmt.Print(prompt)
+	line, err := utils.ReadLine()
+	if err != nil {
+		return nil, false
+	}
+	value, err := strconv.ParseFloat(line, 64)
+	if err != nil {
+		fmt.Println("Ошибка! Используйте число (например, 70.5 или 70).")
+		return nil, false
+	}
+	if value < 0 {
+		fmt.Println("Порог не может быть отрицательным")
+		return nil, false
+	}
+	if max > 0 && value > max {
+		fmt.Printf("Порог должен быть от 0 до %.0f\n", max)
+		return nil, false
+	}
+	if value == 0 {
+		return nil, true
+	}
+	return &value, true
+}
+
+/* Читает целое число из строки ввода */
+func scanRecordingInt() (int, error) {
+	line, err := utils.ReadLine()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(line)
+}
+
+/* Запрашивает список значений через запятую, - означает пустой список */
+/* Читается вся строка, поэтому пробелы после запятых не разбивают ввод на несколько ответов */
+func scanRecordingList(prompt string) []string {
+	fmt.Print(prompt)
+	value, err := utils.ReadLine()
+	if err != nil || value == "" || value == "-" {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
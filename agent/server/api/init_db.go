@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/RZhurakovskiy/agent/server/db"
 	_ "github.com/mattn/go-sqlite3"
@@ -16,5 +17,22 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		sqlDB.Close()
 		return nil, err
 	}
+	if err := applyMigrations(sqlDB); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 	return sqlDB, nil
 }
+
+/* Применяет миграции схемы, пропуская уже применённые */
+func applyMigrations(sqlDB *sql.DB) error {
+	for _, migration := range db.MigrationsSQL {
+		if _, err := sqlDB.Exec(migration); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
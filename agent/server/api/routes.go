@@ -15,6 +15,46 @@ func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/gethostusername", handlers.GetHostUserName)
 	/* API для завершения процесса по его PID */
 	mux.HandleFunc("/api/kill-process-by-id", handlers.KillProcessById)
+	/* API для получения дерева процессов с показателями поддеревьев */
+	mux.HandleFunc("/api/processes/tree", handlers.GetProcessTree)
+	/* API для получения списка сигналов, которые можно отправить процессу */
+	mux.HandleFunc("/api/processes/signals", handlers.GetProcessSignals)
+	/* API для отправки сигнала процессу по его PID */
+	mux.HandleFunc("/api/processes/signal", handlers.SendProcessSignal)
+	/* API для мягкого завершения процесса: SIGTERM, ожидание и SIGKILL */
+	mux.HandleFunc("/api/processes/graceful-stop", handlers.GracefulStopProcess)
+	/* API для завершения дерева, группы или сессии процесса с предварительным просмотром */
+	mux.HandleFunc("/api/processes/kill-group", handlers.KillProcessGroup)
+	/* API для изменения nice, привязки к CPU и класса ввода-вывода процесса */
+	mux.HandleFunc("/api/processes/tune", handlers.TuneProcess)
+	/* API для получения журнала изменений настроек процессов */
+	mux.HandleFunc("/api/processes/tune/audit", handlers.GetProcessTuningAudit)
+	/* API для получения и создания правил настроек процессов, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/processes/tune/rules", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetProcessTuningRules(writer, request)
+		case http.MethodPost:
+			handlers.CreateProcessTuningRule(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для удаления правила настроек процессов по ID */
+	mux.HandleFunc("/api/processes/tune/rules/{id}", handlers.DeleteProcessTuningRule)
+	/* API для ограничения ресурсов процесса через cgroup v2 и списка управляемых cgroup, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/cgroups", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetManagedCgroups(writer, request)
+		case http.MethodPost:
+			handlers.LimitProcess(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для снятия ограничений и удаления управляемой cgroup по имени */
+	mux.HandleFunc("/api/cgroups/{name}", handlers.ReleaseManagedCgroup)
 	/* API для получения имени пользователя хоста */
 	mux.HandleFunc("/api/get-host-username", handlers.GetHostUserName)
 
@@ -38,11 +78,32 @@ func SetupRoutes(mux *http.ServeMux) {
 
 	/* API для запуска нового процесса */
 	mux.HandleFunc("/api/start-processes", handlers.StartProcess)
+	/* API для получения списка процессов под управлением агента и запуска нового, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/managed", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetManagedProcesses(writer, request)
+		case http.MethodPost:
+			handlers.CreateManagedProcess(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для удаления процесса из списка управляемых */
+	mux.HandleFunc("/api/managed/{id}", handlers.RemoveManagedProcess)
+	/* API для остановки управляемого процесса */
+	mux.HandleFunc("/api/managed/{id}/stop", handlers.StopManagedProcess)
+	/* API для перезапуска управляемого процесса */
+	mux.HandleFunc("/api/managed/{id}/restart", handlers.RestartManagedProcess)
+	/* API для получения последних строк вывода процесса, запущенного агентом */
+	mux.HandleFunc("/api/process-logs", handlers.GetProcessLogs)
 
 	/* API для экспорта списка процессов в CSV или JSON */
 	mux.HandleFunc("/api/export/processes", handlers.ExportProcesses)
 	/* API для экспорта текущих метрик CPU и памяти в CSV или JSON */
 	mux.HandleFunc("/api/export/metrics", handlers.ExportMetrics)
+	/* API для экспорта сравнения сессий записи или интервалов */
+	mux.HandleFunc("/api/export/comparison", handlers.ExportComparison)
 
 	/* API для получения истории метрик за указанный период */
 	mux.HandleFunc("/api/metrics-history", handlers.GetMetricsHistory)
@@ -57,6 +118,63 @@ func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/recording-status", handlers.GetRecordingStatus)
 	/* API для получения списка записанных процессов */
 	mux.HandleFunc("/api/recorded-processes", handlers.GetRecordedProcesses)
+	/* API для получения списка сессий записи со счетчиками */
+	mux.HandleFunc("/api/recordings", handlers.ListRecordings)
+	/* API для сравнения двух сессий записи или интервалов времени */
+	mux.HandleFunc("/api/recordings/compare", handlers.CompareRecordings)
+	/* API для получения и удаления сессии записи по ID, поддерживает GET и DELETE методы */
+	mux.HandleFunc("/api/recordings/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetRecording(writer, request)
+		case http.MethodDelete:
+			handlers.DeleteRecording(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или DELETE", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения списка и создания расписаний записи, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/recording-schedules", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetRecordingSchedules(writer, request)
+		case http.MethodPost:
+			handlers.CreateRecordingSchedule(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения и отмены расписания записи по ID, поддерживает GET и DELETE методы */
+	mux.HandleFunc("/api/recording-schedules/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetRecordingSchedule(writer, request)
+		case http.MethodDelete:
+			handlers.DeleteRecordingSchedule(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или DELETE", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения списка снимков системы сессии записи */
+	mux.HandleFunc("/api/recordings/{id}/snapshots", handlers.GetRecordingSnapshots)
+	/* API для получения полного снимка системы по ID */
+	mux.HandleFunc("/api/recordings/{id}/snapshots/{snapshotId}", handlers.GetRecordingSnapshot)
+
+	/* API для получения и установки настроек бортового самописца, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/flight-recorder/config", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetFlightRecorderConfig(writer, request)
+		case http.MethodPost:
+			handlers.SetFlightRecorderConfig(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения состояния буфера бортового самописца */
+	mux.HandleFunc("/api/flight-recorder/status", handlers.GetFlightRecorderStatus)
+	/* API для ручного сохранения буфера бортового самописца в сессию записи */
+	mux.HandleFunc("/api/flight-recorder/dump", handlers.DumpFlightRecorder)
 
 	/* API для проверки наличия root прав */
 	mux.HandleFunc("/api/get-root-status", handlers.GetRootStatus)
@@ -85,6 +203,140 @@ func SetupRoutes(mux *http.ServeMux) {
 		}
 	})
 
+	/* API для получения списка правил алертов и создания нового правила, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/alerts/rules", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetAlertRules(writer, request)
+		case http.MethodPost:
+			handlers.CreateAlertRule(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для обновления правила алерта */
+	mux.HandleFunc("/api/alerts/rules/update", handlers.UpdateAlertRule)
+	/* API для удаления правила алерта */
+	mux.HandleFunc("/api/alerts/rules/delete", handlers.DeleteAlertRule)
+	/* API для получения списка тишин алертов и создания новой тишины, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/alerts/silences", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetAlertSilences(writer, request)
+		case http.MethodPost:
+			handlers.CreateAlertSilence(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для обновления тишины алертов */
+	mux.HandleFunc("/api/alerts/silences/update", handlers.UpdateAlertSilence)
+	/* API для удаления тишины алертов */
+	mux.HandleFunc("/api/alerts/silences/delete", handlers.DeleteAlertSilence)
+	/* API для получения журнала изменений тишин */
+	mux.HandleFunc("/api/alerts/silences/audit", handlers.GetAlertSilenceAudit)
+	/* API для откладывания алерта на заданное время */
+	mux.HandleFunc("/api/alerts/snooze", handlers.SnoozeAlert)
+	/* API для переноса снятых алертов в архив */
+	mux.HandleFunc("/api/alerts/archive", handlers.ArchiveAlerts)
+	/* API для удаления снятых алертов */
+	mux.HandleFunc("/api/alerts/delete", handlers.DeleteAlerts)
+	/* API для получения заметок к алерту и добавления новой заметки, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/alerts/notes", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetAlertNotes(writer, request)
+		case http.MethodPost:
+			handlers.AddAlertNote(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения статистики алертов по типам, важности и дням */
+	mux.HandleFunc("/api/alerts/stats", handlers.GetAlertStats)
+	/* API для потоковой передачи событий алертов в формате Server-Sent Events */
+	mux.HandleFunc("/api/alerts/stream", handlers.StreamAlertEvents)
+
+	/* API для получения списка каналов уведомлений и создания нового канала, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/notifications/channels", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetNotificationChannels(writer, request)
+		case http.MethodPost:
+			handlers.CreateNotificationChannel(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для обновления канала уведомлений */
+	mux.HandleFunc("/api/notifications/channels/update", handlers.UpdateNotificationChannel)
+	/* API для удаления канала уведомлений */
+	mux.HandleFunc("/api/notifications/channels/delete", handlers.DeleteNotificationChannel)
+	/* API для отправки тестового уведомления в канал */
+	mux.HandleFunc("/api/notifications/channels/test", handlers.TestNotificationChannel)
+	/* API для получения журнала доставки уведомлений */
+	mux.HandleFunc("/api/notifications/deliveries", handlers.GetNotificationDeliveries)
+
+	/* API для получения списка всплесков потребления ресурсов процессами */
+	mux.HandleFunc("/api/spikes", handlers.GetSpikes)
+	/* API для получения и установки настроек детектора всплесков, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/spikes/config", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetSpikeConfig(writer, request)
+		case http.MethodPost:
+			handlers.SetSpikeConfig(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+
+	/* API для получения отчета об утечках памяти процессов */
+	mux.HandleFunc("/api/processes/leaks", handlers.GetProcessLeaks)
+	/* API для получения и установки настроек детектора утечек памяти, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/processes/leaks/config", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetLeakConfig(writer, request)
+		case http.MethodPost:
+			handlers.SetLeakConfig(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+
+	/* API для получения прогноза заполнения файловых систем */
+	mux.HandleFunc("/api/disk-forecast", handlers.GetDiskForecast)
+	/* API для получения истории заполненности файловых систем */
+	mux.HandleFunc("/api/disk-forecast/history", handlers.GetDiskUsageHistory)
+	/* API для получения и установки настроек прогноза заполнения дисков, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/disk-forecast/config", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetDiskForecastConfig(writer, request)
+		case http.MethodPost:
+			handlers.SetDiskForecastConfig(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+
+	/* API для получения и установки настроек детектора аномалий, поддерживает GET и POST методы */
+	mux.HandleFunc("/api/anomalies/config", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			handlers.GetAnomalyConfig(writer, request)
+		case http.MethodPost:
+			handlers.SetAnomalyConfig(writer, request)
+		default:
+			http.Error(writer, "Метод не разрешён. Используйте GET или POST", http.StatusMethodNotAllowed)
+		}
+	})
+	/* API для получения базовых линий детектора аномалий */
+	mux.HandleFunc("/api/anomalies/baselines", handlers.GetAnomalyBaselines)
+	/* API для сброса базовых линий детектора аномалий */
+	mux.HandleFunc("/api/anomalies/baselines/reset", handlers.ResetAnomalyBaselines)
+
 	/* API для получения и установки статуса мониторинга, поддерживает GET и POST методы */
 	mux.HandleFunc("/api/monitoring-status", func(writer http.ResponseWriter, request *http.Request) {
 		switch request.Method {
@@ -101,6 +353,12 @@ func SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/ws/cpu", ws.StreamCPU)
 	/* WebSocket для потоковой передачи метрик памяти в реальном времени */
 	mux.HandleFunc("/ws/memory", ws.StreamMemory)
-	/* WebSocket для потоковой передачи списка процессов в реальном времени */
+	/* WebSocket для потоковой передачи списка процессов в реальном времени, с view=tree - дерева процессов */
 	mux.HandleFunc("/ws/processes", ws.StreamProcesses)
+	/* WebSocket для потоковой передачи событий алертов в реальном времени */
+	mux.HandleFunc("/ws/alerts", ws.StreamAlerts)
+	/* WebSocket для воспроизведения сохраненной сессии записи */
+	mux.HandleFunc("/ws/replay", ws.StreamReplay)
+	/* WebSocket для потоковой передачи вывода процесса, запущенного агентом */
+	mux.HandleFunc("/ws/process-logs", ws.StreamProcessLogs)
 }
@@ -28,6 +28,9 @@ func StartServer(port string) {
 	defer sqlDB.Close()
 
 	services.SetDB(sqlDB)
+	services.StartRecordingScheduler()
+	services.StartProcessLogCleanup()
+	services.RestoreManagedProcesses()
 
 	mux := http.NewServeMux()
 
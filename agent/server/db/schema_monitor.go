@@ -10,7 +10,8 @@ CREATE TABLE IF NOT EXISTS spikes (
     memory_rss INTEGER,
     memory_vms INTEGER,
     duration_sec INTEGER DEFAULT 0,
-    reason TEXT NOT NULL      
+    reason TEXT NOT NULL,
+    ended_at DATETIME
 );
 
 CREATE INDEX IF NOT EXISTS idx_pid ON spikes(pid);
@@ -24,7 +25,30 @@ CREATE TABLE IF NOT EXISTS recording_sessions (
     cpu_threshold REAL NOT NULL,
     ram_threshold REAL NOT NULL,
     duration_sec INTEGER NOT NULL,
-    status TEXT DEFAULT 'active'
+    status TEXT DEFAULT 'active',
+    criteria TEXT,
+    mode TEXT DEFAULT 'processes',
+    interval_sec INTEGER,
+    alert_id INTEGER,
+    schedule_id INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS recording_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    name TEXT,
+    schedule TEXT,
+    start_at DATETIME,
+    mode TEXT NOT NULL DEFAULT 'processes',
+    criteria TEXT,
+    interval_sec INTEGER,
+    duration_sec INTEGER NOT NULL,
+    concurrency TEXT NOT NULL DEFAULT 'skip',
+    enabled INTEGER DEFAULT 1,
+    next_run_at DATETIME,
+    last_run_at DATETIME,
+    last_session_id INTEGER,
+    last_result TEXT
 );
 
 CREATE TABLE IF NOT EXISTS recorded_processes (
@@ -46,13 +70,30 @@ CREATE INDEX IF NOT EXISTS idx_recorded_session ON recorded_processes(session_id
 CREATE INDEX IF NOT EXISTS idx_recorded_at ON recorded_processes(recorded_at);
 CREATE INDEX IF NOT EXISTS idx_recorded_pid ON recorded_processes(pid);
 
+CREATE TABLE IF NOT EXISTS recording_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL,
+    captured_at DATETIME NOT NULL,
+    process_count INTEGER NOT NULL,
+    connection_count INTEGER NOT NULL,
+    cpu_percent REAL,
+    memory_percent REAL,
+    data BLOB NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES recording_sessions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recording_snapshots_session ON recording_snapshots(session_id, captured_at);
+
 CREATE TABLE IF NOT EXISTS metrics_history (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp DATETIME DEFAULT (datetime('now')),
     cpu_percent REAL NOT NULL,
     memory_percent REAL NOT NULL,
     memory_used_mb INTEGER NOT NULL,
-    memory_total_mb INTEGER NOT NULL
+    memory_total_mb INTEGER NOT NULL,
+    load1 REAL,
+    net_rx_bps REAL,
+    net_tx_bps REAL
 );
 
 CREATE INDEX IF NOT EXISTS idx_metrics_timestamp ON metrics_history(timestamp);
@@ -64,9 +105,233 @@ CREATE TABLE IF NOT EXISTS alerts (
     threshold REAL NOT NULL,
     current_value REAL NOT NULL,
     message TEXT NOT NULL,
-    acknowledged INTEGER DEFAULT 0
+    acknowledged INTEGER DEFAULT 0,
+    rule_id INTEGER,
+    severity TEXT DEFAULT 'warning',
+    status TEXT DEFAULT 'resolved',
+    fingerprint TEXT,
+    started_at DATETIME,
+    resolved_at DATETIME,
+    pid INTEGER,
+    process_name TEXT,
+    silenced INTEGER DEFAULT 0,
+    silence_id INTEGER,
+    acknowledged_by TEXT,
+    acknowledged_at DATETIME,
+    archived INTEGER DEFAULT 0
 );
 
 CREATE INDEX IF NOT EXISTS idx_alerts_created_at ON alerts(created_at);
 CREATE INDEX IF NOT EXISTS idx_alerts_type ON alerts(type);
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    name TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    comparator TEXT NOT NULL DEFAULT '>',
+    threshold REAL NOT NULL,
+    for_sec INTEGER NOT NULL DEFAULT 0,
+    clear_threshold REAL,
+    severity TEXT NOT NULL DEFAULT 'warning',
+    enabled INTEGER DEFAULT 1,
+    scope TEXT NOT NULL DEFAULT 'host',
+    match_name TEXT,
+    match_user TEXT,
+    aggregate TEXT,
+    channel_ids TEXT,
+    recording TEXT
+);
+
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    config TEXT NOT NULL,
+    template TEXT,
+    min_severity TEXT DEFAULT 'info',
+    is_default INTEGER DEFAULT 1,
+    enabled INTEGER DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    alert_id INTEGER,
+    channel_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_alert ON notification_deliveries(alert_id);
+CREATE INDEX IF NOT EXISTS idx_deliveries_created_at ON notification_deliveries(created_at);
+
+CREATE TABLE IF NOT EXISTS alert_silences (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    created_by TEXT NOT NULL,
+    comment TEXT,
+    matcher_type TEXT NOT NULL DEFAULT 'all',
+    matcher_value TEXT,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME,
+    schedule TEXT,
+    duration_sec INTEGER DEFAULT 0,
+    mode TEXT NOT NULL DEFAULT 'suppress',
+    enabled INTEGER DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS alert_silence_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    silence_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    details TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_silence_audit_silence ON alert_silence_audit(silence_id);
+
+CREATE TABLE IF NOT EXISTS alert_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    alert_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_alert ON alert_events(alert_id);
+
+CREATE TABLE IF NOT EXISTS alert_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    alert_id INTEGER NOT NULL,
+    author TEXT NOT NULL,
+    text TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_notes_alert ON alert_notes(alert_id);
+
+CREATE TABLE IF NOT EXISTS anomaly_baselines (
+    metric TEXT NOT NULL,
+    hour INTEGER NOT NULL,
+    samples INTEGER NOT NULL,
+    mean REAL NOT NULL,
+    variance REAL NOT NULL,
+    recent TEXT,
+    updated_at DATETIME DEFAULT (datetime('now')),
+    PRIMARY KEY (metric, hour)
+);
+
+CREATE TABLE IF NOT EXISTS disk_usage_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp DATETIME DEFAULT (datetime('now')),
+    mountpoint TEXT NOT NULL,
+    device TEXT,
+    fstype TEXT,
+    total_bytes INTEGER NOT NULL,
+    used_bytes INTEGER NOT NULL,
+    free_bytes INTEGER,
+    inodes_total INTEGER,
+    inodes_used INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_disk_usage_history_mountpoint ON disk_usage_history(mountpoint, timestamp);
+
+CREATE TABLE IF NOT EXISTS process_tuning_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    match_name TEXT NOT NULL,
+    nice INTEGER,
+    affinity TEXT,
+    io_class TEXT,
+    io_level INTEGER,
+    enabled INTEGER DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS process_tuning_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    pid INTEGER NOT NULL,
+    process_name TEXT,
+    setting TEXT NOT NULL,
+    previous_value TEXT,
+    new_value TEXT,
+    actor TEXT NOT NULL,
+    rule_id INTEGER,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_process_tuning_audit_pid ON process_tuning_audit(pid);
+
+CREATE TABLE IF NOT EXISTS managed_processes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT (datetime('now')),
+    name TEXT NOT NULL,
+    command TEXT NOT NULL,
+    args TEXT,
+    cwd TEXT,
+    options TEXT,
+    restart_policy TEXT NOT NULL DEFAULT 'on-failure',
+    max_restarts INTEGER NOT NULL DEFAULT 0,
+    backoff_sec INTEGER NOT NULL DEFAULT 1,
+    status TEXT NOT NULL DEFAULT 'running',
+    pid INTEGER,
+    pid_create_time INTEGER,
+    restarts INTEGER NOT NULL DEFAULT 0,
+    last_started_at DATETIME,
+    last_exited_at DATETIME,
+    last_exit_code INTEGER,
+    last_error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS managed_cgroups (
+    name TEXT PRIMARY KEY,
+    created_at DATETIME DEFAULT (datetime('now')),
+    pid INTEGER NOT NULL,
+    original_cgroup TEXT NOT NULL
+);
 `
+
+/*
+Миграции для баз данных, созданных предыдущими версиями агента
+
+	Выполняются после SchemaSQL, ошибка "duplicate column name" означает что миграция уже применена
+*/
+var MigrationsSQL = []string{
+	"ALTER TABLE spikes ADD COLUMN ended_at DATETIME",
+	"ALTER TABLE alerts ADD COLUMN rule_id INTEGER",
+	"ALTER TABLE alerts ADD COLUMN severity TEXT DEFAULT 'warning'",
+	"ALTER TABLE alerts ADD COLUMN status TEXT DEFAULT 'resolved'",
+	"ALTER TABLE alerts ADD COLUMN fingerprint TEXT",
+	"ALTER TABLE alerts ADD COLUMN started_at DATETIME",
+	"ALTER TABLE alerts ADD COLUMN resolved_at DATETIME",
+	"CREATE INDEX IF NOT EXISTS idx_alerts_fingerprint ON alerts(fingerprint, status)",
+	"ALTER TABLE alerts ADD COLUMN pid INTEGER",
+	"ALTER TABLE alerts ADD COLUMN process_name TEXT",
+	"ALTER TABLE alert_rules ADD COLUMN scope TEXT NOT NULL DEFAULT 'host'",
+	"ALTER TABLE alert_rules ADD COLUMN match_name TEXT",
+	"ALTER TABLE alert_rules ADD COLUMN match_user TEXT",
+	"ALTER TABLE alert_rules ADD COLUMN aggregate TEXT",
+	"ALTER TABLE alert_rules ADD COLUMN channel_ids TEXT",
+	"ALTER TABLE alerts ADD COLUMN silenced INTEGER DEFAULT 0",
+	"ALTER TABLE alerts ADD COLUMN silence_id INTEGER",
+	"ALTER TABLE alerts ADD COLUMN acknowledged_by TEXT",
+	"ALTER TABLE alerts ADD COLUMN acknowledged_at DATETIME",
+	"ALTER TABLE alerts ADD COLUMN archived INTEGER DEFAULT 0",
+	"CREATE INDEX IF NOT EXISTS idx_alerts_severity ON alerts(severity)",
+	"ALTER TABLE metrics_history ADD COLUMN load1 REAL",
+	"ALTER TABLE metrics_history ADD COLUMN net_rx_bps REAL",
+	"ALTER TABLE metrics_history ADD COLUMN net_tx_bps REAL",
+	"ALTER TABLE recording_sessions ADD COLUMN criteria TEXT",
+	"ALTER TABLE recording_sessions ADD COLUMN mode TEXT DEFAULT 'processes'",
+	"ALTER TABLE recording_sessions ADD COLUMN interval_sec INTEGER",
+	"ALTER TABLE recording_sessions ADD COLUMN alert_id INTEGER",
+	"ALTER TABLE recording_sessions ADD COLUMN schedule_id INTEGER",
+	"ALTER TABLE alert_rules ADD COLUMN recording TEXT",
+	"ALTER TABLE managed_processes ADD COLUMN options TEXT",
+	"ALTER TABLE disk_usage_history ADD COLUMN free_bytes INTEGER",
+}
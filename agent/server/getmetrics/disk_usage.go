@@ -0,0 +1,40 @@
+/* Функции для получения заполненности файловых систем */
+package getmetrics
+
+import (
+	"github.com/RZhurakovskiy/agent/server/models"
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+/* Получает заполненность всех смонтированных файловых систем по объему и inode */
+/* Возвращает список файловых систем или ошибку, разделы с нулевым объемом пропускаются */
+func GetDiskUsage() ([]models.DiskUsageStat, error) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.DiskUsageStat, 0, len(partitions))
+	seen := make(map[string]bool, len(partitions))
+	for _, p := range partitions {
+		if seen[p.Mountpoint] {
+			continue
+		}
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil || usage == nil || usage.Total == 0 {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		stats = append(stats, models.DiskUsageStat{
+			Device:      p.Device,
+			Mountpoint:  p.Mountpoint,
+			Fstype:      p.Fstype,
+			TotalBytes:  usage.Total,
+			UsedBytes:   usage.Used,
+			FreeBytes:   usage.Free,
+			InodesTotal: usage.InodesTotal,
+			InodesUsed:  usage.InodesUsed,
+		})
+	}
+	return stats, nil
+}
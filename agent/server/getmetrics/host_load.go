@@ -0,0 +1,27 @@
+/* Функции для получения средней нагрузки и суммарного сетевого трафика хоста */
+package getmetrics
+
+import (
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+/* Получает среднюю нагрузку системы за последнюю минуту */
+/* Возвращает load average или ошибку */
+func LoadAverage() (float64, error) {
+	avg, err := load.Avg()
+	if err != nil {
+		return 0, err
+	}
+	return avg.Load1, nil
+}
+
+/* Получает суммарное количество принятых и отправленных байт по всем сетевым интерфейсам */
+/* Возвращает принятые байты, отправленные байты или ошибку */
+func NetworkTotals() (uint64, uint64, error) {
+	counters, err := net.IOCounters(false)
+	if err != nil || len(counters) == 0 {
+		return 0, 0, err
+	}
+	return counters[0].BytesRecv, counters[0].BytesSent, nil
+}
@@ -19,3 +19,13 @@ func UsageMemory() (float64, uint64, uint64, error) {
 
 	return memory.UsedPercent, totalMiB, usedMiB, nil
 }
+
+/* Получает объем доступной для выделения и общий объем памяти в байтах */
+/* Возвращает доступный объем, общий объем или ошибку */
+func AvailableMemory() (uint64, uint64, error) {
+	memory, err := mem.VirtualMemory()
+	if err != nil {
+		return 0, 0, err
+	}
+	return memory.Available, memory.Total, nil
+}
@@ -84,6 +84,7 @@ func UsageProcess(allConnections []net.ConnectionStat) ([]models.ProcessInfo, er
 
 			if memInfo, err := proc.MemoryInfo(); err == nil {
 				info.MemoryRSS = memInfo.RSS
+				info.MemoryVMS = memInfo.VMS
 			}
 
 			info.Ports = make([]uint32, 0)
@@ -168,6 +169,7 @@ func UsageProcessLimited(limit int, allConnections []net.ConnectionStat) ([]mode
 		}
 		if memInfo, err := p.MemoryInfo(); err == nil {
 			info.MemoryRSS = memInfo.RSS
+			info.MemoryVMS = memInfo.VMS
 		}
 
 		info.Ports = make([]uint32, 0)
@@ -0,0 +1,74 @@
+/* Функции для снятия полного снимка состояния системы */
+package getmetrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+/* Снимает полный снимок системы: CPU, память, нагрузку, все процессы, счетчики интерфейсов и таблицу соединений */
+/* Скорость трафика интерфейсов не заполняется, ее считают по разнице с предыдущим снимком */
+func CaptureSystemSnapshot() (models.SystemSnapshot, error) {
+	snapshot := models.SystemSnapshot{CapturedAt: time.Now()}
+
+	cpuPercent, err := UsageCPU(100 * time.Millisecond)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.CPUPercent = cpuPercent
+
+	memPercent, totalMB, usedMB, err := UsageMemory()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.MemoryPercent = memPercent
+	snapshot.MemoryTotalMB = totalMB
+	snapshot.MemoryUsedMB = usedMB
+
+	if load1, err := LoadAverage(); err == nil {
+		snapshot.Load1 = load1
+	}
+
+	conns, err := net.Connections("all")
+	if err != nil {
+		conns = []net.ConnectionStat{}
+	}
+
+	procs, err := UsageProcess(conns)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Processes = procs
+
+	snapshot.Connections = make([]models.SnapshotConnection, 0, len(conns))
+	for _, conn := range conns {
+		remoteAddr := ""
+		if conn.Raddr.IP != "" {
+			remoteAddr = fmt.Sprintf("%s:%d", conn.Raddr.IP, conn.Raddr.Port)
+		}
+		snapshot.Connections = append(snapshot.Connections, models.SnapshotConnection{
+			Protocol:   protoFromConn(conn),
+			LocalAddr:  fmt.Sprintf("%s:%d", conn.Laddr.IP, conn.Laddr.Port),
+			RemoteAddr: remoteAddr,
+			Status:     conn.Status,
+			PID:        conn.Pid,
+		})
+	}
+
+	counters, err := net.IOCounters(true)
+	if err == nil {
+		snapshot.Interfaces = make([]models.SnapshotInterface, 0, len(counters))
+		for _, c := range counters {
+			snapshot.Interfaces = append(snapshot.Interfaces, models.SnapshotInterface{
+				Name:      c.Name,
+				BytesSent: c.BytesSent,
+				BytesRecv: c.BytesRecv,
+			})
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,170 @@
+/* Обработчики для архивации и удаления алертов, заметок к ним и статистики */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Запрос на выбор снятых алертов по списку ID или по возрасту в днях */
+type alertSelectionRequest struct {
+	IDs           []int64 `json:"ids"`
+	OlderThanDays int     `json:"olderThanDays"`
+}
+
+/* Возвращает границу по дате создания для выбора алертов старше указанного числа дней */
+func (req alertSelectionRequest) before() time.Time {
+	if req.OlderThanDays <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -req.OlderThanDays)
+}
+
+/* Переносит снятые алерты в архив по списку ID или по возрасту */
+func ArchiveAlerts(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req alertSelectionRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	count, err := services.ArchiveAlerts(req.IDs, req.before())
+	if err != nil {
+		http.Error(writer, "Ошибка архивации алертов: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success":  true,
+		"archived": count,
+		"message":  "Алерты перенесены в архив",
+	})
+}
+
+/* Удаляет снятые алерты и их заметки по списку ID или по возрасту */
+func DeleteAlerts(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req alertSelectionRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	count, err := services.DeleteAlerts(req.IDs, req.before())
+	if err != nil {
+		http.Error(writer, "Ошибка удаления алертов: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"deleted": count,
+		"message": "Алерты удалены",
+	})
+}
+
+/* Возвращает заметки к алерту по параметру alertId */
+func GetAlertNotes(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alertID, err := strconv.ParseInt(request.URL.Query().Get("alertId"), 10, 64)
+	if err != nil || alertID <= 0 {
+		http.Error(writer, "Некорректный ID алерта", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := services.GetAlertNotes(alertID)
+	if err != nil {
+		http.Error(writer, "Ошибка получения заметок: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(notes); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Добавляет заметку к алерту */
+func AddAlertNote(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AlertID int64  `json:"alertId"`
+		Author  string `json:"author"`
+		Text    string `json:"text"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := services.AddAlertNote(req.AlertID, requestActor(request, req.Author), req.Text)
+	if err != nil {
+		http.Error(writer, "Ошибка добавления заметки: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Заметка добавлена",
+	})
+}
+
+/* Возвращает статистику алертов по типам, важности и дням с теми же фильтрами, что и список алертов */
+func GetAlertStats(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAlertFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Archived == "" {
+		filter.Archived = "all"
+	}
+
+	stats, err := services.GetAlertStats(filter)
+	if err != nil {
+		http.Error(writer, "Ошибка получения статистики алертов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(stats); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,116 @@
+/* Обработчики для управления правилами алертов */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список всех правил алертов в формате JSON */
+func GetAlertRules(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := services.GetAlertRules()
+	if err != nil {
+		http.Error(writer, "Ошибка получения правил: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rules); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Создаёт новое правило алерта с метрикой, оператором, порогами, длительностью и важностью */
+func CreateAlertRule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rule := services.AlertRule{Enabled: true}
+	if err := json.NewDecoder(request.Body).Decode(&rule); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := services.CreateAlertRule(rule)
+	if err != nil {
+		http.Error(writer, "Ошибка создания правила: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Правило создано",
+	})
+}
+
+/* Обновляет правило алерта по его ID, правило передаётся целиком */
+func UpdateAlertRule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule services.AlertRule
+	if err := json.NewDecoder(request.Body).Decode(&rule); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if rule.ID <= 0 {
+		http.Error(writer, "Некорректный ID правила", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.UpdateAlertRule(rule); err != nil {
+		http.Error(writer, "Ошибка обновления правила: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Правило обновлено",
+	})
+}
+
+/* Удаляет правило алерта по его ID, сработавшие по нему алерты снимаются при следующей проверке */
+func DeleteAlertRule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteAlertRule(req.ID); err != nil {
+		http.Error(writer, "Ошибка удаления правила: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Правило удалено",
+	})
+}
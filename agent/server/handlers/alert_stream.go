@@ -0,0 +1,93 @@
+/* Обработчик потока событий алертов в формате Server-Sent Events */
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Передаёт события алертов в формате text/event-stream
+
+	Возобновление потока по заголовку Last-Event-ID, который браузер отправляет сам, или по параметру lastEventId
+*/
+func StreamAlertEvents(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lastIDStr := request.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = request.URL.Query().Get("lastEventId")
+	}
+	lastID, _ := strconv.ParseInt(lastIDStr, 10, 64)
+
+	missed, events, unsubscribe, err := services.ResumeAlertEvents(lastID)
+	if err != nil {
+		http.Error(writer, "Ошибка получения пропущенных событий: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer unsubscribe()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	/* Общий таймаут записи сервера продлевается перед каждой отправкой, иначе поток оборвётся через 15 секунд */
+	controller := http.NewResponseController(writer)
+	send := func(payload string) error {
+		controller.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if _, err := fmt.Fprint(writer, payload); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	if err := send("retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, event := range missed {
+		if err := send(formatAlertSSE(event)); err != nil {
+			return
+		}
+		lastID = event.ID
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if err := send(formatAlertSSE(event)); err != nil {
+				return
+			}
+			lastID = event.ID
+		case <-heartbeat.C:
+			if err := send(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* Форматирует событие алерта как сообщение SSE с ID, типом события и JSON данными */
+func formatAlertSSE(event services.AlertEvent) string {
+	data, _ := json.Marshal(event)
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Event, data)
+}
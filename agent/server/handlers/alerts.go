@@ -3,43 +3,55 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/RZhurakovskiy/agent/server/services"
 )
 
-/* Получает список алертов с поддержкой фильтрации по неподтвержденным и лимиту записей
-   Возвращает JSON массив с алертами */
+/* Получает список алертов с фильтрацией по типу, важности, статусу, времени, процессу и тексту
+   Возвращает JSON массив с алертами, курсор следующей страницы передаётся в заголовке X-Next-Cursor */
 func GetAlerts(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
 		return
 	}
 
-	limitStr := request.URL.Query().Get("limit")
-	unacknowledgedOnlyStr := request.URL.Query().Get("unacknowledged_only")
-
-	limit := 100
-	unacknowledgedOnly := false
+	filter, err := parseAlertFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if limitStr != "" {
+	filter.Limit = 100
+	if limitStr := request.URL.Query().Get("limit"); limitStr != "" {
 		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
+			filter.Limit = parsed
 		}
 	}
-
-	if unacknowledgedOnlyStr == "true" || unacknowledgedOnlyStr == "1" {
-		unacknowledgedOnly = true
+	if cursorStr := request.URL.Query().Get("cursor"); cursorStr != "" {
+		cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil || cursor <= 0 {
+			http.Error(writer, "Некорректный курсор: "+cursorStr, http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
 	}
 
-	alerts, err := services.GetAlerts(limit, unacknowledgedOnly)
+	alerts, nextCursor, err := services.QueryAlerts(filter)
 	if err != nil {
 		http.Error(writer, "Ошибка получения алертов: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
+	if nextCursor > 0 {
+		writer.Header().Set("X-Next-Cursor", strconv.FormatInt(nextCursor, 10))
+	}
 	encoder := json.NewEncoder(writer)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(alerts); err != nil {
@@ -48,7 +60,56 @@ func GetAlerts(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
-/* Отмечает алерт как подтвержденный по его ID */
+/* Разбирает параметры фильтра алертов из строки запроса
+   Списки type и severity передаются через запятую */
+func parseAlertFilter(query url.Values) (services.AlertFilter, error) {
+	filter := services.AlertFilter{
+		Status:      query.Get("status"),
+		ProcessName: query.Get("process"),
+		Search:      query.Get("q"),
+		Archived:    query.Get("archived"),
+	}
+
+	if value := query.Get("type"); value != "" {
+		filter.Types = strings.Split(value, ",")
+	}
+	if value := query.Get("severity"); value != "" {
+		filter.Severities = strings.Split(value, ",")
+	}
+	if value := query.Get("pid"); value != "" {
+		pid, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("Некорректный PID: %s", value)
+		}
+		filter.PID = int32(pid)
+	}
+	if value := query.Get("unacknowledged_only"); value == "true" || value == "1" {
+		filter.UnacknowledgedOnly = true
+	}
+	switch filter.Archived {
+	case "true", "1":
+		filter.Archived = "only"
+	case "false", "0":
+		filter.Archived = ""
+	}
+
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, ok := parseQueryTime(value)
+		if !ok {
+			return filter, fmt.Errorf("Некорректный формат даты '%s': %s", param, value)
+		}
+		*target = parsed
+	}
+
+	return filter, filter.Validate()
+}
+
+/* Подтверждает алерты: один по ID, несколько по списку ids или все подходящие под фильтр
+   Поле acknowledgedBy сохраняется как автор подтверждения */
 func AcknowledgeAlert(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
 		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
@@ -56,7 +117,10 @@ func AcknowledgeAlert(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	var req struct {
-		ID int64 `json:"id"`
+		ID             int64                 `json:"id"`
+		IDs            []int64               `json:"ids"`
+		Filter         *services.AlertFilter `json:"filter"`
+		AcknowledgedBy string                `json:"acknowledgedBy"`
 	}
 
 	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
@@ -64,15 +128,35 @@ func AcknowledgeAlert(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	if err := services.AcknowledgeAlert(req.ID); err != nil {
+	acknowledgedBy := requestActor(request, req.AcknowledgedBy)
+
+	var count int64
+	var err error
+	switch {
+	case req.Filter != nil:
+		if err := req.Filter.Validate(); err != nil {
+			http.Error(writer, "Некорректный фильтр: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		count, err = services.AcknowledgeAlertsByFilter(*req.Filter, acknowledgedBy)
+	case len(req.IDs) > 0:
+		count, err = services.AcknowledgeAlerts(req.IDs, acknowledgedBy)
+	case req.ID > 0:
+		count, err = services.AcknowledgeAlerts([]int64{req.ID}, acknowledgedBy)
+	default:
+		http.Error(writer, "Нужно указать id, ids или filter", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		http.Error(writer, "Ошибка подтверждения алерта: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Алерт подтвержден",
+		"success":      true,
+		"acknowledged": count,
+		"message":      "Алерт подтвержден",
 	})
 }
 
@@ -0,0 +1,90 @@
+/* Обработчики для управления детектором аномалий метрик хоста */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает текущие настройки детектора аномалий в формате JSON */
+func GetAnomalyConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetAnomalyConfig())
+}
+
+/* Включает детектор аномалий и задаёт метод, чувствительность, длительность и проверяемые метрики */
+func SetAnomalyConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := services.GetAnomalyConfig()
+	if err := json.NewDecoder(request.Body).Decode(&cfg); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.SetAnomalyConfig(cfg); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"config":  cfg,
+		"message": "Настройки детектора аномалий сохранены",
+	})
+}
+
+/* Возвращает базовые линии метрик по часам суток */
+func GetAnomalyBaselines(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(services.GetAnomalyBaselines()); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Сбрасывает базовые линии указанных метрик, без списка сбрасываются все */
+func ResetAnomalyBaselines(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Metrics []string `json:"metrics"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.ResetAnomalyBaselines(req.Metrics); err != nil {
+		http.Error(writer, "Ошибка сброса базовых линий: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Базовые линии сброшены",
+	})
+}
@@ -0,0 +1,92 @@
+/* Обработчики для ограничения ресурсов процессов через cgroup v2 */
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Запрос на ограничение ресурсов процесса */
+type LimitProcessRequest struct {
+	PID int32 `json:"pid"`
+	services.CgroupLimits
+}
+
+/* Возвращает код ответа для ошибки cgroup: 503, если cgroup v2 недоступен агенту, иначе fallback */
+func cgroupErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrCgroupUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
+
+/* Переносит процесс в управляемую агентом cgroup с ограничениями CPU, памяти и ввода-вывода */
+func LimitProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LimitProcessRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	cgroup, err := services.LimitProcess(req.PID, req.CgroupLimits)
+	if err != nil {
+		http.Error(writer, "Ошибка ограничения ресурсов процесса: "+err.Error(), cgroupErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"cgroup":  cgroup,
+		"message": "Ограничения ресурсов применены",
+	})
+}
+
+/* Возвращает список управляемых агентом cgroup с текущими ограничениями и процессами */
+func GetManagedCgroups(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cgroups, err := services.GetManagedCgroups()
+	if err != nil {
+		http.Error(writer, "Ошибка получения cgroup: "+err.Error(), cgroupErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cgroups); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Снимает ограничения: возвращает процессы в исходную cgroup и удаляет управляемую cgroup по имени из пути */
+func ReleaseManagedCgroup(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Метод не разрешён. Используйте DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := services.ReleaseManagedCgroup(request.PathValue("name")); err != nil {
+		http.Error(writer, "Ошибка снятия ограничений: "+err.Error(), cgroupErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Ограничения сняты",
+	})
+}
@@ -0,0 +1,123 @@
+/* Обработчики для истории заполненности и прогноза заполнения файловых систем */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Возвращает прогноз заполнения по объему и inode для каждой файловой системы
+
+	Параметр method (linear или holt) переопределяет метод из настроек, mountpoint оставляет одну файловую систему
+*/
+func GetDiskForecast(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	forecasts, err := services.GetDiskForecasts(query.Get("method"))
+	if err != nil {
+		http.Error(writer, "Ошибка прогноза заполнения дисков: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if mountpoint := query.Get("mountpoint"); mountpoint != "" {
+		filtered := make([]services.DiskForecast, 0, 1)
+		for _, f := range forecasts {
+			if f.Mountpoint == mountpoint {
+				filtered = append(filtered, f)
+			}
+		}
+		forecasts = filtered
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(forecasts); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает историю заполненности файловых систем с фильтрацией по mountpoint, from и to, по умолчанию за последние сутки */
+func GetDiskUsageHistory(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, ok := parseQueryTime(value)
+		if !ok {
+			http.Error(writer, "Некорректный формат даты '"+param+"': "+value, http.StatusBadRequest)
+			return
+		}
+		*target = parsed
+	}
+
+	samples, err := services.GetDiskUsageHistory(query.Get("mountpoint"), from, to)
+	if err != nil {
+		http.Error(writer, "Ошибка получения истории заполненности дисков: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(samples); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает текущие настройки прогноза заполнения дисков в формате JSON */
+func GetDiskForecastConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetDiskForecastConfig())
+}
+
+/* Устанавливает интервал замеров, метод прогноза и горизонт алертов о заполнении дисков */
+func SetDiskForecastConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := services.GetDiskForecastConfig()
+	if err := json.NewDecoder(request.Body).Decode(&cfg); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.SetDiskForecastConfig(cfg); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"config":  cfg,
+		"message": "Настройки прогноза заполнения дисков сохранены",
+	})
+}
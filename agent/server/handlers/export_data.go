@@ -13,6 +13,7 @@ import (
 
 	"github.com/RZhurakovskiy/agent/server/getmetrics"
 	"github.com/RZhurakovskiy/agent/server/models"
+	"github.com/RZhurakovskiy/agent/server/services"
 	"github.com/shirou/gopsutil/v4/net"
 )
 
@@ -214,3 +215,114 @@ func exportMetricsJSON(writer http.ResponseWriter, metrics map[string]interface{
 		return
 	}
 }
+
+/* Экспортирует сравнение двух сессий записи или интервалов в CSV или JSON формат, окна задаются как в /api/recordings/compare */
+func ExportComparison(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := strings.ToLower(request.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(writer, "Неподдерживаемый формат. Используйте 'csv' или 'json'", http.StatusBadRequest)
+		return
+	}
+
+	comparison, ok := compareRecordingWindows(writer, request)
+	if !ok {
+		return
+	}
+
+	if format == "csv" {
+		exportComparisonCSV(writer, comparison)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=comparison_%s.json", time.Now().Format("20060102_150405")))
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(comparison); err != nil {
+		log.Printf("Ошибка сериализации JSON: %v", err)
+		http.Error(writer, "Ошибка формирования JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Записывает сравнение в CSV формат: таблица процессов, пустая строка и таблица показателей хоста */
+func exportComparisonCSV(writer http.ResponseWriter, comparison *services.RecordingComparison) {
+	writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=comparison_%s.csv", time.Now().Format("20060102_150405")))
+
+	w := csv.NewWriter(writer)
+	defer w.Flush()
+
+	headers := []string{
+		"Имя",
+		"Записей до", "Записей после", "Изменение записей",
+		"Средний CPU % до", "Средний CPU % после", "Изменение среднего CPU %",
+		"Пиковый CPU % до", "Пиковый CPU % после", "Изменение пикового CPU %",
+		"Средний RSS до (байты)", "Средний RSS после (байты)", "Изменение среднего RSS (байты)",
+		"Пиковый RSS до (байты)", "Пиковый RSS после (байты)", "Изменение пикового RSS (байты)",
+	}
+	if err := w.Write(headers); err != nil {
+		log.Printf("Ошибка записи CSV заголовков: %v", err)
+		return
+	}
+
+	for _, p := range comparison.Processes {
+		var before, after services.ProcessWindowStats
+		if p.Before != nil {
+			before = *p.Before
+		}
+		if p.After != nil {
+			after = *p.After
+		}
+
+		record := []string{
+			p.Name,
+			strconv.FormatInt(before.Occurrences, 10),
+			strconv.FormatInt(after.Occurrences, 10),
+			strconv.FormatInt(p.DeltaOccurrences, 10),
+			fmt.Sprintf("%.2f", before.AvgCPU),
+			fmt.Sprintf("%.2f", after.AvgCPU),
+			fmt.Sprintf("%.2f", p.DeltaAvgCPU),
+			fmt.Sprintf("%.2f", before.MaxCPU),
+			fmt.Sprintf("%.2f", after.MaxCPU),
+			fmt.Sprintf("%.2f", p.DeltaMaxCPU),
+			fmt.Sprintf("%.0f", before.AvgRSS),
+			fmt.Sprintf("%.0f", after.AvgRSS),
+			fmt.Sprintf("%.0f", p.DeltaAvgRSS),
+			strconv.FormatInt(before.MaxRSS, 10),
+			strconv.FormatInt(after.MaxRSS, 10),
+			strconv.FormatInt(p.DeltaMaxRSS, 10),
+		}
+		if err := w.Write(record); err != nil {
+			log.Printf("Ошибка записи CSV строки: %v", err)
+			return
+		}
+	}
+
+	host := comparison.Host
+	rows := [][]string{
+		{},
+		{"Показатель хоста", "До", "После", "Изменение"},
+		{"Замеров", strconv.FormatInt(host.Before.Samples, 10), strconv.FormatInt(host.After.Samples, 10), strconv.FormatInt(host.Delta.Samples, 10)},
+		{"Средний CPU %", fmt.Sprintf("%.2f", host.Before.AvgCPU), fmt.Sprintf("%.2f", host.After.AvgCPU), fmt.Sprintf("%.2f", host.Delta.AvgCPU)},
+		{"Пиковый CPU %", fmt.Sprintf("%.2f", host.Before.MaxCPU), fmt.Sprintf("%.2f", host.After.MaxCPU), fmt.Sprintf("%.2f", host.Delta.MaxCPU)},
+		{"Средняя память %", fmt.Sprintf("%.2f", host.Before.AvgMemory), fmt.Sprintf("%.2f", host.After.AvgMemory), fmt.Sprintf("%.2f", host.Delta.AvgMemory)},
+		{"Пиковая память %", fmt.Sprintf("%.2f", host.Before.MaxMemory), fmt.Sprintf("%.2f", host.After.MaxMemory), fmt.Sprintf("%.2f", host.Delta.MaxMemory)},
+		{"Средняя нагрузка (1 мин)", fmt.Sprintf("%.2f", host.Before.AvgLoad1), fmt.Sprintf("%.2f", host.After.AvgLoad1), fmt.Sprintf("%.2f", host.Delta.AvgLoad1)},
+		{"Средний прием (байт/с)", fmt.Sprintf("%.0f", host.Before.AvgNetRx), fmt.Sprintf("%.0f", host.After.AvgNetRx), fmt.Sprintf("%.0f", host.Delta.AvgNetRx)},
+		{"Средняя передача (байт/с)", fmt.Sprintf("%.0f", host.Before.AvgNetTx), fmt.Sprintf("%.0f", host.After.AvgNetTx), fmt.Sprintf("%.0f", host.Delta.AvgNetTx)},
+	}
+	if err := w.WriteAll(rows); err != nil {
+		log.Printf("Ошибка записи CSV строки: %v", err)
+		return
+	}
+}
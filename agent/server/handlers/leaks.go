@@ -0,0 +1,73 @@
+/* Обработчики для отчета об утечках памяти процессов */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Возвращает отчет об утечках памяти с прогнозом исчерпания памяти хоста
+
+	Параметр all=true включает в отчет все процессы с оцененным трендом, а не только подозрительные
+*/
+func GetProcessLeaks(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	all := request.URL.Query().Get("all")
+	report, err := services.GetLeakReport(all == "true" || all == "1")
+	if err != nil {
+		http.Error(writer, "Ошибка формирования отчета об утечках: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает текущие настройки детектора утечек памяти в формате JSON */
+func GetLeakConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetLeakConfig())
+}
+
+/* Устанавливает окно тренда, интервал замеров и пороги роста для детектора утечек памяти */
+func SetLeakConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := services.GetLeakConfig()
+	if err := json.NewDecoder(request.Body).Decode(&cfg); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.SetLeakConfig(cfg); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"config":  cfg,
+		"message": "Настройки детектора утечек памяти сохранены",
+	})
+}
@@ -0,0 +1,142 @@
+/* Обработчики для процессов под управлением агента */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список процессов под управлением агента с их состоянием */
+func GetManagedProcesses(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	processes, err := services.GetManagedProcesses()
+	if err != nil {
+		http.Error(writer, "Ошибка получения управляемых процессов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(processes); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Запускает процесс под управлением агента, аргументы передаются списком */
+func CreateManagedProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req services.ManagedProcess
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	managed, err := services.CreateManagedProcess(req)
+	if err != nil {
+		http.Error(writer, "Ошибка запуска процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"process": managed,
+		"message": "Процесс запущен под управлением агента",
+	})
+}
+
+/* Читает ID управляемого процесса из пути */
+func managedProcessID(writer http.ResponseWriter, request *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(writer, "Некорректный ID управляемого процесса", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+/* Останавливает управляемый процесс без перезапуска */
+func StopManagedProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := managedProcessID(writer, request)
+	if !ok {
+		return
+	}
+
+	managed, err := services.StopManagedProcess(id)
+	if err != nil {
+		http.Error(writer, "Ошибка остановки процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"process": managed,
+		"message": "Процесс остановлен",
+	})
+}
+
+/* Перезапускает управляемый процесс, в том числе остановленный или завершившийся */
+func RestartManagedProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := managedProcessID(writer, request)
+	if !ok {
+		return
+	}
+
+	managed, err := services.RestartManagedProcess(id)
+	if err != nil {
+		http.Error(writer, "Ошибка перезапуска процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"process": managed,
+		"message": "Процесс перезапущен",
+	})
+}
+
+/* Останавливает управляемый процесс и удаляет его из списка */
+func RemoveManagedProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Метод не разрешён. Используйте DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := managedProcessID(writer, request)
+	if !ok {
+		return
+	}
+
+	if err := services.RemoveManagedProcess(id); err != nil {
+		http.Error(writer, "Ошибка удаления процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Процесс остановлен и удален из списка управляемых",
+	})
+}
@@ -0,0 +1,178 @@
+/* Обработчики для управления каналами уведомлений об алертах */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список каналов уведомлений в формате JSON */
+func GetNotificationChannels(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channels, err := services.GetNotificationChannels()
+	if err != nil {
+		http.Error(writer, "Ошибка получения каналов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(channels); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Создаёт новый канал уведомлений: webhook, email, slack, telegram или script */
+func CreateNotificationChannel(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := services.NotificationChannel{Enabled: true, IsDefault: true}
+	if err := json.NewDecoder(request.Body).Decode(&channel); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := services.CreateNotificationChannel(channel)
+	if err != nil {
+		http.Error(writer, "Ошибка создания канала: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Канал уведомлений создан",
+	})
+}
+
+/* Обновляет канал уведомлений по его ID, канал передаётся целиком */
+func UpdateNotificationChannel(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var channel services.NotificationChannel
+	if err := json.NewDecoder(request.Body).Decode(&channel); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if channel.ID <= 0 {
+		http.Error(writer, "Некорректный ID канала", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.UpdateNotificationChannel(channel); err != nil {
+		http.Error(writer, "Ошибка обновления канала: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Канал уведомлений обновлён",
+	})
+}
+
+/* Удаляет канал уведомлений по его ID */
+func DeleteNotificationChannel(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteNotificationChannel(req.ID); err != nil {
+		http.Error(writer, "Ошибка удаления канала: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Канал уведомлений удалён",
+	})
+}
+
+/* Отправляет тестовое уведомление в канал и возвращает результат доставки */
+func TestNotificationChannel(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.TestNotificationChannel(req.ID); err != nil {
+		http.Error(writer, "Ошибка отправки тестового уведомления: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Тестовое уведомление отправлено",
+	})
+}
+
+/* Возвращает журнал доставки уведомлений с фильтрацией по alertId, channelId и лимиту записей */
+func GetNotificationDeliveries(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	alertID, _ := strconv.ParseInt(query.Get("alertId"), 10, 64)
+	channelID, _ := strconv.ParseInt(query.Get("channelId"), 10, 64)
+
+	limit := 100
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	deliveries, err := services.GetNotificationDeliveries(alertID, channelID, limit)
+	if err != nil {
+		http.Error(writer, "Ошибка получения журнала доставки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(deliveries); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+/* Обработчики для чтения вывода процессов, запущенных агентом */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает последние строки вывода процесса по PID, параметр lines задает их количество */
+func GetProcessLogs(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	pid, err := strconv.ParseInt(query.Get("pid"), 10, 32)
+	if err != nil || pid <= 0 {
+		http.Error(writer, "Некорректный PID", http.StatusBadRequest)
+		return
+	}
+	lines, _ := strconv.Atoi(query.Get("lines"))
+
+	tail, err := services.GetProcessLogTail(int32(pid), lines)
+	if err != nil {
+		http.Error(writer, "Ошибка чтения лога процесса: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tail == nil {
+		http.Error(writer, "Лог процесса не найден: процесс не запускался агентом или лог удален", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(tail); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,145 @@
+/* Обработчики для отправки сигналов процессам, мягкого завершения и завершения групп процессов */
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Запрос на отправку сигнала процессу */
+type ProcessSignalRequest struct {
+	PID    int32  `json:"pid"`
+	Signal string `json:"signal"` // Например "SIGTERM", "hup" или "STOP"
+}
+
+/* Запрос на мягкое завершение процесса */
+type GracefulStopRequest struct {
+	PID      int32 `json:"pid"`
+	GraceSec int   `json:"graceSec"` // Сколько ждать после SIGTERM перед SIGKILL, по умолчанию 10
+}
+
+/* Возвращает список сигналов, поддерживаемых на этой платформе */
+func GetProcessSignals(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"signals": services.ProcessSignalNames(),
+	})
+}
+
+/* Отправляет процессу сигнал: SIGTERM, SIGINT, SIGHUP, SIGSTOP, SIGCONT, SIGUSR1, SIGUSR2 и другие */
+func SendProcessSignal(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ProcessSignalRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+	if req.Signal == "" {
+		http.Error(writer, "Поле 'signal' обязательно", http.StatusBadRequest)
+		return
+	}
+
+	signal, err := services.SendProcessSignal(req.PID, req.Signal)
+	if err != nil {
+		http.Error(writer, "Ошибка отправки сигнала: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Процессу %d отправлен сигнал %s", req.PID, signal)
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"pid":     req.PID,
+		"signal":  signal,
+		"message": "Сигнал отправлен",
+	})
+}
+
+/* Мягко завершает процесс: SIGTERM, ожидание graceSec секунд и SIGKILL, если процесс не завершился */
+func GracefulStopProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GracefulStopRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	result, err := services.GracefulStopProcess(req.PID, req.GraceSec)
+	if err != nil {
+		http.Error(writer, "Ошибка завершения процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Мягкое завершение процесса %d: %s", req.PID, result.Outcome)
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": result.Outcome != "running",
+		"result":  result,
+		"message": result.Message,
+	})
+}
+
+/* Запрос на завершение дерева, группы или сессии процесса */
+type KillProcessGroupRequest struct {
+	PID    int32  `json:"pid"`
+	Scope  string `json:"scope"`  // tree, pgroup или session, по умолчанию tree
+	Signal string `json:"signal"` // По умолчанию SIGKILL
+	DryRun bool   `json:"dryRun"` // Только показать процессы, которые будут завершены
+}
+
+/*
+Завершает процесс вместе с потомками, группой процессов или сессией
+
+	С dryRun возвращает список затронутых процессов без отправки сигналов. PID 1, сам агент и потоки ядра не завершаются
+*/
+func KillProcessGroup(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req KillProcessGroupRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := services.KillProcessGroup(req.PID, req.Scope, req.Signal, req.DryRun)
+	if err != nil {
+		http.Error(writer, "Ошибка завершения процессов: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := "Сигнал отправлен процессам"
+	if plan.DryRun {
+		message = "Предварительный просмотр, сигналы не отправлены"
+	} else {
+		log.Printf("Завершение %s процесса %d сигналом %s: процессов %d, ошибок %d", plan.Scope, plan.RootPID, plan.Signal, len(plan.Targets), plan.Failed)
+		if plan.Failed > 0 {
+			message = "Сигнал отправлен не всем процессам"
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": plan.Failed == 0,
+		"plan":    plan,
+		"message": message,
+	})
+}
@@ -0,0 +1,64 @@
+/* Обработчики для получения дерева процессов */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+	"github.com/RZhurakovskiy/agent/server/services"
+	"github.com/RZhurakovskiy/agent/server/ws"
+)
+
+/*
+Возвращает дерево процессов с суммарными CPU и RSS поддеревьев и количеством потомков
+
+	Параметр root возвращает только поддерево указанного процесса. Если мониторинг включен,
+	дерево строится по кэшу процессов, иначе процессы собираются заново
+*/
+func GetProcessTree(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rootPID int32
+	if value := request.URL.Query().Get("root"); value != "" {
+		pid, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || pid <= 0 {
+			http.Error(writer, "Некорректный PID в параметре 'root'", http.StatusBadRequest)
+			return
+		}
+		rootPID = int32(pid)
+	}
+
+	procs := ws.GetCachedProcesses()
+	if !ws.GetMonitoringEnabled() || len(procs) == 0 {
+		var err error
+		procs, err = getmetrics.UsageProcess(nil)
+		if err != nil {
+			http.Error(writer, "Ошибка получения списка процессов: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	roots := services.BuildProcessTree(procs)
+	var result interface{} = roots
+	if rootPID != 0 {
+		node := services.FindProcessTreeNode(roots, rootPID)
+		if node == nil {
+			http.Error(writer, "Процесс не найден", http.StatusNotFound)
+			return
+		}
+		result = node
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
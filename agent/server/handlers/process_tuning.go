@@ -0,0 +1,183 @@
+/* Обработчики для изменения приоритета, привязки к CPU и класса ввода-вывода процессов */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Запрос на изменение настроек процесса по PID или всех процессов с подходящим именем */
+type TuneProcessRequest struct {
+	PID  int32  `json:"pid"`
+	Name string `json:"name"` // Шаблон имени, поддерживаются * и ?
+	services.ProcessTuning
+	Persist bool   `json:"persist"` // Сохранить как правило для новых процессов с этим именем
+	Actor   string `json:"actor"`
+}
+
+/*
+Изменяет nice, привязку к CPU и класс ввода-вывода процесса и возвращает предыдущие и новые значения
+
+	С persist настройки сохраняются как правило: шаблон name или имя процесса pid
+*/
+func TuneProcess(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TuneProcessRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	results, err := services.TuneProcesses(req.PID, req.Name, req.ProcessTuning, req.Actor)
+	if err != nil {
+		http.Error(writer, "Ошибка изменения настроек процесса: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	success := true
+	for _, result := range results {
+		for _, change := range result.Changes {
+			if change.Error != "" {
+				success = false
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"success": success,
+		"results": results,
+		"message": "Настройки процесса изменены",
+	}
+	if !success {
+		response["message"] = "Не все настройки удалось изменить"
+	}
+
+	if req.Persist {
+		matchName := req.Name
+		if matchName == "" {
+			matchName = results[0].Name
+		}
+		id, err := services.CreateProcessTuningRule(services.ProcessTuningRule{MatchName: matchName, ProcessTuning: req.ProcessTuning})
+		if err != nil {
+			http.Error(writer, "Ошибка сохранения правила: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["ruleId"] = id
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+/* Возвращает журнал изменений настроек процессов, параметр pid отбирает записи одного процесса */
+func GetProcessTuningAudit(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	pid, _ := strconv.ParseInt(query.Get("pid"), 10, 32)
+
+	limit := 100
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	entries, err := services.GetProcessTuningAudit(int32(pid), limit)
+	if err != nil {
+		http.Error(writer, "Ошибка получения журнала настроек процессов: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает список правил настроек процессов */
+func GetProcessTuningRules(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := services.GetProcessTuningRules()
+	if err != nil {
+		http.Error(writer, "Ошибка получения правил: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rules); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Создает правило, которое применяет настройки к новым процессам с подходящим именем */
+func CreateProcessTuningRule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule services.ProcessTuningRule
+	if err := json.NewDecoder(request.Body).Decode(&rule); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := services.CreateProcessTuningRule(rule)
+	if err != nil {
+		http.Error(writer, "Ошибка создания правила: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Правило создано",
+	})
+}
+
+/* Удаляет правило настроек процессов по ID из пути */
+func DeleteProcessTuningRule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Метод не разрешён. Используйте DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(writer, "Некорректный ID правила", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteProcessTuningRule(id); err != nil {
+		http.Error(writer, "Ошибка удаления правила: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Правило удалено",
+	})
+}
@@ -12,14 +12,22 @@ import (
 	"github.com/RZhurakovskiy/agent/server/ws"
 )
 
-/* Структура для запроса на начало записи процессов */
+/*
+Структура для запроса на начало записи
+
+	mode processes (по умолчанию) записывает процессы по criteria, без criteria используются оба порога cpuThreshold и ramThreshold.
+	mode snapshot записывает полные снимки системы каждые intervalSec секунд
+*/
 type StartRecordingRequest struct {
-	CPUThreshold float64 `json:"cpuThreshold"`
-	RAMThreshold float64 `json:"ramThreshold"`
-	Duration     int     `json:"duration"`
+	Mode         string                      `json:"mode"`
+	CPUThreshold float64                     `json:"cpuThreshold"`
+	RAMThreshold float64                     `json:"ramThreshold"`
+	Criteria     *services.RecordingCriteria `json:"criteria"`
+	IntervalSec  int                         `json:"intervalSec"`
+	Duration     int                         `json:"duration"`
 }
 
-/* Запускает новую сессию записи процессов с указанными порогами cpu и ram */
+/* Запускает новую сессию записи процессов с указанными условиями отбора или порогами cpu и ram либо сессию записи снимков системы */
 func StartRecording(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
 		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
@@ -37,12 +45,30 @@ func StartRecording(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	if req.CPUThreshold <= 0 || req.RAMThreshold <= 0 || req.Duration <= 0 {
+	if req.Mode == "snapshot" {
+		startSnapshotRecording(writer, req)
+		return
+	}
+	if req.Mode != "" && req.Mode != "processes" {
+		http.Error(writer, "Некорректный режим записи: "+req.Mode, http.StatusBadRequest)
+		return
+	}
+
+	if req.Duration <= 0 || (req.Criteria == nil && (req.CPUThreshold <= 0 || req.RAMThreshold <= 0)) {
 		http.Error(writer, "Некорректные параметры", http.StatusBadRequest)
 		return
 	}
 
-	sessionID, err := services.StartRecording(req.CPUThreshold, req.RAMThreshold, req.Duration)
+	criteria := services.RecordingCriteria{Mode: "all", CPUPercent: &req.CPUThreshold, MemoryPercent: &req.RAMThreshold}
+	if req.Criteria != nil {
+		criteria = *req.Criteria
+	}
+	if err := criteria.Validate(); err != nil {
+		http.Error(writer, "Некорректные условия записи: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionID, err := services.StartRecording(criteria, req.Duration)
 	fmt.Println(sessionID)
 	if err != nil {
 		http.Error(writer, "Ошибка запуска записи: "+err.Error(), http.StatusInternalServerError)
@@ -57,6 +83,30 @@ func StartRecording(writer http.ResponseWriter, request *http.Request) {
 	})
 }
 
+/* Запускает сессию записи полных снимков системы, по умолчанию снимок делается раз в 10 секунд */
+func startSnapshotRecording(writer http.ResponseWriter, req StartRecordingRequest) {
+	if req.Duration <= 0 {
+		http.Error(writer, "Некорректные параметры", http.StatusBadRequest)
+		return
+	}
+	if req.IntervalSec == 0 {
+		req.IntervalSec = 10
+	}
+
+	sessionID, err := services.StartSnapshotRecording(req.IntervalSec, req.Duration)
+	if err != nil {
+		http.Error(writer, "Ошибка запуска записи: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success":   true,
+		"sessionId": sessionID,
+		"message":   "Запись снимков системы запущена",
+	})
+}
+
 /* Останавливает активную сессию записи процессов */
 func StopRecording(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
@@ -76,7 +126,7 @@ func StopRecording(writer http.ResponseWriter, request *http.Request) {
 	})
 }
 
-/* Возвращает статус текущей сессии записи включая пороги и временные метки */
+/* Возвращает статус текущей сессии записи включая пороги, условия отбора и временные метки */
 func GetRecordingStatus(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
@@ -92,8 +142,11 @@ func GetRecordingStatus(writer http.ResponseWriter, request *http.Request) {
 	if session != nil {
 		response["session"] = map[string]interface{}{
 			"id":           session.ID,
+			"mode":         session.Mode,
+			"intervalSec":  session.IntervalSec,
 			"cpuThreshold": session.CPUThreshold,
 			"ramThreshold": session.RAMThreshold,
+			"criteria":     session.Criteria,
 			"duration":     session.Duration,
 			"startedAt":    session.StartedAt.Format(time.RFC3339),
 			"endTime":      session.EndTime.Format(time.RFC3339),
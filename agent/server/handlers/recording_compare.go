@@ -0,0 +1,87 @@
+/* Обработчики для сравнения сессий записи и интервалов времени */
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Сравнивает два окна "до" и "после" по процессам и метрикам хоста
+
+	Окна задаются ID сессий before и after либо интервалами beforeFrom, beforeTo, afterFrom и afterTo
+*/
+func CompareRecordings(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	comparison, ok := compareRecordingWindows(writer, request)
+	if !ok {
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(comparison); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Разбирает окна сравнения из параметров запроса и выполняет сравнение, при ошибке отвечает клиенту */
+func compareRecordingWindows(writer http.ResponseWriter, request *http.Request) (*services.RecordingComparison, bool) {
+	before, err := parseComparisonWindow(request, "before")
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	after, err := parseComparisonWindow(request, "after")
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	comparison, err := services.CompareRecordings(before, after)
+	if err != nil {
+		http.Error(writer, "Ошибка сравнения: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return comparison, true
+}
+
+/* Разбирает окно сравнения: ID сессии в параметре prefix или интервал в параметрах prefixFrom и prefixTo */
+func parseComparisonWindow(request *http.Request, prefix string) (services.ComparisonWindow, error) {
+	query := request.URL.Query()
+	var window services.ComparisonWindow
+
+	if value := query.Get(prefix); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return window, fmt.Errorf("некорректный ID сессии '%s': %s", prefix, value)
+		}
+		window.SessionID = id
+		return window, nil
+	}
+
+	fromParam, toParam := prefix+"From", prefix+"To"
+	if query.Get(fromParam) == "" || query.Get(toParam) == "" {
+		return window, fmt.Errorf("укажите ID сессии '%s' или интервал '%s' и '%s'", prefix, fromParam, toParam)
+	}
+	from, ok := parseQueryTime(query.Get(fromParam))
+	if !ok {
+		return window, fmt.Errorf("некорректный формат даты '%s': %s", fromParam, query.Get(fromParam))
+	}
+	to, ok := parseQueryTime(query.Get(toParam))
+	if !ok {
+		return window, fmt.Errorf("некорректный формат даты '%s': %s", toParam, query.Get(toParam))
+	}
+	window.From, window.To = from, to
+	return window, nil
+}
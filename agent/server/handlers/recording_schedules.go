@@ -0,0 +1,126 @@
+/* Обработчики для управления расписаниями записи */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список расписаний записи со временем следующего запуска и результатом последнего */
+func GetRecordingSchedules(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	schedules, err := services.GetRecordingSchedules()
+	if err != nil {
+		http.Error(writer, "Ошибка получения расписаний записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schedules); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Создаёт расписание записи по выражению cron или на разовый запуск в будущем */
+func CreateRecordingSchedule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var schedule services.RecordingSchedule
+	if err := json.NewDecoder(request.Body).Decode(&schedule); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := services.CreateRecordingSchedule(schedule)
+	if err != nil {
+		http.Error(writer, "Ошибка создания расписания записи: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := services.GetRecordingSchedule(id)
+	if err != nil {
+		http.Error(writer, "Ошибка получения расписания записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success":  true,
+		"id":       id,
+		"schedule": created,
+		"message":  "Расписание записи создано",
+	})
+}
+
+/* Возвращает расписание записи по ID из пути */
+func GetRecordingSchedule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := scheduleIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.GetRecordingSchedule(id)
+	if err != nil {
+		http.Error(writer, "Ошибка получения расписания записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if schedule == nil {
+		http.Error(writer, "Расписание записи не найдено", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(schedule)
+}
+
+/* Отменяет расписание записи по ID из пути, уже запущенная по нему запись не останавливается */
+func DeleteRecordingSchedule(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Метод не разрешён. Используйте DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := scheduleIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteRecordingSchedule(id); err != nil {
+		http.Error(writer, "Ошибка отмены расписания записи: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Расписание записи отменено",
+	})
+}
+
+/* Разбирает ID расписания записи из пути запроса, при ошибке отвечает клиенту */
+func scheduleIDFromPath(writer http.ResponseWriter, request *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(writer, "Некорректный ID расписания записи", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,145 @@
+/* Обработчики для списка, просмотра и удаления сессий записи процессов */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Получает список сессий записи с фильтрацией по status, from, to и лимиту записей
+
+	Возвращает JSON массив сессий со статусом, количеством записей и уникальных процессов
+*/
+func ListRecordings(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	filter := services.RecordingSessionFilter{
+		Status: query.Get("status"),
+		Limit:  100,
+	}
+
+	switch filter.Status {
+	case "", "active", "stopped", "interrupted":
+	default:
+		http.Error(writer, "Некорректный статус: "+filter.Status, http.StatusBadRequest)
+		return
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, ok := parseQueryTime(value)
+		if !ok {
+			http.Error(writer, "Некорректный формат даты '"+param+"': "+value, http.StatusBadRequest)
+			return
+		}
+		*target = parsed
+	}
+
+	sessions, err := services.ListRecordingSessions(filter)
+	if err != nil {
+		http.Error(writer, "Ошибка получения сессий записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sessions); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает сессию записи по ID из пути вместе со сводной статистикой */
+func GetRecording(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := recordingIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+
+	session, err := services.GetRecordingSession(id)
+	if err != nil {
+		http.Error(writer, "Ошибка получения сессии записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(writer, "Сессия записи не найдена", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(session); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Удаляет сессию записи по ID из пути вместе с записанными процессами */
+func DeleteRecording(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Метод не разрешён. Используйте DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := recordingIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+
+	session, err := services.GetRecordingSession(id)
+	if err != nil {
+		http.Error(writer, "Ошибка получения сессии записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(writer, "Сессия записи не найдена", http.StatusNotFound)
+		return
+	}
+
+	if err := services.DeleteRecordingSession(id); err != nil {
+		http.Error(writer, "Ошибка удаления сессии записи: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"deleted": session.RecordCount,
+		"message": "Сессия записи удалена",
+	})
+}
+
+/* Разбирает ID сессии записи из пути запроса, при ошибке отвечает клиенту */
+func recordingIDFromPath(writer http.ResponseWriter, request *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(writer, "Некорректный ID сессии записи", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
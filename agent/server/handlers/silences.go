@@ -0,0 +1,205 @@
+/* Обработчики для управления тишиной алертов и окнами обслуживания */
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список тишин, параметр active=true оставляет только действующие сейчас */
+func GetAlertSilences(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	activeStr := request.URL.Query().Get("active")
+	activeOnly := activeStr == "true" || activeStr == "1"
+
+	silences, err := services.GetAlertSilences(activeOnly)
+	if err != nil {
+		http.Error(writer, "Ошибка получения тишин: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(silences); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Создаёт тишину на интервал времени или окно обслуживания по расписанию */
+func CreateAlertSilence(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	silence := services.AlertSilence{Enabled: true}
+	if err := json.NewDecoder(request.Body).Decode(&silence); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+	silence.CreatedBy = requestActor(request, silence.CreatedBy)
+
+	id, err := services.CreateAlertSilence(silence)
+	if err != nil {
+		http.Error(writer, "Ошибка создания тишины: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Тишина создана",
+	})
+}
+
+/* Обновляет тишину по её ID, тишина передаётся целиком, автор изменения указывается в поле actor */
+func UpdateAlertSilence(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		services.AlertSilence
+		Actor string `json:"actor"`
+	}
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if req.ID <= 0 {
+		http.Error(writer, "Некорректный ID тишины", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.UpdateAlertSilence(req.AlertSilence, requestActor(request, req.Actor)); err != nil {
+		http.Error(writer, "Ошибка обновления тишины: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Тишина обновлена",
+	})
+}
+
+/* Удаляет тишину по её ID */
+func DeleteAlertSilence(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID    int64  `json:"id"`
+		Actor string `json:"actor"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeleteAlertSilence(req.ID, requestActor(request, req.Actor)); err != nil {
+		http.Error(writer, "Ошибка удаления тишины: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Тишина удалена",
+	})
+}
+
+/* Возвращает журнал изменений тишин с фильтрацией по silenceId и лимиту записей */
+func GetAlertSilenceAudit(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	silenceID, _ := strconv.ParseInt(query.Get("silenceId"), 10, 64)
+
+	limit := 100
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	entries, err := services.GetAlertSilenceAudit(silenceID, limit)
+	if err != nil {
+		http.Error(writer, "Ошибка получения журнала тишин: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Откладывает алерт на указанное количество секунд */
+func SnoozeAlert(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID          int64  `json:"id"`
+		DurationSec int    `json:"durationSec"`
+		Actor       string `json:"actor"`
+		Comment     string `json:"comment"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	silenceID, err := services.SnoozeAlert(req.ID, req.DurationSec, requestActor(request, req.Actor), req.Comment)
+	if err != nil {
+		http.Error(writer, "Ошибка откладывания алерта: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success":   true,
+		"silenceId": silenceID,
+		"message":   "Алерт отложен",
+	})
+}
+
+/* Определяет автора действия: значение из запроса, заголовок X-User или адрес клиента */
+func requestActor(request *http.Request, value string) string {
+	if value != "" {
+		return value
+	}
+	if user := request.Header.Get("X-User"); user != "" {
+		return user
+	}
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		return host
+	}
+	return request.RemoteAddr
+}
@@ -0,0 +1,137 @@
+/* Обработчики для снимков системы и бортового самописца */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/* Возвращает список снимков сессии записи по ID из пути без их содержимого */
+func GetRecordingSnapshots(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := recordingIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+
+	snapshots, err := services.GetRecordingSnapshots(id)
+	if err != nil {
+		http.Error(writer, "Ошибка получения снимков: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshots); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает полный снимок системы: процессы, загрузку хоста, сетевые интерфейсы и соединения */
+func GetRecordingSnapshot(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := recordingIDFromPath(writer, request)
+	if !ok {
+		return
+	}
+	snapshotID, err := strconv.ParseInt(request.PathValue("snapshotId"), 10, 64)
+	if err != nil || snapshotID <= 0 {
+		http.Error(writer, "Некорректный ID снимка", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := services.GetRecordingSnapshot(id, snapshotID)
+	if err != nil {
+		http.Error(writer, "Ошибка получения снимка: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if snapshot == nil {
+		http.Error(writer, "Снимок не найден", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(snapshot)
+}
+
+/* Возвращает текущие настройки бортового самописца в формате JSON */
+func GetFlightRecorderConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetFlightRecorderConfig())
+}
+
+/* Включает или выключает бортовой самописец, задает интервал снимков, размер буфера и сохранение по алертам */
+func SetFlightRecorderConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := services.GetFlightRecorderConfig()
+	if err := json.NewDecoder(request.Body).Decode(&cfg); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.SetFlightRecorderConfig(cfg); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"config":  cfg,
+		"message": "Настройки бортового самописца сохранены",
+	})
+}
+
+/* Возвращает состояние буфера бортового самописца */
+func GetFlightRecorderStatus(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetFlightRecorderStatus())
+}
+
+/* Сохраняет текущий буфер бортового самописца в новую сессию записи */
+func DumpFlightRecorder(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID, err := services.DumpFlightRecorder(0)
+	if err != nil {
+		http.Error(writer, "Ошибка сохранения бортового самописца: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success":   true,
+		"sessionId": sessionID,
+		"message":   "Буфер бортового самописца сохранен",
+	})
+}
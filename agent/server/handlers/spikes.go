@@ -0,0 +1,126 @@
+/* Обработчики для работы со всплесками потребления ресурсов процессами */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+)
+
+/*
+Получает список всплесков с фильтрацией по pid, name, reason, from, to, active и лимиту записей
+
+	Возвращает JSON массив со всплесками
+*/
+func GetSpikes(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	filter := services.SpikeFilter{
+		Name:   query.Get("name"),
+		Reason: query.Get("reason"),
+		Limit:  100,
+	}
+
+	if pidStr := query.Get("pid"); pidStr != "" {
+		pid, err := strconv.ParseInt(pidStr, 10, 32)
+		if err != nil || pid <= 0 {
+			http.Error(writer, "Некорректный pid", http.StatusBadRequest)
+			return
+		}
+		filter.PID = int32(pid)
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			filter.Limit = parsed
+		}
+	}
+
+	if activeStr := query.Get("active"); activeStr == "true" || activeStr == "1" {
+		filter.ActiveOnly = true
+	}
+
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, ok := parseQueryTime(value)
+		if !ok {
+			http.Error(writer, "Некорректный формат даты '"+param+"': "+value, http.StatusBadRequest)
+			return
+		}
+		*target = parsed
+	}
+
+	spikes, err := services.GetSpikes(filter)
+	if err != nil {
+		http.Error(writer, "Ошибка получения всплесков: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(spikes); err != nil {
+		http.Error(writer, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+}
+
+/* Возвращает текущие настройки детектора всплесков в формате JSON */
+func GetSpikeConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Метод не разрешён. Используйте GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(services.GetSpikeConfig())
+}
+
+/* Устанавливает пороги, допустимое отклонение и минимальную длительность для детектора всплесков */
+func SetSpikeConfig(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Метод не разрешён. Используйте POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := services.GetSpikeConfig()
+	if err := json.NewDecoder(request.Body).Decode(&cfg); err != nil {
+		http.Error(writer, "Ошибка парсинга запроса", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.SetSpikeConfig(cfg); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"success": true,
+		"config":  cfg,
+		"message": "Настройки детектора всплесков сохранены",
+	})
+}
+
+/* Разбирает дату из параметра запроса в одном из поддерживаемых форматов */
+func parseQueryTime(value string) (time.Time, bool) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.Local(), true
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
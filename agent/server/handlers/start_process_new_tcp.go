@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/RZhurakovskiy/agent/server/models"
@@ -20,7 +21,36 @@ func StartProcess(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	result, err := services.StartProcess(req.Command, req.Args, req.Cwd)
+	if req.RestartPolicy != "" {
+		managed, err := services.CreateManagedProcess(services.ManagedProcess{
+			Name:          req.Name,
+			Command:       req.Command,
+			Args:          req.Args,
+			Cwd:           req.Cwd,
+			RestartPolicy: req.RestartPolicy,
+			MaxRestarts:   req.MaxRestarts,
+			BackoffSec:    req.BackoffSec,
+
+			ProcessLaunchOptions: req.ProcessLaunchOptions,
+		})
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(models.StartProcessResponse{
+			PID:       managed.PID,
+			Command:   req.Command,
+			Args:      req.Args,
+			Cwd:       req.Cwd,
+			Msg:       fmt.Sprintf("Процесс запущен под управлением агента (ID=%d, PID=%d, политика перезапуска %s)", managed.ID, managed.PID, managed.RestartPolicy),
+			ManagedID: managed.ID,
+		})
+		return
+	}
+
+	result, err := services.StartProcess(req.Command, req.Args, req.Cwd, req.ProcessLaunchOptions)
 	if err != nil {
 		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
@@ -7,9 +7,11 @@ func CorsMiddleware(next http.Handler) http.Handler {
 
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-User, Last-Event-ID")
+
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,14 @@
+/* Модели данных для заполненности файловых систем */
+package models
+
+/* Структура с заполненностью одной файловой системы по объему и inode */
+type DiskUsageStat struct {
+	Device      string `json:"device"`
+	Mountpoint  string `json:"mountpoint"`
+	Fstype      string `json:"fstype"`
+	TotalBytes  uint64 `json:"totalBytes"`
+	UsedBytes   uint64 `json:"usedBytes"`
+	FreeBytes   uint64 `json:"freeBytes"` // Доступно непривилегированным процессам, без зарезервированных для root блоков
+	InodesTotal uint64 `json:"inodesTotal"`
+	InodesUsed  uint64 `json:"inodesUsed"`
+}
@@ -1,6 +1,12 @@
 /* Модели данных для процессов и метрик системы */
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 /* Структура для хранения информации о системном процессе */
 type ProcessInfo struct {
 	PID           int32    `json:"pid"`
@@ -14,7 +20,10 @@ type ProcessInfo struct {
 	CPUPercent    float64  `json:"cpuPercent"`
 	MemoryPercent float64  `json:"memoryPercent"`
 	MemoryRSS     uint64   `json:"memoryRss"`
+	MemoryVMS     uint64   `json:"memoryVms"`
 	Ports         []uint32 `json:"ports"`
+
+	Spike *ProcessSpike `json:"spike,omitempty"`
 }
 
 /* Структура для ответа с метриками использования CPU */
@@ -60,19 +69,64 @@ type ListeningPort struct {
 	RemoteAddr string `json:"remoteAddr"`
 }
 
+/*
+Аргументы запускаемого процесса
+
+	В JSON принимается массив строк или строка, которая разбивается по пробелам. Аргументы с пробелами передаются только массивом
+*/
+type ProcessArgs []string
+
+/* Разбирает аргументы из массива строк или строки */
+func (a *ProcessArgs) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*a = list
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("args должен быть строкой или массивом строк")
+	}
+	*a = strings.Fields(str)
+	return nil
+}
+
+/*
+Параметры запуска процесса
+
+	env дополняет окружение агента, user и group задают непривилегированного пользователя и группу (только если агент
+	запущен от root), rlimits - ограничения ресурсов (имя ресурса и значение), maxRuntimeSec - время, после которого
+	процесс останавливается, stdin - данные, которые передаются процессу на стандартный ввод
+*/
+type ProcessLaunchOptions struct {
+	Env           map[string]string `json:"env,omitempty"`
+	User          string            `json:"user,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Rlimits       map[string]uint64 `json:"rlimits,omitempty"`
+	MaxRuntimeSec int               `json:"maxRuntimeSec,omitempty"`
+	Stdin         string            `json:"stdin,omitempty"`
+}
+
 /* Структура для запроса на запуск нового процесса */
 type StartProcessRequest struct {
-	Command   string `json:"command"`
-	Args      string `json:"args"`
-	Cwd       string `json:"cwd"`
-	Timestamp string `json:"timestamp"`
+	Command       string      `json:"command"`
+	Args          ProcessArgs `json:"args"`
+	Cwd           string      `json:"cwd"`
+	Timestamp     string      `json:"timestamp"`
+	Name          string      `json:"name"`
+	RestartPolicy string      `json:"restartPolicy"` // Если задана, процесс запускается под управлением агента
+	MaxRestarts   int         `json:"maxRestarts"`
+	BackoffSec    int         `json:"backoffSec"`
+
+	ProcessLaunchOptions
 }
 
 /* Структура для ответа с результатом запуска процесса */
 type StartProcessResponse struct {
-	PID     int32  `json:"pid"`
-	Command string `json:"command"`
-	Args    string `json:"args"`
-	Cwd     string `json:"cwd"`
-	Msg     string `json:"msg"`
+	PID       int32       `json:"pid"`
+	Command   string      `json:"command"`
+	Args      ProcessArgs `json:"args"`
+	Cwd       string      `json:"cwd"`
+	Msg       string      `json:"msg"`
+	ManagedID int64       `json:"managedId,omitempty"`
 }
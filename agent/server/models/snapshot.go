@@ -0,0 +1,35 @@
+/* Модели данных для полных снимков состояния системы */
+package models
+
+import "time"
+
+/* Полный снимок системы: метрики хоста, таблица процессов, сетевые интерфейсы и соединения */
+type SystemSnapshot struct {
+	CapturedAt    time.Time            `json:"capturedAt"`
+	CPUPercent    float64              `json:"cpuPercent"`
+	MemoryPercent float64              `json:"memoryPercent"`
+	MemoryUsedMB  uint64               `json:"memoryUsedMB"`
+	MemoryTotalMB uint64               `json:"memoryTotalMB"`
+	Load1         float64              `json:"load1"`
+	Processes     []ProcessInfo        `json:"processes"`
+	Interfaces    []SnapshotInterface  `json:"interfaces"`
+	Connections   []SnapshotConnection `json:"connections"`
+}
+
+/* Счетчики и скорость трафика сетевого интерфейса в снимке */
+type SnapshotInterface struct {
+	Name      string  `json:"name"`
+	BytesSent uint64  `json:"bytesSent"`
+	BytesRecv uint64  `json:"bytesRecv"`
+	RxBps     float64 `json:"rxBps"`
+	TxBps     float64 `json:"txBps"`
+}
+
+/* Сетевое соединение в снимке, имя процесса берется из таблицы процессов по PID */
+type SnapshotConnection struct {
+	Protocol   string `json:"protocol"`
+	LocalAddr  string `json:"localAddr"`
+	RemoteAddr string `json:"remoteAddr"`
+	Status     string `json:"status"`
+	PID        int32  `json:"pid"`
+}
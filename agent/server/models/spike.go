@@ -0,0 +1,12 @@
+/* Модели данных для всплесков потребления ресурсов процессами */
+package models
+
+/* Структура с информацией об активном всплеске процесса для потока процессов */
+type ProcessSpike struct {
+	ID          int64   `json:"id"`
+	Reason      string  `json:"reason"`
+	StartedAt   string  `json:"startedAt"`
+	DurationSec int64   `json:"durationSec"`
+	PeakCPU     float64 `json:"peakCpu"`
+	PeakRSS     uint64  `json:"peakRss"`
+}
@@ -0,0 +1,161 @@
+/* Сервисы для журнала событий алертов и их рассылки подписчикам в реальном времени */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+/* Структура для хранения события алерта: срабатывания, снятия или подтверждения */
+type AlertEvent struct {
+	ID        int64     `json:"id"` // Возрастающий номер события, по нему клиенты продолжают поток после переподключения
+	CreatedAt time.Time `json:"createdAt"`
+	Event     string    `json:"event"` // "fired", "resolved" или "acknowledged"
+	AlertID   int64     `json:"alertId"`
+	Alert     *Alert    `json:"alert"` // Состояние алерта в момент события
+}
+
+/* Размер буфера событий одного подписчика, при переполнении подписка закрывается */
+const alertSubscriberBuffer = 64
+
+var (
+	alertSubscribers      = make(map[chan AlertEvent]struct{})
+	alertSubscribersMutex sync.Mutex
+)
+
+/*
+Сохраняет событие алерта в журнал и рассылает его подписчикам
+
+	Запись и рассылка выполняются под одной блокировкой, поэтому подписчики получают события в порядке их ID.
+	Подписчик, который не успевает читать события, отключается и должен переподключиться с последним полученным ID
+*/
+func publishAlertEvent(event string, alertID int64) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	alert, err := GetAlertByID(alertID)
+	if err != nil || alert == nil {
+		return
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+
+	alertSubscribersMutex.Lock()
+	defer alertSubscribersMutex.Unlock()
+
+	now := time.Now().Truncate(time.Second)
+	result, err := db.Exec(
+		"INSERT INTO alert_events (created_at, alert_id, event, payload) VALUES (?, ?, ?, ?)",
+		now.Format("2006-01-02 15:04:05"),
+		alertID,
+		event,
+		string(payload),
+	)
+	if err != nil {
+		log.Printf("Ошибка сохранения события алерта %d: %v", alertID, err)
+		return
+	}
+	id, _ := result.LastInsertId()
+
+	evt := AlertEvent{ID: id, CreatedAt: now, Event: event, AlertID: alertID, Alert: alert}
+	for ch := range alertSubscribers {
+		select {
+		case ch <- evt:
+		default:
+			delete(alertSubscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+/*
+Подписывает на новые события алертов
+
+	Возвращает канал событий и функцию отписки, канал закрывается при отписке или переполнении
+*/
+func SubscribeAlertEvents() (<-chan AlertEvent, func()) {
+	ch := make(chan AlertEvent, alertSubscriberBuffer)
+
+	alertSubscribersMutex.Lock()
+	alertSubscribers[ch] = struct{}{}
+	alertSubscribersMutex.Unlock()
+
+	unsubscribe := func() {
+		alertSubscribersMutex.Lock()
+		defer alertSubscribersMutex.Unlock()
+		if _, ok := alertSubscribers[ch]; ok {
+			delete(alertSubscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+/* Получает события алертов с ID больше afterID в порядке возрастания */
+func GetAlertEventsSince(afterID int64, limit int) ([]AlertEvent, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	query := "SELECT id, created_at, alert_id, event, payload FROM alert_events WHERE id > ? ORDER BY id"
+	args := []interface{}{afterID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AlertEvent{}
+	for rows.Next() {
+		var e AlertEvent
+		var createdAtStr string
+		var payload sql.NullString
+		if err := rows.Scan(&e.ID, &createdAtStr, &e.AlertID, &e.Event, &payload); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			e.CreatedAt = parsed
+		}
+		if payload.Valid {
+			var alert Alert
+			if err := json.Unmarshal([]byte(payload.String), &alert); err == nil {
+				e.Alert = &alert
+			}
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+/*
+Возвращает пропущенные события и подписку на новые без потерь между ними
+
+	Подписка оформляется до чтения журнала, поэтому события из подписки с ID не больше последнего пропущенного нужно отбрасывать
+*/
+func ResumeAlertEvents(afterID int64) ([]AlertEvent, <-chan AlertEvent, func(), error) {
+	ch, unsubscribe := SubscribeAlertEvents()
+	if afterID <= 0 {
+		return nil, ch, unsubscribe, nil
+	}
+
+	missed, err := GetAlertEventsSince(afterID, 0)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, nil, err
+	}
+	return missed, ch, unsubscribe, nil
+}
@@ -0,0 +1,440 @@
+/* Сервисы для поиска алертов, массовых операций над ними, заметок и статистики */
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Колонки таблицы alerts в порядке, который ожидает scanAlert */
+const alertColumns = "id, created_at, type, threshold, current_value, message, acknowledged, rule_id, severity, status, started_at, resolved_at, pid, process_name, silenced, silence_id, acknowledged_by, acknowledged_at, archived"
+
+/* Параметры поиска алертов, пустые поля не ограничивают выборку */
+type AlertFilter struct {
+	Types              []string  `json:"types"`
+	Severities         []string  `json:"severities"`
+	Status             string    `json:"status"` // "firing" или "resolved"
+	From               time.Time `json:"from"`
+	To                 time.Time `json:"to"`
+	PID                int32     `json:"pid"`
+	ProcessName        string    `json:"processName"` // Подстрока имени процесса
+	Search             string    `json:"search"`      // Подстрока текста сообщения
+	UnacknowledgedOnly bool      `json:"unacknowledgedOnly"`
+	Archived           string    `json:"archived"` // "" - без архивных, "only" - только архивные, "all" - все
+	Cursor             int64     `json:"-"`        // ID последнего алерта предыдущей страницы
+	Limit              int       `json:"-"`
+}
+
+/* Структура для хранения заметки к алерту */
+type AlertNote struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	AlertID   int64     `json:"alertId"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+}
+
+/* Количество алертов за день по одному типу */
+type AlertDayCount struct {
+	Day   string `json:"day"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+/* Сводная статистика алертов для дашбордов */
+type AlertStats struct {
+	Total          int             `json:"total"`
+	Firing         int             `json:"firing"`
+	Unacknowledged int             `json:"unacknowledged"`
+	ByType         map[string]int  `json:"byType"`
+	BySeverity     map[string]int  `json:"bySeverity"`
+	ByDay          []AlertDayCount `json:"byDay"`
+}
+
+/* Проверяет значения статуса и архивности в фильтре алертов */
+func (f AlertFilter) Validate() error {
+	switch f.Status {
+	case "", "firing", "resolved":
+	default:
+		return fmt.Errorf("status должен быть firing или resolved")
+	}
+	switch f.Archived {
+	case "", "only", "all":
+	default:
+		return fmt.Errorf("archived должен быть only или all")
+	}
+	return nil
+}
+
+/* Строит условие WHERE и аргументы запроса по фильтру алертов */
+func buildAlertWhere(filter AlertFilter) (string, []interface{}) {
+	where := " WHERE 1=1"
+	var args []interface{}
+
+	if len(filter.Types) > 0 {
+		where += " AND type IN (" + sqlPlaceholders(len(filter.Types)) + ")"
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	if len(filter.Severities) > 0 {
+		where += " AND severity IN (" + sqlPlaceholders(len(filter.Severities)) + ")"
+		for _, s := range filter.Severities {
+			args = append(args, s)
+		}
+	}
+	if filter.Status != "" {
+		where += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, filter.From.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !filter.To.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, filter.To.Local().Format("2006-01-02 15:04:05"))
+	}
+	if filter.PID > 0 {
+		where += " AND pid = ?"
+		args = append(args, filter.PID)
+	}
+	if filter.ProcessName != "" {
+		where += " AND process_name LIKE ?"
+		args = append(args, "%"+filter.ProcessName+"%")
+	}
+	if filter.Search != "" {
+		where += " AND message LIKE ?"
+		args = append(args, "%"+filter.Search+"%")
+	}
+	if filter.UnacknowledgedOnly {
+		where += " AND acknowledged = 0"
+	}
+	switch filter.Archived {
+	case "only":
+		where += " AND archived = 1"
+	case "all":
+	default:
+		where += " AND COALESCE(archived, 0) = 0"
+	}
+	if filter.Cursor > 0 {
+		where += " AND id < ?"
+		args = append(args, filter.Cursor)
+	}
+
+	return where, args
+}
+
+/* Возвращает строку плейсхолдеров "?, ?, ?" для условия IN */
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+/*
+Ищет алерты по фильтру, от новых к старым
+
+	Возвращает страницу алертов и курсор следующей страницы, нулевой курсор означает что страница последняя
+*/
+func QueryAlerts(filter AlertFilter) ([]Alert, int64, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, 0, nil
+	}
+
+	where, args := buildAlertWhere(filter)
+	query := "SELECT " + alertColumns + " FROM alerts" + where + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit+1)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor int64
+	if filter.Limit > 0 && len(alerts) > filter.Limit {
+		alerts = alerts[:filter.Limit]
+		nextCursor = alerts[len(alerts)-1].ID
+	}
+	return alerts, nextCursor, nil
+}
+
+/* Получает ID всех алертов, подходящих под фильтр, без учёта курсора и лимита */
+func selectAlertIDs(filter AlertFilter) ([]int64, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	filter.Cursor = 0
+	where, args := buildAlertWhere(filter)
+	rows, err := db.Query("SELECT id FROM alerts"+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+/*
+Подтверждает алерты по списку ID с указанием, кто их подтвердил
+
+	Уже подтвержденные алерты пропускаются, возвращает количество подтвержденных алертов
+*/
+func AcknowledgeAlerts(ids []int64, acknowledgedBy string) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, nil
+	}
+
+	var by interface{}
+	if acknowledgedBy != "" {
+		by = acknowledgedBy
+	}
+
+	var count int64
+	for _, id := range ids {
+		result, err := db.Exec(
+			"UPDATE alerts SET acknowledged = 1, acknowledged_by = ?, acknowledged_at = ? WHERE id = ? AND acknowledged = 0",
+			by,
+			time.Now().Format("2006-01-02 15:04:05"),
+			id,
+		)
+		if err != nil {
+			return count, err
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			count++
+			publishAlertEvent("acknowledged", id)
+		}
+	}
+	return count, nil
+}
+
+/* Подтверждает все неподтвержденные алерты, подходящие под фильтр */
+func AcknowledgeAlertsByFilter(filter AlertFilter, acknowledgedBy string) (int64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	filter.UnacknowledgedOnly = true
+	ids, err := selectAlertIDs(filter)
+	if err != nil {
+		return 0, err
+	}
+	return AcknowledgeAlerts(ids, acknowledgedBy)
+}
+
+/* Строит условие выбора снятых алертов по списку ID или по дате создания раньше before */
+func buildAlertSelection(ids []int64, before time.Time) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if len(ids) > 0 {
+		conditions = append(conditions, "id IN ("+sqlPlaceholders(len(ids))+")")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	if !before.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, before.Format("2006-01-02 15:04:05"))
+	}
+	if len(conditions) == 0 {
+		return "", nil, fmt.Errorf("нужно указать ID алертов или возраст")
+	}
+
+	/* Сработавшие алерты не трогаются, иначе движок правил потеряет их состояние */
+	return " WHERE status != 'firing' AND (" + strings.Join(conditions, " OR ") + ")", args, nil
+}
+
+/* Переносит снятые алерты в архив по списку ID или по дате создания, возвращает количество перенесённых */
+func ArchiveAlerts(ids []int64, before time.Time) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+
+	where, args, err := buildAlertSelection(ids, before)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec("UPDATE alerts SET archived = 1"+where+" AND COALESCE(archived, 0) = 0", args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+/* Удаляет снятые алерты вместе с заметками, событиями и доставками уведомлений по списку ID или по дате создания, возвращает количество удалённых */
+func DeleteAlerts(ids []int64, before time.Time) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+
+	where, args, err := buildAlertSelection(ids, before)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"alert_notes", "alert_events", "notification_deliveries"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE alert_id IN (SELECT id FROM alerts"+where+")", args...); err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.Exec("DELETE FROM alerts"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+/* Добавляет заметку к алерту и возвращает её ID */
+func AddAlertNote(alertID int64, author, text string) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if strings.TrimSpace(text) == "" {
+		return 0, fmt.Errorf("текст заметки не может быть пустым")
+	}
+
+	alert, err := GetAlertByID(alertID)
+	if err != nil {
+		return 0, err
+	}
+	if alert == nil {
+		return 0, fmt.Errorf("алерт с ID %d не найден", alertID)
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO alert_notes (created_at, alert_id, author, text) VALUES (?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		alertID,
+		author,
+		text,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+/* Получает заметки к алерту в порядке добавления */
+func GetAlertNotes(alertID int64) ([]AlertNote, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT id, created_at, alert_id, author, text FROM alert_notes WHERE alert_id = ? ORDER BY id", alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []AlertNote{}
+	for rows.Next() {
+		var n AlertNote
+		var createdAtStr string
+		if err := rows.Scan(&n.ID, &createdAtStr, &n.AlertID, &n.Author, &n.Text); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			n.CreatedAt = parsed
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+/* Считает алерты по фильтру: итоги, разбивку по типам, важности и по дням */
+func GetAlertStats(filter AlertFilter) (*AlertStats, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	filter.Cursor = 0
+	where, args := buildAlertWhere(filter)
+	stats := &AlertStats{
+		ByType:     make(map[string]int),
+		BySeverity: make(map[string]int),
+		ByDay:      []AlertDayCount{},
+	}
+
+	err := db.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(status = 'firing'), 0), COALESCE(SUM(acknowledged = 0), 0) FROM alerts"+where,
+		args...,
+	).Scan(&stats.Total, &stats.Firing, &stats.Unacknowledged)
+	if err != nil {
+		return nil, err
+	}
+
+	for column, target := range map[string]map[string]int{"type": stats.ByType, "COALESCE(severity, 'warning')": stats.BySeverity} {
+		rows, err := db.Query("SELECT "+column+", COUNT(*) FROM alerts"+where+" GROUP BY 1", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var count int
+			if err := rows.Scan(&key, &count); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			target[key] = count
+		}
+		rows.Close()
+	}
+
+	rows, err := db.Query("SELECT substr(created_at, 1, 10), type, COUNT(*) FROM alerts"+where+" GROUP BY 1, 2 ORDER BY 1, 2", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c AlertDayCount
+		if err := rows.Scan(&c.Day, &c.Type, &c.Count); err != nil {
+			return nil, err
+		}
+		stats.ByDay = append(stats.ByDay, c)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  AlertFilter
+		wantErr bool
+	}{
+		{"пустой фильтр", AlertFilter{}, false},
+		{"firing", AlertFilter{Status: "firing"}, false},
+		{"resolved и все архивные", AlertFilter{Status: "resolved", Archived: "all"}, false},
+		{"неизвестный статус", AlertFilter{Status: "open"}, true},
+		{"неизвестное значение archived", AlertFilter{Archived: "yes"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeleteAlertsRemovesRelatedRows(t *testing.T) {
+	db := setupTestDB(t)
+
+	created := time.Now().Add(-time.Hour).Format("2006-01-02 15:04:05")
+	insert := func(status string) int64 {
+		result, err := db.Exec(
+			"INSERT INTO alerts (created_at, type, threshold, current_value, message, status) VALUES (?, 'cpu', 90, 95, 'test', ?)",
+			created, status,
+		)
+		if err != nil {
+			t.Fatalf("не удалось добавить алерт: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		for _, query := range []string{
+			"INSERT INTO alert_notes (alert_id, author, text) VALUES (?, 'test', 'note')",
+			"INSERT INTO alert_events (alert_id, event) VALUES (?, 'fired')",
+			"INSERT INTO notification_deliveries (alert_id, channel_id, event, attempts, status) VALUES (?, 1, 'fired', 1, 'sent')",
+		} {
+			if _, err := db.Exec(query, id); err != nil {
+				t.Fatalf("не удалось добавить связанную запись: %v", err)
+			}
+		}
+		return id
+	}
+	resolved := insert("resolved")
+	firing := insert("firing")
+
+	count, err := DeleteAlerts(nil, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteAlerts: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("удалено алертов: %d, ожидался 1", count)
+	}
+
+	for _, table := range []string{"alert_notes", "alert_events", "notification_deliveries"} {
+		for id, want := range map[int64]int{resolved: 0, firing: 1} {
+			var got int
+			if err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE alert_id = ?", id).Scan(&got); err != nil {
+				t.Fatalf("%s: %v", table, err)
+			}
+			if got != want {
+				t.Errorf("%s для алерта %d: %d записей, ожидалось %d", table, id, got, want)
+			}
+		}
+	}
+}
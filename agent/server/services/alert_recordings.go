@@ -0,0 +1,241 @@
+/* Сервисы для автоматического запуска записи при срабатывании алерта */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/*
+Запись, которую правило алерта запускает при срабатывании
+
+	В сессию попадают и процессы за preTriggerSec секунд до срабатывания: для режима processes из буфера
+	последних списков процессов, для режима snapshot из буфера бортового самописца, если он включен
+*/
+type AlertRuleRecording struct {
+	Mode          string             `json:"mode"` // processes или snapshot, как у сессий записи
+	Criteria      *RecordingCriteria `json:"criteria,omitempty"`
+	IntervalSec   int                `json:"intervalSec,omitempty"`
+	DurationSec   int                `json:"durationSec"`
+	PreTriggerSec int                `json:"preTriggerSec"`
+}
+
+/* Список процессов из кэша мониторинга на момент обновления */
+type recentProcessFrame struct {
+	at    time.Time
+	procs []models.ProcessInfo
+}
+
+/* Дольше этого процессы до срабатывания не хранятся */
+const maxPreTriggerSec = 30 * 60
+
+var (
+	recentProcessFrames []recentProcessFrame
+	recentProcessMutex  sync.Mutex
+)
+
+/* Проверяет настройки записи по алерту и подставляет значения по умолчанию */
+func (r *AlertRuleRecording) Validate() error {
+	if r.DurationSec <= 0 {
+		return fmt.Errorf("durationSec должен быть больше 0")
+	}
+	if r.PreTriggerSec < 0 || r.PreTriggerSec > maxPreTriggerSec {
+		return fmt.Errorf("preTriggerSec должен быть от 0 до %d секунд", maxPreTriggerSec)
+	}
+
+	switch r.Mode {
+	case "", "processes":
+		r.Mode = "processes"
+		r.IntervalSec = 0
+		if r.Criteria == nil {
+			return fmt.Errorf("для записи процессов нужны условия criteria")
+		}
+		return r.Criteria.Validate()
+	case "snapshot":
+		r.Criteria = nil
+		if r.IntervalSec == 0 {
+			r.IntervalSec = 10
+		}
+		return validateSnapshotInterval(r.IntervalSec, r.DurationSec)
+	default:
+		return fmt.Errorf("режим записи должен быть processes или snapshot")
+	}
+}
+
+/* Сериализует настройки записи правила для хранения в базе данных */
+func encodeAlertRuleRecording(recording *AlertRuleRecording) interface{} {
+	if recording == nil {
+		return nil
+	}
+	data, err := json.Marshal(recording)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+/* Разбирает настройки записи правила из базы данных */
+func decodeAlertRuleRecording(value string) *AlertRuleRecording {
+	if value == "" {
+		return nil
+	}
+	var recording AlertRuleRecording
+	if json.Unmarshal([]byte(value), &recording) != nil {
+		return nil
+	}
+	return &recording
+}
+
+/* Возвращает, сколько секунд процессов нужно хранить для записи до срабатывания включенных правил */
+func alertRecordingPreTrigger() time.Duration {
+	var longest int
+	for _, rule := range cachedAlertRules() {
+		if rule.Enabled && rule.Recording != nil && rule.Recording.Mode == "processes" && rule.Recording.PreTriggerSec > longest {
+			longest = rule.Recording.PreTriggerSec
+		}
+	}
+	return time.Duration(longest) * time.Second
+}
+
+/*
+Сохраняет список процессов из очередного обновления кэша для записи до срабатывания алерта
+
+	Списки хранятся, только пока есть включенные правила с записью процессов, и не дольше их preTriggerSec
+*/
+func BufferRecentProcesses(procs []models.ProcessInfo) {
+	retention := alertRecordingPreTrigger()
+	now := time.Now()
+
+	recentProcessMutex.Lock()
+	defer recentProcessMutex.Unlock()
+
+	if retention == 0 {
+		recentProcessFrames = nil
+		return
+	}
+
+	cutoff := now.Add(-retention)
+	drop := 0
+	for drop < len(recentProcessFrames) && recentProcessFrames[drop].at.Before(cutoff) {
+		drop++
+	}
+	recentProcessFrames = append(recentProcessFrames[drop:], recentProcessFrame{at: now, procs: procs})
+}
+
+/*
+Запускает запись по сработавшему алерту, если она настроена в его правиле
+
+	Заглушенные алерты запись не запускают. Если уже идет другая запись, запуск пропускается,
+	одновременно может идти только одна запись
+*/
+func startRecordingOnAlert(alertID int64) {
+	alert, err := GetAlertByID(alertID)
+	if err != nil || alert == nil || alert.Silenced || alert.RuleID == nil {
+		return
+	}
+	rule, ok := findAlertRule(*alert.RuleID)
+	if !ok || rule.Recording == nil {
+		return
+	}
+	recording := *rule.Recording
+
+	if active, _ := GetRecordingStatus(); active {
+		log.Printf("Запись по алерту %d не запущена: уже идет другая запись", alertID)
+		return
+	}
+
+	triggeredAt := time.Now()
+	var sessionID int64
+	if recording.Mode == "snapshot" {
+		sessionID, err = StartSnapshotRecording(recording.IntervalSec, recording.DurationSec)
+	} else if recording.Criteria != nil {
+		sessionID, err = StartRecording(*recording.Criteria, recording.DurationSec)
+	} else {
+		err = fmt.Errorf("у правила нет условий записи")
+	}
+	if err != nil {
+		log.Printf("Ошибка запуска записи по алерту %d: %v", alertID, err)
+		return
+	}
+
+	db := GetDB()
+	db.Exec("UPDATE recording_sessions SET alert_id = ? WHERE id = ?", alertID, sessionID)
+
+	since := triggeredAt.Add(-time.Duration(recording.PreTriggerSec) * time.Second)
+	if recording.PreTriggerSec > 0 {
+		if recording.Mode == "snapshot" {
+			err = savePreTriggerSnapshots(db, sessionID, since)
+		} else {
+			err = savePreTriggerProcesses(db, sessionID, *recording.Criteria, since)
+		}
+		if err != nil {
+			log.Printf("Ошибка сохранения данных до срабатывания алерта %d: %v", alertID, err)
+		}
+	}
+	log.Printf("Запись %d запущена по алерту %d", sessionID, alertID)
+}
+
+/* Сохраняет в сессию процессы из буфера начиная с since, которые подходят под условия записи */
+func savePreTriggerProcesses(db *sql.DB, sessionID int64, criteria RecordingCriteria, since time.Time) error {
+	recentProcessMutex.Lock()
+	frames := append([]recentProcessFrame(nil), recentProcessFrames...)
+	recentProcessMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, frame := range frames {
+		if frame.at.Before(since) {
+			continue
+		}
+		for i := range frame.procs {
+			p := &frame.procs[i]
+			if !criteria.Matches(p) {
+				continue
+			}
+			_, err := tx.Exec(
+				"INSERT INTO recorded_processes (session_id, recorded_at, pid, name, cpu_percent, memory_percent, memory_rss, exe, cmdline, username) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				sessionID,
+				frame.at.Format("2006-01-02 15:04:05"),
+				p.PID,
+				p.Name,
+				p.CPUPercent,
+				p.MemoryPercent,
+				p.MemoryRSS,
+				p.Exe,
+				p.Cmdline,
+				p.Username,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+/* Сохраняет в сессию снимки бортового самописца начиная с since */
+func savePreTriggerSnapshots(db *sql.DB, sessionID int64, since time.Time) error {
+	flightRecorderMutex.Lock()
+	var snapshots []encodedSnapshot
+	for _, s := range flightRecorderBuffer {
+		if !s.capturedAt.Before(since) {
+			snapshots = append(snapshots, s)
+		}
+	}
+	flightRecorderMutex.Unlock()
+
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return saveSnapshots(db, sessionID, snapshots)
+}
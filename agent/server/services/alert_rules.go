@@ -0,0 +1,328 @@
+/* Сервисы для хранения и валидации правил алертов */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+)
+
+/* Структура для хранения декларативного правила алерта */
+type AlertRule struct {
+	ID             int64               `json:"id"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	Name           string              `json:"name"`
+	Metric         string              `json:"metric"`         // Метрика, например "cpu" или "memory"
+	Comparator     string              `json:"comparator"`     // ">", ">=", "<" или "<="
+	Threshold      float64             `json:"threshold"`      // Порог срабатывания
+	ForSec         int                 `json:"forSec"`         // Сколько секунд условие должно держаться до срабатывания
+	ClearThreshold *float64            `json:"clearThreshold"` // Порог снятия алерта, по умолчанию равен Threshold
+	Severity       string              `json:"severity"`       // "info", "warning" или "critical"
+	Enabled        bool                `json:"enabled"`
+	Scope          string              `json:"scope"`      // "host" для метрик хоста или "process" для процессов
+	MatchName      string              `json:"matchName"`  // Шаблон имени процесса, например "postgres" или "node*"
+	MatchUser      string              `json:"matchUser"`  // Имя пользователя владельца процесса
+	Aggregate      string              `json:"aggregate"`  // "each" - отдельный алерт на процесс, "sum" или "max" по всем совпавшим
+	ChannelIDs     []int64             `json:"channelIds"` // Каналы уведомлений, пустой список - каналы по умолчанию
+	Recording      *AlertRuleRecording `json:"recording"`  // Запись, которая запускается при срабатывании, nil - не запускать
+}
+
+/* Подписи метрик хоста, по которым можно создавать правила */
+var alertHostMetrics = map[string]string{
+	"cpu":    "CPU",
+	"memory": "Память",
+}
+
+/* Подписи метрик процессов, по которым можно создавать правила */
+var alertProcessMetrics = map[string]string{
+	"process_cpu":    "CPU процесса",
+	"process_memory": "Память процесса",
+	"process_rss":    "RSS процесса",
+	"process_count":  "Количество процессов",
+}
+
+var alertSeverities = map[string]bool{
+	"info":     true,
+	"warning":  true,
+	"critical": true,
+}
+
+var (
+	alertRulesCache      []AlertRule
+	alertRulesLoaded     bool
+	alertRulesGeneration uint64 // Растет при каждом сбросе кэша, чтобы не сохранить правила, прочитанные до изменения
+	alertRulesMutex      sync.RWMutex
+)
+
+/* Проверяет корректность правила и подставляет значения по умолчанию */
+func ValidateAlertRule(rule *AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("поле 'name' обязательно")
+	}
+	if rule.Scope == "" {
+		rule.Scope = "host"
+	}
+	switch rule.Scope {
+	case "host":
+		if _, ok := alertHostMetrics[rule.Metric]; !ok {
+			return fmt.Errorf("неизвестная метрика хоста '%s'", rule.Metric)
+		}
+		rule.MatchName = ""
+		rule.MatchUser = ""
+		rule.Aggregate = ""
+	case "process":
+		if _, ok := alertProcessMetrics[rule.Metric]; !ok {
+			return fmt.Errorf("неизвестная метрика процесса '%s'", rule.Metric)
+		}
+		if _, err := path.Match(rule.MatchName, ""); err != nil {
+			return fmt.Errorf("некорректный шаблон имени '%s'", rule.MatchName)
+		}
+		if rule.Metric == "process_count" {
+			rule.Aggregate = ""
+		} else {
+			if rule.Aggregate == "" {
+				rule.Aggregate = "each"
+			}
+			switch rule.Aggregate {
+			case "each", "sum", "max":
+			default:
+				return fmt.Errorf("неизвестная агрегация '%s'", rule.Aggregate)
+			}
+		}
+	default:
+		return fmt.Errorf("неизвестная область правила '%s'", rule.Scope)
+	}
+	if rule.Comparator == "" {
+		rule.Comparator = ">"
+	}
+	switch rule.Comparator {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("неизвестный оператор сравнения '%s'", rule.Comparator)
+	}
+	if rule.ForSec < 0 {
+		return fmt.Errorf("поле 'forSec' не может быть отрицательным")
+	}
+	if rule.Severity == "" {
+		rule.Severity = "warning"
+	}
+	if !alertSeverities[rule.Severity] {
+		return fmt.Errorf("неизвестная важность '%s'", rule.Severity)
+	}
+	if rule.Recording != nil {
+		if err := rule.Recording.Validate(); err != nil {
+			return fmt.Errorf("запись по алерту: %w", err)
+		}
+	}
+	if rule.ClearThreshold != nil {
+		clear := *rule.ClearThreshold
+		if (rule.Comparator[0] == '>' && clear > rule.Threshold) || (rule.Comparator[0] == '<' && clear < rule.Threshold) {
+			return fmt.Errorf("порог снятия должен находиться по другую сторону от порога срабатывания")
+		}
+	}
+	return nil
+}
+
+/* Создаёт новое правило алерта и возвращает его ID */
+func CreateAlertRule(rule AlertRule) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if err := ValidateAlertRule(&rule); err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO alert_rules (created_at, name, metric, comparator, threshold, for_sec, clear_threshold, severity, enabled, scope, match_name, match_user, aggregate, channel_ids, recording) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		rule.Name,
+		rule.Metric,
+		rule.Comparator,
+		rule.Threshold,
+		rule.ForSec,
+		rule.ClearThreshold,
+		rule.Severity,
+		rule.Enabled,
+		rule.Scope,
+		rule.MatchName,
+		rule.MatchUser,
+		rule.Aggregate,
+		encodeChannelIDs(rule.ChannelIDs),
+		encodeAlertRuleRecording(rule.Recording),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	invalidateAlertRules()
+	return result.LastInsertId()
+}
+
+/* Обновляет существующее правило алерта по его ID */
+func UpdateAlertRule(rule AlertRule) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+	if err := ValidateAlertRule(&rule); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(
+		"UPDATE alert_rules SET name = ?, metric = ?, comparator = ?, threshold = ?, for_sec = ?, clear_threshold = ?, severity = ?, enabled = ?, scope = ?, match_name = ?, match_user = ?, aggregate = ?, channel_ids = ?, recording = ? WHERE id = ?",
+		rule.Name,
+		rule.Metric,
+		rule.Comparator,
+		rule.Threshold,
+		rule.ForSec,
+		rule.ClearThreshold,
+		rule.Severity,
+		rule.Enabled,
+		rule.Scope,
+		rule.MatchName,
+		rule.MatchUser,
+		rule.Aggregate,
+		encodeChannelIDs(rule.ChannelIDs),
+		encodeAlertRuleRecording(rule.Recording),
+		rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("правило с ID %d не найдено", rule.ID)
+	}
+
+	invalidateAlertRules()
+	return nil
+}
+
+/* Удаляет правило алерта по его ID */
+func DeleteAlertRule(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	result, err := db.Exec("DELETE FROM alert_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("правило с ID %d не найдено", id)
+	}
+
+	invalidateAlertRules()
+	return nil
+}
+
+/* Получает список всех правил алертов из базы данных */
+func GetAlertRules() ([]AlertRule, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT id, created_at, name, metric, comparator, threshold, for_sec, clear_threshold, severity, enabled, scope, match_name, match_user, aggregate, channel_ids, recording FROM alert_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		var r AlertRule
+		var createdAtStr string
+		var clearThreshold sql.NullFloat64
+		var enabled int
+		var matchName, matchUser, aggregate, channelIDs, recording sql.NullString
+
+		if err := rows.Scan(&r.ID, &createdAtStr, &r.Name, &r.Metric, &r.Comparator, &r.Threshold, &r.ForSec, &clearThreshold, &r.Severity, &enabled, &r.Scope, &matchName, &matchUser, &aggregate, &channelIDs, &recording); err != nil {
+			return nil, err
+		}
+
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			r.CreatedAt = parsed
+		}
+		if clearThreshold.Valid {
+			value := clearThreshold.Float64
+			r.ClearThreshold = &value
+		}
+		r.Enabled = enabled == 1
+		r.MatchName = matchName.String
+		r.MatchUser = matchUser.String
+		r.Aggregate = aggregate.String
+		r.ChannelIDs = decodeChannelIDs(channelIDs.String)
+		r.Recording = decodeAlertRuleRecording(recording.String)
+
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+/* Сериализует список каналов правила для хранения в базе данных */
+func encodeChannelIDs(ids []int64) interface{} {
+	if len(ids) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+/* Разбирает список каналов правила из базы данных */
+func decodeChannelIDs(value string) []int64 {
+	ids := []int64{}
+	if value != "" {
+		json.Unmarshal([]byte(value), &ids)
+	}
+	return ids
+}
+
+/* Находит правило по ID среди закэшированных */
+func findAlertRule(id int64) (AlertRule, bool) {
+	for _, rule := range cachedAlertRules() {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return AlertRule{}, false
+}
+
+/* Возвращает закэшированные правила, загружая их из базы при первом обращении после изменения */
+func cachedAlertRules() []AlertRule {
+	alertRulesMutex.RLock()
+	if alertRulesLoaded {
+		rules := alertRulesCache
+		alertRulesMutex.RUnlock()
+		return rules
+	}
+	generation := alertRulesGeneration
+	alertRulesMutex.RUnlock()
+
+	rules, err := GetAlertRules()
+	if err != nil || rules == nil {
+		return nil
+	}
+
+	// Если кэш сбросили во время чтения, правила могли устареть: их не кэшируем, следующий вызов прочитает заново
+	alertRulesMutex.Lock()
+	if alertRulesGeneration == generation {
+		alertRulesCache = rules
+		alertRulesLoaded = true
+	}
+	alertRulesMutex.Unlock()
+	return rules
+}
+
+/* Сбрасывает кэш правил после их изменения */
+func invalidateAlertRules() {
+	alertRulesMutex.Lock()
+	alertRulesLoaded = false
+	alertRulesGeneration++
+	alertRulesMutex.Unlock()
+}
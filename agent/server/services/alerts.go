@@ -4,19 +4,33 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 )
 
 /* Структура для хранения информации об алерте */
 type Alert struct {
-	ID           int64     `json:"id"`
-	CreatedAt    time.Time `json:"createdAt"`
-	Type         string    `json:"type"`         // "cpu" или "memory"
-	Threshold    float64   `json:"threshold"`    // Пороговое значение
-	CurrentValue float64   `json:"currentValue"` // Текущее значение
-	Message      string    `json:"message"`
-	Acknowledged bool      `json:"acknowledged"`
+	ID             int64      `json:"id"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	Type           string     `json:"type"`         // "cpu" или "memory"
+	Threshold      float64    `json:"threshold"`    // Пороговое значение
+	CurrentValue   float64    `json:"currentValue"` // Текущее значение
+	Message        string     `json:"message"`
+	Acknowledged   bool       `json:"acknowledged"`
+	RuleID         *int64     `json:"ruleId"`     // Правило, по которому создан алерт, nil для порогов
+	Severity       string     `json:"severity"`   // "info", "warning" или "critical"
+	Status         string     `json:"status"`     // "firing" пока условие держится, затем "resolved"
+	StartedAt      *time.Time `json:"startedAt"`  // Когда условие начало выполняться
+	ResolvedAt     *time.Time `json:"resolvedAt"` // Когда алерт был снят
+	PID            int32      `json:"pid,omitempty"`
+	ProcessName    string     `json:"processName,omitempty"`
+	Silenced       bool       `json:"silenced"`  // Алерт создан во время тишины, уведомления по нему не отправляются
+	SilenceID      *int64     `json:"silenceId"` // Тишина, под действие которой попал алерт
+	AcknowledgedBy string     `json:"acknowledgedBy"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt"`
+	Archived       bool       `json:"archived"` // Архивные алерты не попадают в список по умолчанию
 }
 
 // Сохраняет новый алерт в базу данных с указанным типом, порогом, текущим значением и сообщением
@@ -43,55 +57,81 @@ func SaveAlert(alertType string, threshold, currentValue float64, message string
 	Возвращает массив алертов или ошибку
 */
 func GetAlerts(limit int, unacknowledgedOnly bool) ([]Alert, error) {
-	db := GetDB()
-	if db == nil {
-		return nil, nil
+	alerts, _, err := QueryAlerts(AlertFilter{Limit: limit, UnacknowledgedOnly: unacknowledgedOnly})
+	return alerts, err
+}
+
+/* Читает алерт из строки результата запроса с колонками в порядке alertColumns */
+func scanAlert(rows *sql.Rows) (Alert, error) {
+	var a Alert
+	var createdAtStr string
+	var acknowledged int
+	var ruleID sql.NullInt64
+	var severity, status, startedAtStr, resolvedAtStr, processName, acknowledgedBy, acknowledgedAtStr sql.NullString
+	var pid, silenced, silenceID, archived sql.NullInt64
+
+	if err := rows.Scan(&a.ID, &createdAtStr, &a.Type, &a.Threshold, &a.CurrentValue, &a.Message, &acknowledged, &ruleID, &severity, &status, &startedAtStr, &resolvedAtStr, &pid, &processName, &silenced, &silenceID, &acknowledgedBy, &acknowledgedAtStr, &archived); err != nil {
+		return a, err
 	}
 
-	query := "SELECT id, created_at, type, threshold, current_value, message, acknowledged FROM alerts"
-	if unacknowledgedOnly {
-		query += " WHERE acknowledged = 0"
+	if parsed, ok := parseDBTime(createdAtStr); ok {
+		a.CreatedAt = parsed
+	}
+	a.Acknowledged = acknowledged == 1
+	if ruleID.Valid {
+		id := ruleID.Int64
+		a.RuleID = &id
+	}
+	a.Severity = severity.String
+	a.Status = status.String
+	if parsed, ok := parseDBTime(startedAtStr.String); ok {
+		a.StartedAt = &parsed
 	}
-	query += " ORDER BY created_at DESC"
-	if limit > 0 {
-		query += " LIMIT ?"
+	if parsed, ok := parseDBTime(resolvedAtStr.String); ok {
+		a.ResolvedAt = &parsed
 	}
+	a.PID = int32(pid.Int64)
+	a.ProcessName = processName.String
+	a.Silenced = silenced.Int64 == 1
+	if silenceID.Valid {
+		id := silenceID.Int64
+		a.SilenceID = &id
+	}
+	a.AcknowledgedBy = acknowledgedBy.String
+	if parsed, ok := parseDBTime(acknowledgedAtStr.String); ok {
+		a.AcknowledgedAt = &parsed
+	}
+	a.Archived = archived.Int64 == 1
+
+	return a, nil
+}
 
-	rows, err := db.Query(query, limit)
+/* Получает алерт по его ID, возвращает nil если алерт не найден */
+func GetAlertByID(id int64) (*Alert, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT "+alertColumns+" FROM alerts WHERE id = ?", id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var alerts []Alert
-	for rows.Next() {
-		var a Alert
-		var createdAtStr string
-		var acknowledged int
-
-		if err := rows.Scan(&a.ID, &createdAtStr, &a.Type, &a.Threshold, &a.CurrentValue, &a.Message, &acknowledged); err != nil {
-			return nil, err
-		}
-
-		if parsed, err := time.Parse("2006-01-02 15:04:05", createdAtStr); err == nil {
-			a.CreatedAt = parsed
-		}
-		a.Acknowledged = acknowledged == 1
-
-		alerts = append(alerts, a)
+	if !rows.Next() {
+		return nil, rows.Err()
 	}
-
-	return alerts, nil
+	a, err := scanAlert(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
 }
 
-/* Отмечает алерт как подтвержденный по его ID в базе данных */
+/* Отмечает алерт как подтвержденный по его ID в базе данных и публикует событие подтверждения */
 func AcknowledgeAlert(id int64) error {
-	db := GetDB()
-	if db == nil {
-		return nil
-	}
-
-	_, err := db.Exec("UPDATE alerts SET acknowledged = 1 WHERE id = ?", id)
+	_, err := AcknowledgeAlerts([]int64{id}, "")
 	return err
 }
 
@@ -124,20 +164,274 @@ func GetAlertThresholds() map[string]float64 {
 	}
 }
 
-/* Проверяет текущие значения CPU и памяти и создает алерты если они превышают установленные пороги */
+/*
+Проверяет текущие значения CPU и памяти по порогам и правилам из базы данных
+
+	На один инцидент создаётся один алерт, который снимается когда значение пересекает порог снятия
+*/
 func CheckAlerts(currentCPU, currentMemory float64) {
 	alertMutex.RLock()
 	cpuThreshold := alertCPUThreshold
 	memoryThreshold := alertMemoryThreshold
 	alertMutex.RUnlock()
 
-	if cpuThreshold > 0 && currentCPU > cpuThreshold {
-		message := fmt.Sprintf("Превышен порог CPU: %.2f%% (порог: %.2f%%)", currentCPU, cpuThreshold)
-		SaveAlert("cpu", cpuThreshold, currentCPU, message)
+	values := map[string]float64{
+		"cpu":    currentCPU,
+		"memory": currentMemory,
+	}
+
+	now := time.Now()
+
+	alertEngineMutex.Lock()
+	defer alertEngineMutex.Unlock()
+
+	restoreFiringAlerts()
+	seen := make(map[string]bool)
+
+	if cpuThreshold > 0 {
+		rule := AlertRule{Metric: "cpu", Comparator: ">", Threshold: cpuThreshold, Severity: "warning"}
+		evaluateAlertRule(rule, alertObservation{
+			fingerprint: "host:threshold:cpu",
+			value:       currentCPU,
+			message:     fmt.Sprintf("Превышен порог CPU: %.2f%% (порог: %.2f%%)", currentCPU, cpuThreshold),
+		}, now)
+		seen["host:threshold:cpu"] = true
+	}
+
+	if memoryThreshold > 0 {
+		rule := AlertRule{Metric: "memory", Comparator: ">", Threshold: memoryThreshold, Severity: "warning"}
+		evaluateAlertRule(rule, alertObservation{
+			fingerprint: "host:threshold:memory",
+			value:       currentMemory,
+			message:     fmt.Sprintf("Превышен порог памяти: %.2f%% (порог: %.2f%%)", currentMemory, memoryThreshold),
+		}, now)
+		seen["host:threshold:memory"] = true
+	}
+
+	for _, rule := range cachedAlertRules() {
+		value, ok := values[rule.Metric]
+		if !rule.Enabled || rule.Scope != "host" || !ok {
+			continue
+		}
+		fingerprint := fmt.Sprintf("host:rule:%d", rule.ID)
+		evaluateAlertRule(rule, alertObservation{
+			fingerprint: fingerprint,
+			value:       value,
+			message:     fmt.Sprintf("%s: %s %.2f%% %s %.2f%%", rule.Name, alertHostMetrics[rule.Metric], value, rule.Comparator, rule.Threshold),
+		}, now)
+		seen[fingerprint] = true
+	}
+
+	resolveUnseenAlerts("host:", seen, now)
+}
+
+/* Значение метрики одного источника, которое проверяется правилом */
+type alertObservation struct {
+	fingerprint string // Идентификатор источника, по нему алерты дедуплицируются
+	value       float64
+	message     string
+	pid         int32
+	processName string
+}
+
+/* Состояние проверки правила для одного источника значений */
+type alertState struct {
+	pendingSince time.Time
+	alertID      int64
+}
+
+var (
+	alertStates         = make(map[string]*alertState)
+	alertStatesRestored bool
+	alertEngineMutex    sync.Mutex
+)
+
+/*
+Переводит состояние правила по очередному значению метрики
+
+	Условие должно держаться ForSec секунд до создания алерта, снимается алерт по порогу снятия
+*/
+func evaluateAlertRule(rule AlertRule, obs alertObservation, now time.Time) {
+	fingerprint := obs.fingerprint
+	value := obs.value
+
+	state, ok := alertStates[fingerprint]
+	if !ok {
+		state = &alertState{}
+		alertStates[fingerprint] = state
+	}
+
+	if state.alertID != 0 {
+		clearThreshold := rule.Threshold
+		if rule.ClearThreshold != nil {
+			clearThreshold = *rule.ClearThreshold
+		}
+		if !compareAlertValue(value, rule.Comparator, clearThreshold) {
+			resolveAlert(state.alertID, now)
+			delete(alertStates, fingerprint)
+		}
+		return
+	}
+
+	if !compareAlertValue(value, rule.Comparator, rule.Threshold) {
+		delete(alertStates, fingerprint)
+		return
+	}
+
+	if state.pendingSince.IsZero() {
+		state.pendingSince = now
+	}
+	if now.Sub(state.pendingSince) < time.Duration(rule.ForSec)*time.Second {
+		return
+	}
+
+	silence := findActiveSilence(rule.Metric, obs.processName, now)
+	if silence != nil && silence.Mode == "suppress" {
+		return
+	}
+
+	id, err := fireAlert(rule, obs, state.pendingSince, now, silence)
+	if err != nil {
+		log.Printf("Ошибка сохранения алерта %s: %v", fingerprint, err)
+		return
+	}
+	state.alertID = id
+	handleAlertEvent("fired", id)
+}
+
+/*
+Обрабатывает изменение состояния алерта: публикует событие в поток и рассылает уведомления по каналам правила
+
+	При срабатывании алерта на диск сохраняется буфер бортового самописца, если он включен,
+	и запускается запись, если она настроена в правиле алерта
+*/
+func handleAlertEvent(event string, id int64) {
+	publishAlertEvent(event, id)
+	go notifyAlert(event, id)
+	if event == "fired" {
+		go dumpFlightRecorderOnAlert(id)
+		go startRecordingOnAlert(id)
+	}
+}
+
+/* Снимает алерты источников с указанным префиксом, которые не участвовали в последней проверке */
+func resolveUnseenAlerts(prefix string, seen map[string]bool, now time.Time) {
+	for fingerprint, state := range alertStates {
+		if !strings.HasPrefix(fingerprint, prefix) || seen[fingerprint] {
+			continue
+		}
+		if state.alertID != 0 {
+			resolveAlert(state.alertID, now)
+		}
+		delete(alertStates, fingerprint)
+	}
+}
+
+/* Сравнивает значение метрики с порогом с помощью указанного оператора */
+func compareAlertValue(value float64, comparator string, threshold float64) bool {
+	switch comparator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	}
+	return false
+}
+
+/* Сохраняет сработавший алерт в базу данных и возвращает его ID, silence отмечает алерт как заглушенный */
+func fireAlert(rule AlertRule, obs alertObservation, startedAt, now time.Time, silence *AlertSilence) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
 	}
 
-	if memoryThreshold > 0 && currentMemory > memoryThreshold {
-		message := fmt.Sprintf("Превышен порог памяти: %.2f%% (порог: %.2f%%)", currentMemory, memoryThreshold)
-		SaveAlert("memory", memoryThreshold, currentMemory, message)
+	var ruleID, pid, processName, silenceID interface{}
+	if rule.ID != 0 {
+		ruleID = rule.ID
+	}
+	if obs.pid != 0 {
+		pid = obs.pid
+	}
+	if obs.processName != "" {
+		processName = obs.processName
+	}
+	if silence != nil {
+		silenceID = silence.ID
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO alerts (created_at, type, threshold, current_value, message, acknowledged, rule_id, severity, status, fingerprint, started_at, pid, process_name, silenced, silence_id) VALUES (?, ?, ?, ?, ?, 0, ?, ?, 'firing', ?, ?, ?, ?, ?, ?)",
+		now.Format("2006-01-02 15:04:05"),
+		rule.Metric,
+		rule.Threshold,
+		obs.value,
+		obs.message,
+		ruleID,
+		rule.Severity,
+		obs.fingerprint,
+		startedAt.Format("2006-01-02 15:04:05"),
+		pid,
+		processName,
+		silence != nil,
+		silenceID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+/* Отмечает алерт как снятый с указанием времени снятия */
+func resolveAlert(id int64, now time.Time) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+	_, err := db.Exec(
+		"UPDATE alerts SET status = 'resolved', resolved_at = ? WHERE id = ?",
+		now.Format("2006-01-02 15:04:05"),
+		id,
+	)
+	if err != nil {
+		log.Printf("Ошибка снятия алерта %d: %v", id, err)
+		return
+	}
+	handleAlertEvent("resolved", id)
+}
+
+/* Восстанавливает состояние сработавших алертов после перезапуска агента, чтобы не создавать дубликаты */
+func restoreFiringAlerts() {
+	if alertStatesRestored {
+		return
+	}
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	rows, err := db.Query("SELECT id, fingerprint FROM alerts WHERE status = 'firing' AND fingerprint IS NOT NULL")
+	if err != nil {
+		log.Printf("Ошибка восстановления состояния алертов: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var fingerprint string
+		if err := rows.Scan(&id, &fingerprint); err != nil {
+			log.Printf("Ошибка восстановления состояния алертов: %v", err)
+			return
+		}
+		if existing, ok := alertStates[fingerprint]; ok && existing.alertID != 0 {
+			resolveAlert(id, time.Now())
+			continue
+		}
+		alertStates[fingerprint] = &alertState{alertID: id}
 	}
+	alertStatesRestored = true
 }
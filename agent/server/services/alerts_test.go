@@ -0,0 +1,187 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+/* Очищает состояние движка правил алертов на время теста */
+func resetAlertStates(t *testing.T) {
+	t.Helper()
+	alertEngineMutex.Lock()
+	alertStates = make(map[string]*alertState)
+	alertEngineMutex.Unlock()
+	t.Cleanup(func() {
+		alertEngineMutex.Lock()
+		alertStates = make(map[string]*alertState)
+		alertEngineMutex.Unlock()
+	})
+}
+
+/* Подменяет закэшированные тишины на время теста */
+func withSilences(t *testing.T, silences []AlertSilence) {
+	t.Helper()
+	silencesMutex.Lock()
+	silencesCache, silencesLoaded = silences, true
+	silencesMutex.Unlock()
+	t.Cleanup(invalidateSilences)
+}
+
+func TestEvaluateAlertRule(t *testing.T) {
+	clearAt := func(value float64) *float64 { return &value }
+
+	/* Одно значение метрики через at секунд после начала и ожидаемое состояние после его проверки */
+	type step struct {
+		at          int
+		value       float64
+		wantFiring  bool
+		wantPending bool
+	}
+
+	tests := []struct {
+		name         string
+		rule         AlertRule
+		steps        []step
+		wantAlerts   int // Сколько алертов создано
+		wantResolved int // Сколько из них снято
+	}{
+		{
+			name: "без задержки срабатывает сразу и снимается по порогу",
+			rule: AlertRule{Metric: "cpu", Comparator: ">", Threshold: 90},
+			steps: []step{
+				{0, 95, true, false},
+				{5, 91, true, false},
+				{10, 90, false, false},
+			},
+			wantAlerts:   1,
+			wantResolved: 1,
+		},
+		{
+			name: "срабатывает только после forSec",
+			rule: AlertRule{Metric: "cpu", Comparator: ">", Threshold: 90, ForSec: 10},
+			steps: []step{
+				{0, 95, false, true},
+				{5, 95, false, true},
+				{10, 95, true, false},
+			},
+			wantAlerts: 1,
+		},
+		{
+			name: "падение ниже порога сбрасывает ожидание",
+			rule: AlertRule{Metric: "cpu", Comparator: ">", Threshold: 90, ForSec: 10},
+			steps: []step{
+				{0, 95, false, true},
+				{5, 50, false, false},
+				{10, 95, false, true},
+				{15, 95, false, true},
+				{20, 95, true, false},
+			},
+			wantAlerts: 1,
+		},
+		{
+			name: "гистерезис: снимается только ниже порога снятия",
+			rule: AlertRule{Metric: "memory", Comparator: ">", Threshold: 90, ClearThreshold: clearAt(80)},
+			steps: []step{
+				{0, 95, true, false},
+				{5, 85, true, false},
+				{10, 80, false, false},
+				{15, 85, false, false},
+				{20, 95, true, false},
+			},
+			wantAlerts:   2,
+			wantResolved: 1,
+		},
+		{
+			name: "оператор меньше с порогом снятия выше порога",
+			rule: AlertRule{Metric: "cpu", Comparator: "<", Threshold: 10, ClearThreshold: clearAt(20)},
+			steps: []step{
+				{0, 15, false, false},
+				{5, 5, true, false},
+				{10, 15, true, false},
+				{15, 25, false, false},
+			},
+			wantAlerts:   1,
+			wantResolved: 1,
+		},
+		{
+			name: "нестрогое сравнение на пороге",
+			rule: AlertRule{Metric: "cpu", Comparator: ">=", Threshold: 90},
+			steps: []step{
+				{0, 90, true, false},
+				{5, 89.9, false, false},
+			},
+			wantAlerts:   1,
+			wantResolved: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			resetAlertStates(t)
+			withSilences(t, nil)
+
+			const fingerprint = "test:rule"
+			start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+			for _, s := range tt.steps {
+				alertEngineMutex.Lock()
+				evaluateAlertRule(tt.rule, alertObservation{fingerprint: fingerprint, value: s.value, message: "test"}, start.Add(time.Duration(s.at)*time.Second))
+				state := alertStates[fingerprint]
+				alertEngineMutex.Unlock()
+
+				firing := state != nil && state.alertID != 0
+				pending := state != nil && state.alertID == 0 && !state.pendingSince.IsZero()
+				if firing != s.wantFiring || pending != s.wantPending {
+					t.Fatalf("через %d с при значении %.1f: firing=%v pending=%v, ожидалось firing=%v pending=%v",
+						s.at, s.value, firing, pending, s.wantFiring, s.wantPending)
+				}
+			}
+
+			var alerts, resolved int
+			err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM(status = 'resolved'), 0) FROM alerts WHERE fingerprint = ?", fingerprint).Scan(&alerts, &resolved)
+			if err != nil {
+				t.Fatalf("не удалось прочитать алерты: %v", err)
+			}
+			if alerts != tt.wantAlerts || resolved != tt.wantResolved {
+				t.Fatalf("алертов %d, снято %d, ожидалось %d и %d", alerts, resolved, tt.wantAlerts, tt.wantResolved)
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertRuleSilences(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	ends := start.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		mode         string
+		wantAlerts   int
+		wantSilenced bool
+	}{
+		{"suppress не создает алерт", "suppress", 0, false},
+		{"mark создает заглушенный алерт", "mark", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			resetAlertStates(t)
+			withSilences(t, []AlertSilence{{ID: 1, MatcherType: "all", StartsAt: start, EndsAt: &ends, Mode: tt.mode, Enabled: true}})
+
+			rule := AlertRule{Metric: "cpu", Comparator: ">", Threshold: 90}
+			alertEngineMutex.Lock()
+			evaluateAlertRule(rule, alertObservation{fingerprint: "test:silence", value: 95, message: "test"}, start.Add(time.Minute))
+			alertEngineMutex.Unlock()
+
+			var alerts, silenced int
+			err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM(silenced), 0) FROM alerts").Scan(&alerts, &silenced)
+			if err != nil {
+				t.Fatalf("не удалось прочитать алерты: %v", err)
+			}
+			if alerts != tt.wantAlerts || (silenced > 0) != tt.wantSilenced {
+				t.Fatalf("алертов %d, заглушенных %d, ожидалось %d и заглушен=%v", alerts, silenced, tt.wantAlerts, tt.wantSilenced)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+/* Сервисы для обнаружения аномалий метрик хоста по статистическим базовым линиям */
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+)
+
+/* Снимок метрик хоста, по которому детектор обучается и ищет аномалии */
+type HostSample struct {
+	Time       time.Time `json:"time"`
+	CPU        float64   `json:"cpu"`
+	Memory     float64   `json:"memory"`
+	Load1      float64   `json:"load1"`
+	NetRxBps   float64   `json:"netRxBps"`
+	NetTxBps   float64   `json:"netTxBps"`
+	HasNetwork bool      `json:"-"` // Скорость сети известна только начиная со второго снимка
+}
+
+/* Настройки детектора аномалий */
+type AnomalyConfig struct {
+	Enabled        bool     `json:"enabled"`
+	Method         string   `json:"method"`         // "ewma" - среднее и отклонение, "mad" - медиана и MAD
+	Sensitivity    float64  `json:"sensitivity"`    // Во сколько отклонений значение должно уйти от нормы
+	MinDurationSec int      `json:"minDurationSec"` // Сколько секунд отклонение должно держаться до создания алерта
+	MinSamples     int      `json:"minSamples"`     // Сколько минутных значений нужно в часе до начала проверок
+	Alpha          float64  `json:"alpha"`          // Коэффициент сглаживания EWMA
+	LearningDays   int      `json:"learningDays"`   // За сколько дней истории метрик обучаться при первом запуске, 0 - только по текущим метрикам
+	Metrics        []string `json:"metrics"`        // Проверяемые метрики: cpu, memory, load, net_rx, net_tx
+	Severity       string   `json:"severity"`
+}
+
+/* Базовая линия одной метрики для одного часа суток */
+type AnomalyBaseline struct {
+	Metric  string  `json:"metric"`
+	Hour    int     `json:"hour"`
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stdDev"`
+	Median  float64 `json:"median"`
+	MAD     float64 `json:"mad"`
+}
+
+/* Накопленная статистика метрики за один час суток */
+type anomalyBucket struct {
+	Samples  int       `json:"samples"`
+	Mean     float64   `json:"mean"`
+	Variance float64   `json:"variance"`
+	Recent   []float64 `json:"recent"` // Последние минутные значения для медианы и MAD
+}
+
+/* Подписи метрик и минимальный разброс, ниже которого отклонение не считается значимым */
+var anomalyMetrics = map[string]struct {
+	label     string
+	minSpread float64
+}{
+	"cpu":    {"CPU", 2},
+	"memory": {"Память", 1},
+	"load":   {"Load average", 0.2},
+	"net_rx": {"Входящий трафик", 64 * 1024},
+	"net_tx": {"Исходящий трафик", 64 * 1024},
+}
+
+/* Сколько последних минутных значений хранится в каждом часе */
+const anomalyRecentSize = 240
+
+var (
+	anomalyConfig = AnomalyConfig{
+		Method:         "ewma",
+		Sensitivity:    3,
+		MinDurationSec: 120,
+		MinSamples:     30,
+		Alpha:          0.05,
+		LearningDays:   0,
+		Metrics:        []string{"cpu", "memory", "load", "net_rx", "net_tx"},
+		Severity:       "warning",
+	}
+	anomalyBaselines = make(map[string]*[24]anomalyBucket)
+	anomalyLoaded    bool
+	anomalyMinute    time.Time
+	anomalySums      = make(map[string]float64)
+	anomalyCounts    = make(map[string]int)
+	lastHostSample   HostSample
+	lastNetRx        uint64
+	lastNetTx        uint64
+	anomalyMutex     sync.Mutex
+)
+
+/* Устанавливает настройки детектора аномалий после проверки значений */
+func SetAnomalyConfig(cfg AnomalyConfig) error {
+	if cfg.Method != "ewma" && cfg.Method != "mad" {
+		return fmt.Errorf("неизвестный метод '%s', допустимы ewma и mad", cfg.Method)
+	}
+	if cfg.Sensitivity <= 0 {
+		return fmt.Errorf("sensitivity должен быть больше нуля")
+	}
+	if cfg.MinDurationSec < 0 || cfg.MinSamples < 0 || cfg.LearningDays < 0 {
+		return fmt.Errorf("параметры детектора не могут быть отрицательными")
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha >= 1 {
+		return fmt.Errorf("alpha должен быть в интервале (0, 1)")
+	}
+	if !alertSeverities[cfg.Severity] {
+		return fmt.Errorf("неизвестная важность '%s'", cfg.Severity)
+	}
+	for _, metric := range cfg.Metrics {
+		if _, ok := anomalyMetrics[metric]; !ok {
+			return fmt.Errorf("неизвестная метрика '%s'", metric)
+		}
+	}
+
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+	anomalyConfig = cfg
+	return nil
+}
+
+/* Возвращает текущие настройки детектора аномалий */
+func GetAnomalyConfig() AnomalyConfig {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+	return anomalyConfig
+}
+
+/* Возвращает последний снимок метрик хоста, false если снимков ещё не было */
+func LatestHostSample() (HostSample, bool) {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+	return lastHostSample, !lastHostSample.Time.IsZero()
+}
+
+/*
+Снимает нагрузку и сетевой трафик, обучает базовые линии и проверяет метрики хоста на аномалии
+
+	Вызывается вместе с CheckAlerts при каждом обновлении метрик памяти.
+	Базовые линии ведутся отдельно для каждого часа суток по минутным средним значениям
+*/
+func CheckAnomalies(currentCPU, currentMemory float64) {
+	now := time.Now()
+	sample := takeHostSample(currentCPU, currentMemory, now)
+
+	anomalyMutex.Lock()
+	cfg := anomalyConfig
+	lastHostSample = sample
+	if !cfg.Enabled {
+		anomalyMutex.Unlock()
+		return
+	}
+	if !anomalyLoaded {
+		loadAnomalyBaselines(cfg)
+		anomalyLoaded = true
+	}
+
+	values := hostSampleValues(sample)
+	observations := make([]alertObservation, 0, len(cfg.Metrics))
+	scores := make(map[string]float64, len(cfg.Metrics))
+	for _, metric := range cfg.Metrics {
+		value, ok := values[metric]
+		if !ok {
+			continue
+		}
+		bucket := anomalyBucketFor(metric, now.Hour())
+		if bucket.Samples < cfg.MinSamples {
+			continue
+		}
+		center, spread := bucket.baseline(cfg.Method, anomalyMetrics[metric].minSpread)
+		score := (value - center) / spread
+		scores[metric] = math.Abs(score)
+		observations = append(observations, alertObservation{
+			fingerprint: "anomaly:" + metric,
+			value:       math.Abs(score),
+			message:     describeAnomaly(metric, value, center, spread, score, now.Hour(), cfg.Method),
+		})
+	}
+	accumulateAnomalyMinute(cfg, values, scores, now)
+	anomalyMutex.Unlock()
+
+	rule := AlertRule{
+		Metric:     "anomaly",
+		Comparator: ">",
+		Threshold:  cfg.Sensitivity,
+		ForSec:     cfg.MinDurationSec,
+		Severity:   cfg.Severity,
+	}
+	clearThreshold := cfg.Sensitivity * 0.75
+	rule.ClearThreshold = &clearThreshold
+
+	alertEngineMutex.Lock()
+	defer alertEngineMutex.Unlock()
+
+	restoreFiringAlerts()
+	seen := make(map[string]bool, len(observations))
+	for _, obs := range observations {
+		evaluateAlertRule(rule, obs, now)
+		seen[obs.fingerprint] = true
+	}
+	resolveUnseenAlerts("anomaly:", seen, now)
+}
+
+/* Снимает load average и скорость сетевого трафика с момента предыдущего снимка */
+func takeHostSample(currentCPU, currentMemory float64, now time.Time) HostSample {
+	sample := HostSample{Time: now, CPU: currentCPU, Memory: currentMemory}
+
+	if load1, err := getmetrics.LoadAverage(); err == nil {
+		sample.Load1 = load1
+	}
+
+	rx, tx, err := getmetrics.NetworkTotals()
+	if err != nil {
+		return sample
+	}
+
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+	prev := lastHostSample
+	if !prev.Time.IsZero() && lastNetRx+lastNetTx > 0 && rx >= lastNetRx && tx >= lastNetTx {
+		if elapsed := now.Sub(prev.Time).Seconds(); elapsed > 0 {
+			sample.NetRxBps = float64(rx-lastNetRx) / elapsed
+			sample.NetTxBps = float64(tx-lastNetTx) / elapsed
+			sample.HasNetwork = true
+		}
+	}
+	lastNetRx = rx
+	lastNetTx = tx
+	return sample
+}
+
+/* Раскладывает снимок хоста по именам метрик детектора */
+func hostSampleValues(sample HostSample) map[string]float64 {
+	values := map[string]float64{
+		"cpu":    sample.CPU,
+		"memory": sample.Memory,
+		"load":   sample.Load1,
+	}
+	if sample.HasNetwork {
+		values["net_rx"] = sample.NetRxBps
+		values["net_tx"] = sample.NetTxBps
+	}
+	return values
+}
+
+/*
+Копит значения текущей минуты и по её окончании передаёт средние в базовые линии
+
+	Минуты с аномальными значениями пропускаются, чтобы инцидент не становился новой нормой
+*/
+func accumulateAnomalyMinute(cfg AnomalyConfig, values, scores map[string]float64, now time.Time) {
+	minute := now.Truncate(time.Minute)
+	if !anomalyMinute.IsZero() && !minute.Equal(anomalyMinute) {
+		hour := anomalyMinute.Hour()
+		for metric, sum := range anomalySums {
+			if anomalyCounts[metric] == 0 {
+				continue
+			}
+			anomalyBucketFor(metric, hour).add(sum/float64(anomalyCounts[metric]), cfg.Alpha)
+		}
+		anomalySums = make(map[string]float64)
+		anomalyCounts = make(map[string]int)
+
+		if minute.Minute()%15 == 0 {
+			saveAnomalyBaselines()
+		}
+	}
+	anomalyMinute = minute
+
+	for metric, value := range values {
+		if scores[metric] > cfg.Sensitivity {
+			continue
+		}
+		anomalySums[metric] += value
+		anomalyCounts[metric]++
+	}
+}
+
+/* Возвращает статистику метрики для часа суток, создавая её при первом обращении */
+func anomalyBucketFor(metric string, hour int) *anomalyBucket {
+	buckets, ok := anomalyBaselines[metric]
+	if !ok {
+		buckets = &[24]anomalyBucket{}
+		anomalyBaselines[metric] = buckets
+	}
+	return &buckets[hour]
+}
+
+/* Добавляет минутное значение в статистику часа: обновляет EWMA среднего и дисперсии и список последних значений */
+func (b *anomalyBucket) add(value, alpha float64) {
+	if b.Samples == 0 {
+		b.Mean = value
+		b.Variance = 0
+	} else {
+		diff := value - b.Mean
+		increment := alpha * diff
+		b.Mean += increment
+		b.Variance = (1 - alpha) * (b.Variance + diff*increment)
+	}
+	b.Samples++
+
+	b.Recent = append(b.Recent, value)
+	if len(b.Recent) > anomalyRecentSize {
+		b.Recent = b.Recent[len(b.Recent)-anomalyRecentSize:]
+	}
+}
+
+/* Возвращает норму и разброс метрики по выбранному методу, разброс не меньше minSpread */
+func (b *anomalyBucket) baseline(method string, minSpread float64) (float64, float64) {
+	center, spread := b.Mean, math.Sqrt(b.Variance)
+	if method == "mad" && len(b.Recent) > 0 {
+		median, mad := medianMAD(b.Recent)
+		/* Коэффициент 1.4826 приводит MAD к стандартному отклонению нормального распределения */
+		center, spread = median, 1.4826*mad
+	}
+	return center, math.Max(spread, minSpread)
+}
+
+/* Считает медиану и медианное абсолютное отклонение значений */
+func medianMAD(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := sortedMedian(sorted)
+
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, sortedMedian(deviations)
+}
+
+/* Возвращает медиану отсортированного списка */
+func sortedMedian(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+/* Формирует объяснение аномалии для сообщения алерта */
+func describeAnomaly(metric string, value, center, spread, score float64, hour int, method string) string {
+	direction := "выше"
+	if score < 0 {
+		direction = "ниже"
+	}
+	norm := "среднее"
+	if method == "mad" {
+		norm = "медиана"
+	}
+	return fmt.Sprintf("Аномалия %s: %s при норме %s ± %s для %02d:00-%02d:00 (%s), отклонение %.1fσ %s нормы",
+		anomalyMetrics[metric].label,
+		formatAnomalyValue(metric, value),
+		formatAnomalyValue(metric, center),
+		formatAnomalyValue(metric, spread),
+		hour, (hour+1)%24, norm, math.Abs(score), direction)
+}
+
+/* Форматирует значение метрики детектора с единицами измерения */
+func formatAnomalyValue(metric string, value float64) string {
+	switch metric {
+	case "cpu", "memory":
+		return fmt.Sprintf("%.2f%%", value)
+	case "net_rx", "net_tx":
+		return fmt.Sprintf("%.1f КБ/с", value/1024)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+/*
+Загружает сохранённые базовые линии, а если их нет и LearningDays больше нуля - обучается по истории метрик
+
+	Из истории берутся минутные средние за последние LearningDays дней.
+	metrics_history пишется только во время записи и только когда хост подходит под ее критерии,
+	поэтому такая норма смещена к высокой нагрузке и может скрывать настоящие аномалии.
+	По умолчанию обучение идет только по текущим метрикам, которые проверяются при каждом обновлении
+*/
+func loadAnomalyBaselines(cfg AnomalyConfig) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	rows, err := db.Query("SELECT metric, hour, samples, mean, variance, recent FROM anomaly_baselines")
+	if err != nil {
+		log.Printf("Ошибка загрузки базовых линий аномалий: %v", err)
+		return
+	}
+	loaded := 0
+	for rows.Next() {
+		var metric, recent string
+		var hour int
+		var b anomalyBucket
+		if err := rows.Scan(&metric, &hour, &b.Samples, &b.Mean, &b.Variance, &recent); err != nil {
+			log.Printf("Ошибка загрузки базовых линий аномалий: %v", err)
+			break
+		}
+		if hour < 0 || hour > 23 {
+			continue
+		}
+		json.Unmarshal([]byte(recent), &b.Recent)
+		*anomalyBucketFor(metric, hour) = b
+		loaded++
+	}
+	rows.Close()
+
+	if loaded > 0 || cfg.LearningDays == 0 {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -cfg.LearningDays)
+	history, err := db.Query(`
+		SELECT substr(timestamp, 12, 2), AVG(cpu_percent), AVG(memory_percent), AVG(load1), AVG(net_rx_bps), AVG(net_tx_bps)
+		FROM metrics_history
+		WHERE timestamp >= ?
+		GROUP BY substr(timestamp, 1, 16)
+		ORDER BY substr(timestamp, 1, 16)
+	`, since.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		log.Printf("Ошибка обучения детектора аномалий по истории: %v", err)
+		return
+	}
+	defer history.Close()
+
+	for history.Next() {
+		var hourStr string
+		var cpu, memory float64
+		var load1, netRx, netTx *float64
+		if err := history.Scan(&hourStr, &cpu, &memory, &load1, &netRx, &netTx); err != nil {
+			log.Printf("Ошибка обучения детектора аномалий по истории: %v", err)
+			return
+		}
+		var hour int
+		if _, err := fmt.Sscanf(hourStr, "%d", &hour); err != nil || hour < 0 || hour > 23 {
+			continue
+		}
+		anomalyBucketFor("cpu", hour).add(cpu, cfg.Alpha)
+		anomalyBucketFor("memory", hour).add(memory, cfg.Alpha)
+		for metric, value := range map[string]*float64{"load": load1, "net_rx": netRx, "net_tx": netTx} {
+			if value != nil {
+				anomalyBucketFor(metric, hour).add(*value, cfg.Alpha)
+			}
+		}
+	}
+}
+
+/* Сохраняет базовые линии в базу данных, чтобы обучение не начиналось заново после перезапуска */
+func saveAnomalyBaselines() {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for metric, buckets := range anomalyBaselines {
+		for hour, b := range buckets {
+			if b.Samples == 0 {
+				continue
+			}
+			recent, _ := json.Marshal(b.Recent)
+			_, err := db.Exec(
+				"INSERT OR REPLACE INTO anomaly_baselines (metric, hour, samples, mean, variance, recent, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				metric, hour, b.Samples, b.Mean, b.Variance, string(recent), now,
+			)
+			if err != nil {
+				log.Printf("Ошибка сохранения базовой линии %s: %v", metric, err)
+				return
+			}
+		}
+	}
+}
+
+/* Возвращает базовые линии всех метрик по часам суток */
+func GetAnomalyBaselines() []AnomalyBaseline {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	if !anomalyLoaded && anomalyConfig.Enabled {
+		loadAnomalyBaselines(anomalyConfig)
+		anomalyLoaded = true
+	}
+
+	metrics := make([]string, 0, len(anomalyBaselines))
+	for metric := range anomalyBaselines {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	baselines := []AnomalyBaseline{}
+	for _, metric := range metrics {
+		for hour, b := range anomalyBaselines[metric] {
+			if b.Samples == 0 {
+				continue
+			}
+			median, mad := medianMAD(b.Recent)
+			baselines = append(baselines, AnomalyBaseline{
+				Metric:  metric,
+				Hour:    hour,
+				Samples: b.Samples,
+				Mean:    b.Mean,
+				StdDev:  math.Sqrt(b.Variance),
+				Median:  median,
+				MAD:     mad,
+			})
+		}
+	}
+	return baselines
+}
+
+/* Сбрасывает базовые линии указанных метрик или всех метрик, если список пуст */
+func ResetAnomalyBaselines(metrics []string) error {
+	db := GetDB()
+
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	if len(metrics) == 0 {
+		anomalyBaselines = make(map[string]*[24]anomalyBucket)
+		if db != nil {
+			if _, err := db.Exec("DELETE FROM anomaly_baselines"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, metric := range metrics {
+		if _, ok := anomalyMetrics[metric]; !ok {
+			return fmt.Errorf("неизвестная метрика '%s'", metric)
+		}
+	}
+	for _, metric := range metrics {
+		delete(anomalyBaselines, metric)
+	}
+	if db != nil {
+		_, err := db.Exec("DELETE FROM anomaly_baselines WHERE metric IN ("+sqlPlaceholders(len(metrics))+")", stringsToArgs(metrics)...)
+		return err
+	}
+	return nil
+}
+
+/* Преобразует список строк в аргументы запроса */
+func stringsToArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
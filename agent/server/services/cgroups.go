@@ -0,0 +1,70 @@
+/* Сервисы для ограничения ресурсов процессов через cgroup v2 */
+package services
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+/* Ошибка, когда агент не может управлять cgroup v2: нет поддержки, cgroup v2 не смонтирован или не делегирован агенту */
+var ErrCgroupUnavailable = errors.New("cgroup v2 недоступен агенту")
+
+/* Ограничение ввода-вывода для одного блочного устройства, нулевые значения не ограничиваются */
+type CgroupIOLimit struct {
+	Device    string `json:"device"` // "8:0" или путь к устройству, например "/dev/sda"
+	ReadBps   uint64 `json:"readBps,omitempty"`
+	WriteBps  uint64 `json:"writeBps,omitempty"`
+	ReadIOPS  uint64 `json:"readIops,omitempty"`
+	WriteIOPS uint64 `json:"writeIops,omitempty"`
+}
+
+/* Ограничения ресурсов процесса */
+type CgroupLimits struct {
+	CPUPercent  *float64        `json:"cpuPercent,omitempty"`  // Доля одного CPU в процентах, 200 - два CPU
+	MemoryMaxMB *uint64         `json:"memoryMaxMB,omitempty"` // Жесткий предел памяти
+	IO          []CgroupIOLimit `json:"io,omitempty"`
+}
+
+/* Управляемая агентом cgroup с процессом и ее текущими ограничениями */
+type ManagedCgroup struct {
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	PIDs           []int32 `json:"pids"`
+	CPUMax         string  `json:"cpuMax"`    // Содержимое cpu.max, например "50000 100000"
+	MemoryMax      string  `json:"memoryMax"` // Содержимое memory.max в байтах или "max"
+	MemoryCurrent  uint64  `json:"memoryCurrent"`
+	IOMax          string  `json:"ioMax"`
+	OriginalCgroup string  `json:"originalCgroup,omitempty"` // Cgroup, в которой процесс был до ограничения, сохраняется в базе данных
+}
+
+/* Период cpu.max в микросекундах и минимальная квота, которую принимает ядро */
+const (
+	cgroupCPUPeriod   = 100000
+	cgroupMinCPUQuota = 1000
+)
+
+/* Проверяет, что задано хотя бы одно ограничение и значения допустимы */
+func (l *CgroupLimits) Validate() error {
+	if l.CPUPercent == nil && l.MemoryMaxMB == nil && len(l.IO) == 0 {
+		return fmt.Errorf("укажите cpuPercent, memoryMaxMB или io")
+	}
+	if l.CPUPercent != nil {
+		maxPercent := float64(100 * runtime.NumCPU())
+		if *l.CPUPercent*cgroupCPUPeriod/100 < cgroupMinCPUQuota || *l.CPUPercent > maxPercent {
+			return fmt.Errorf("cpuPercent должен быть от 1 до %.0f", maxPercent)
+		}
+	}
+	if l.MemoryMaxMB != nil && *l.MemoryMaxMB == 0 {
+		return fmt.Errorf("memoryMaxMB должен быть больше 0")
+	}
+	for _, limit := range l.IO {
+		if limit.Device == "" {
+			return fmt.Errorf("для ограничения io нужно поле 'device'")
+		}
+		if limit.ReadBps == 0 && limit.WriteBps == 0 && limit.ReadIOPS == 0 && limit.WriteIOPS == 0 {
+			return fmt.Errorf("для устройства %s не задано ни одного ограничения io", limit.Device)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+Каталог управляемых cgroup по умолчанию и переменная окружения, в которой можно указать другой
+
+	По умолчанию каталог создается в корне cgroup v2, а не в cgroup агента: иначе под systemd он принадлежит
+	службе агента, и остановка или перезапуск агента завершает все ограниченные процессы
+*/
+const (
+	cgroupBaseName = "nexora-limits"
+	cgroupRootEnv  = "NEXORA_CGROUP_ROOT"
+)
+
+/* Контроллеры, которые агент включает для управляемых cgroup */
+var cgroupControllers = []string{"cpu", "memory", "io"}
+
+var (
+	cgroupBase       string
+	cgroupMount      string
+	cgroupMutex      sync.Mutex
+	errCgroupMissing = fmt.Errorf("%w: cgroup v2 не смонтирован", ErrCgroupUnavailable)
+)
+
+/* Находит точку монтирования cgroup v2 и cgroup процесса агента */
+func discoverCgroupV2() (string, string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	mount := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" {
+				mount = fields[4]
+			}
+		}
+	}
+	if mount == "" {
+		return "", "", errCgroupMissing
+	}
+
+	own, err := processCgroup(int32(os.Getpid()))
+	if err != nil {
+		return "", "", err
+	}
+	return mount, own, nil
+}
+
+/* Возвращает путь cgroup v2 процесса из /proc/<pid>/cgroup */
+func processCgroup(pid int32) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", fmt.Errorf("процесс с PID %d не найден", pid)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", errCgroupMissing
+}
+
+/* Переводит ошибку файловой системы cgroup в понятное сообщение */
+func cgroupError(action, path string, err error) error {
+	if errors.Is(err, os.ErrPermission) || errors.Is(err, unix.EROFS) {
+		return fmt.Errorf("%w: нет прав, чтобы %s %s. Запустите агент от root или укажите в %s делегированную агенту cgroup", ErrCgroupUnavailable, action, path, cgroupRootEnv)
+	}
+	return fmt.Errorf("не удалось %s %s: %w", action, path, err)
+}
+
+/* Записывает значение в файл интерфейса cgroup */
+func writeCgroupFile(dir, name, value string) error {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(value), 0644); err != nil {
+		return cgroupError("записать в", path, err)
+	}
+	return nil
+}
+
+/* Читает файл интерфейса cgroup без завершающего перевода строки */
+func readCgroupFile(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+/* Включает для потомков cgroup контроллеры из списка, которые доступны в ней самой и еще не включены */
+func enableCgroupControllers(dir string) error {
+	available := strings.Fields(readCgroupFile(dir, "cgroup.controllers"))
+	enabled := strings.Fields(readCgroupFile(dir, "cgroup.subtree_control"))
+	var enable []string
+	found := false
+	for _, controller := range cgroupControllers {
+		if containsString(available, controller) {
+			found = true
+			if !containsString(enabled, controller) {
+				enable = append(enable, "+"+controller)
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: в %s нет контроллеров cpu, memory и io cgroup v2, они не делегированы агенту или заняты cgroup v1", ErrCgroupUnavailable, dir)
+	}
+	if len(enable) == 0 {
+		return nil
+	}
+	if err := writeCgroupFile(dir, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+		if errors.Is(err, unix.EBUSY) {
+			return fmt.Errorf("%w: в %s есть процессы, а cgroup с контроллерами для потомков не может содержать процессы", ErrCgroupUnavailable, dir)
+		}
+		return err
+	}
+	return nil
+}
+
+/* Проверяет, есть ли строка в списке */
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Возвращает точку монтирования cgroup v2 и путь каталога управляемых cgroup, ничего не создавая
+
+	Каталог берется из NEXORA_CGROUP_ROOT (путь относительно корня cgroup v2) или создается в корне как nexora-limits.
+	Каталог внутри cgroup агента не допускается, чтобы ограниченные процессы не зависели от службы агента
+*/
+func managedCgroupPaths() (string, string, error) {
+	mount, own, err := discoverCgroupV2()
+	if err != nil {
+		return "", "", err
+	}
+
+	root := os.Getenv(cgroupRootEnv)
+	if root == "" {
+		root = "/" + cgroupBaseName
+	}
+	if !strings.HasPrefix(root, "/") {
+		return "", "", fmt.Errorf("%w: %s должен быть путем от корня cgroup v2, например /%s", ErrCgroupUnavailable, cgroupRootEnv, cgroupBaseName)
+	}
+	root = filepath.Clean(root)
+	if own != "/" && (root == own || strings.HasPrefix(root, own+"/")) {
+		return "", "", fmt.Errorf("%w: %s находится внутри cgroup агента %s, ограниченные процессы будут завершены вместе с агентом", ErrCgroupUnavailable, root, own)
+	}
+	return mount, filepath.Join(mount, root), nil
+}
+
+/*
+Возвращает каталог управляемых cgroup, подготавливая его при первом обращении
+
+	Если каталога нет, он создается, а контроллеры включаются в родительской cgroup. Существующий каталог
+	(например, заранее делегированный агенту) используется как есть, в нем включаются только контроллеры для потомков
+*/
+func managedCgroupBase() (string, error) {
+	if cgroupBase != "" {
+		return cgroupBase, nil
+	}
+	mount, base, err := managedCgroupPaths()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		if err := enableCgroupControllers(filepath.Dir(base)); err != nil {
+			return "", err
+		}
+		if err := os.Mkdir(base, 0755); err != nil && !os.IsExist(err) {
+			return "", cgroupError("создать", base, err)
+		}
+	}
+	if err := enableCgroupControllers(base); err != nil {
+		return "", err
+	}
+	cgroupMount, cgroupBase = mount, base
+	return base, nil
+}
+
+/* Переводит устройство из пути или строки "8:0" в формат MAJ:MIN */
+func blockDeviceID(device string) (string, error) {
+	if !strings.HasPrefix(device, "/") {
+		parts := strings.Split(device, ":")
+		if len(parts) == 2 {
+			if _, err := strconv.Atoi(parts[0]); err == nil {
+				if _, err := strconv.Atoi(parts[1]); err == nil {
+					return device, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("некорректное устройство '%s', ожидается путь или MAJ:MIN", device)
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(device, &stat); err != nil {
+		return "", fmt.Errorf("устройство %s не найдено", device)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", fmt.Errorf("%s не является блочным устройством", device)
+	}
+	return fmt.Sprintf("%d:%d", unix.Major(stat.Rdev), unix.Minor(stat.Rdev)), nil
+}
+
+/* Записывает ограничения в cgroup: незаданные cpuPercent и memoryMaxMB снимаются, io меняется только для указанных устройств */
+func applyCgroupLimits(dir string, limits CgroupLimits) error {
+	cpuMax := fmt.Sprintf("max %d", cgroupCPUPeriod)
+	if limits.CPUPercent != nil {
+		cpuMax = fmt.Sprintf("%d %d", int(*limits.CPUPercent*cgroupCPUPeriod/100), cgroupCPUPeriod)
+	}
+	if err := writeCgroupFile(dir, "cpu.max", cpuMax); err != nil {
+		return err
+	}
+
+	memoryMax := "max"
+	if limits.MemoryMaxMB != nil {
+		memoryMax = strconv.FormatUint(*limits.MemoryMaxMB*1024*1024, 10)
+	}
+	if err := writeCgroupFile(dir, "memory.max", memoryMax); err != nil {
+		return err
+	}
+
+	for _, limit := range limits.IO {
+		device, err := blockDeviceID(limit.Device)
+		if err != nil {
+			return err
+		}
+		line := device
+		for _, setting := range []struct {
+			key   string
+			value uint64
+		}{{"rbps", limit.ReadBps}, {"wbps", limit.WriteBps}, {"riops", limit.ReadIOPS}, {"wiops", limit.WriteIOPS}} {
+			value := "max"
+			if setting.value > 0 {
+				value = strconv.FormatUint(setting.value, 10)
+			}
+			line += " " + setting.key + "=" + value
+		}
+		if err := writeCgroupFile(dir, "io.max", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Имя управляемой cgroup для процесса */
+func managedCgroupName(pid int32) string {
+	return fmt.Sprintf("pid-%d", pid)
+}
+
+/*
+Переносит процесс в управляемую агентом cgroup с ограничениями cpu.max, memory.max и io.max
+
+	Если процесс уже ограничен, ограничения заменяются новыми
+*/
+func LimitProcess(pid int32, limits CgroupLimits) (*ManagedCgroup, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("PID должен быть положительным числом")
+	}
+	if pid == int32(os.Getpid()) || pid == 1 {
+		return nil, fmt.Errorf("процесс %d нельзя ограничивать", pid)
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	cgroupMutex.Lock()
+	defer cgroupMutex.Unlock()
+
+	base, err := managedCgroupBase()
+	if err != nil {
+		return nil, err
+	}
+	original, err := processCgroup(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	name := managedCgroupName(pid)
+	dir := filepath.Join(base, name)
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return nil, cgroupError("создать", dir, err)
+	}
+	if err := applyCgroupLimits(dir, limits); err != nil {
+		return nil, err
+	}
+	if filepath.Join(cgroupMount, original) != dir {
+		if _, err := db.Exec(
+			"INSERT OR REPLACE INTO managed_cgroups (name, created_at, pid, original_cgroup) VALUES (?, ?, ?, ?)",
+			name, time.Now().Format("2006-01-02 15:04:05"), pid, original,
+		); err != nil {
+			os.Remove(dir)
+			return nil, fmt.Errorf("не удалось сохранить исходную cgroup процесса: %w", err)
+		}
+		if err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(int(pid))); err != nil {
+			os.Remove(dir)
+			db.Exec("DELETE FROM managed_cgroups WHERE name = ?", name)
+			return nil, fmt.Errorf("не удалось перенести процесс %d: %w. Процесс должен находиться в поддереве cgroup, доступном агенту", pid, err)
+		}
+	}
+	return readManagedCgroup(base, name), nil
+}
+
+/* Читает состояние управляемой cgroup */
+func readManagedCgroup(base, name string) *ManagedCgroup {
+	dir := filepath.Join(base, name)
+	cg := &ManagedCgroup{
+		Name:           name,
+		Path:           dir,
+		PIDs:           []int32{},
+		CPUMax:         readCgroupFile(dir, "cpu.max"),
+		MemoryMax:      readCgroupFile(dir, "memory.max"),
+		IOMax:          readCgroupFile(dir, "io.max"),
+		OriginalCgroup: managedCgroupOriginal(name),
+	}
+	cg.MemoryCurrent, _ = strconv.ParseUint(readCgroupFile(dir, "memory.current"), 10, 64)
+	for _, value := range strings.Fields(readCgroupFile(dir, "cgroup.procs")) {
+		if pid, err := strconv.Atoi(value); err == nil {
+			cg.PIDs = append(cg.PIDs, int32(pid))
+		}
+	}
+	return cg
+}
+
+/* Возвращает исходную cgroup процесса, сохраненную при ограничении, или пустую строку, если она неизвестна */
+func managedCgroupOriginal(name string) string {
+	db := GetDB()
+	if db == nil {
+		return ""
+	}
+	var original string
+	db.QueryRow("SELECT original_cgroup FROM managed_cgroups WHERE name = ?", name).Scan(&original)
+	return original
+}
+
+/* Возвращает список управляемых агентом cgroup, пустой список если каталог управляемых cgroup еще не создан */
+func GetManagedCgroups() ([]ManagedCgroup, error) {
+	cgroupMutex.Lock()
+	defer cgroupMutex.Unlock()
+
+	_, base, err := managedCgroupPaths()
+	if err != nil {
+		return nil, err
+	}
+	result := []ManagedCgroup{}
+	entries, err := os.ReadDir(base)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, cgroupError("прочитать", base, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "pid-") {
+			result = append(result, *readManagedCgroup(base, entry.Name()))
+		}
+	}
+	return result, nil
+}
+
+/*
+Снимает ограничения: возвращает процессы в исходную cgroup и удаляет управляемую cgroup
+
+	Исходная cgroup сохраняется в базе данных при ограничении. Если она неизвестна или уже удалена,
+	ограничения не снимаются, чтобы не перенести процессы в чужую cgroup
+*/
+func ReleaseManagedCgroup(name string) error {
+	if !strings.HasPrefix(name, "pid-") || strings.ContainsAny(name, "/.") {
+		return fmt.Errorf("некорректное имя cgroup '%s'", name)
+	}
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	cgroupMutex.Lock()
+	defer cgroupMutex.Unlock()
+
+	base, err := managedCgroupBase()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(base, name)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("управляемая cgroup '%s' не найдена", name)
+	}
+
+	original := managedCgroupOriginal(name)
+	if original == "" {
+		return fmt.Errorf("исходная cgroup процессов из '%s' неизвестна, ограничения не сняты", name)
+	}
+	target := filepath.Join(cgroupMount, original)
+	if _, err := os.Stat(target); err != nil {
+		return fmt.Errorf("исходная cgroup %s процессов из '%s' больше не существует, ограничения не сняты", original, name)
+	}
+
+	for _, pid := range strings.Fields(readCgroupFile(dir, "cgroup.procs")) {
+		if err := writeCgroupFile(target, "cgroup.procs", pid); err != nil && !errors.Is(err, unix.ESRCH) {
+			return fmt.Errorf("не удалось вернуть процесс %s из cgroup: %w", pid, err)
+		}
+	}
+	if err := os.Remove(dir); err != nil {
+		return cgroupError("удалить", dir, err)
+	}
+	db.Exec("DELETE FROM managed_cgroups WHERE name = ?", name)
+	return nil
+}
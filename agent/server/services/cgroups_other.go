@@ -0,0 +1,23 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+/* Ошибка для платформ без cgroup v2 */
+var errCgroupUnsupported = fmt.Errorf("%w: ограничение ресурсов через cgroup v2 поддерживается только в Linux", ErrCgroupUnavailable)
+
+/* Переносит процесс в управляемую агентом cgroup с ограничениями */
+func LimitProcess(pid int32, limits CgroupLimits) (*ManagedCgroup, error) {
+	return nil, errCgroupUnsupported
+}
+
+/* Возвращает список управляемых агентом cgroup */
+func GetManagedCgroups() ([]ManagedCgroup, error) {
+	return nil, errCgroupUnsupported
+}
+
+/* Снимает ограничения и удаляет управляемую cgroup */
+func ReleaseManagedCgroup(name string) error {
+	return errCgroupUnsupported
+}
@@ -0,0 +1,171 @@
+/* Разбор и проверка расписаний в формате cron */
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Расписание из пяти полей: минута, час, день месяца, месяц, день недели
+
+	Поддерживаются *, списки через запятую, диапазоны через дефис и шаг через косую черту, например "0 2-4 * * 1-5"
+*/
+type CronSchedule struct {
+	minutes    [60]bool
+	hours      [24]bool
+	days       [32]bool
+	months     [13]bool
+	weekdays   [7]bool
+	anyDay     bool
+	anyWeekday bool
+	expr       string
+}
+
+/* Разбирает выражение расписания, возвращает ошибку с указанием неверного поля */
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("расписание должно содержать 5 полей, получено %d", len(fields))
+	}
+
+	s := &CronSchedule{expr: strings.Join(fields, " ")}
+	if err := parseCronField(fields[0], 0, 59, s.minutes[:]); err != nil {
+		return nil, fmt.Errorf("минуты: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, s.hours[:]); err != nil {
+		return nil, fmt.Errorf("часы: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, s.days[:]); err != nil {
+		return nil, fmt.Errorf("день месяца: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, s.months[:]); err != nil {
+		return nil, fmt.Errorf("месяц: %w", err)
+	}
+
+	var weekdays [8]bool
+	if err := parseCronField(fields[4], 0, 7, weekdays[:]); err != nil {
+		return nil, fmt.Errorf("день недели: %w", err)
+	}
+	copy(s.weekdays[:], weekdays[:7])
+	if weekdays[7] {
+		s.weekdays[0] = true
+	}
+
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	return s, nil
+}
+
+/* Разбирает одно поле расписания и отмечает подходящие значения */
+func parseCronField(field string, min, max int, set []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			parsed, err := strconv.Atoi(part[i+1:])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("некорректный шаг %q", part)
+			}
+			step = parsed
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("некорректный диапазон %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("некорректное значение %q", part)
+			}
+			lo = value
+			if step == 1 {
+				hi = value
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("значение %q вне диапазона %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+/* Возвращает исходное выражение расписания */
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+/*
+Проверяет, попадает ли минута времени t в расписание
+
+	Если ограничены и день месяца, и день недели, достаточно совпадения одного из них, как в cron
+*/
+func (s *CronSchedule) Matches(t time.Time) bool {
+	return s.minutes[t.Minute()] && s.hours[t.Hour()] && s.months[int(t.Month())] && s.dayMatches(t)
+}
+
+/* Проверяет, подходит ли день времени t под ограничения дня месяца и дня недели */
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dayMatch := s.days[t.Day()]
+	weekdayMatch := s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekdayMatch
+	case s.anyWeekday:
+		return dayMatch
+	}
+	return dayMatch || weekdayMatch
+}
+
+/* Возвращает ближайшее время запуска строго после after или нулевое время, если его нет в течение пяти лет */
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !s.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+/*
+Проверяет, находится ли время t внутри окна длительностью duration от одного из запусков расписания
+
+	Используется для повторяющихся окон обслуживания, окно начинается в момент запуска
+*/
+func (s *CronSchedule) ActiveAt(t time.Time, duration time.Duration) bool {
+	start := t.Truncate(time.Minute)
+	for m := start; t.Sub(m) < duration; m = m.Add(-time.Minute) {
+		if s.Matches(m) {
+			return true
+		}
+	}
+	return false
+}
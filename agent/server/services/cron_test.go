@@ -0,0 +1,171 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+/* Время в UTC по строке "2006-01-02 15:04" */
+func cronTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.UTC)
+	if err != nil {
+		t.Fatalf("некорректное время %q: %v", value, err)
+	}
+	return parsed
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"мало полей", "* * * *"},
+		{"много полей", "* * * * * *"},
+		{"минута вне диапазона", "60 * * * *"},
+		{"день месяца ноль", "* * 0 * *"},
+		{"месяц вне диапазона", "* * * 13 *"},
+		{"день недели вне диапазона", "* * * * 8"},
+		{"нулевой шаг", "*/0 * * * *"},
+		{"обратный диапазон", "5-1 * * * *"},
+		{"не число", "a * * * *"},
+		{"пустой элемент списка", "1,,2 * * * *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCronSchedule(tt.expr); err == nil {
+				t.Fatalf("ParseCronSchedule(%q) не вернул ошибку", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		after string
+		want  string // Пустая строка - запуска нет
+	}{
+		{"каждую минуту", "* * * * *", "2024-01-01 10:07", "2024-01-01 10:08"},
+		{"шаг по минутам", "*/15 * * * *", "2024-01-01 10:07", "2024-01-01 10:15"},
+		{"шаг через час", "*/15 * * * *", "2024-01-01 10:45", "2024-01-01 11:00"},
+		{"список минут", "5,40 * * * *", "2024-01-01 10:05", "2024-01-01 10:40"},
+		{"шаг от значения", "10/20 * * * *", "2024-01-01 10:31", "2024-01-01 10:50"},
+		{"диапазон с шагом", "0 0 1-10/3 * *", "2024-01-01 00:00", "2024-01-04 00:00"},
+		{"рабочие дни через выходные", "0 2-4 * * 1-5", "2024-01-05 04:00", "2024-01-08 02:00"},
+		{"воскресенье как 7", "0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		{"воскресенье как 0", "0 0 * * 0", "2024-01-01 00:00", "2024-01-07 00:00"},
+		{"день месяца или день недели: воскресенье раньше", "30 9 1 * 0", "2024-01-01 10:00", "2024-01-07 09:30"},
+		{"день месяца или день недели: первое число раньше", "30 9 1 * 0", "2024-01-29 10:00", "2024-02-01 09:30"},
+		{"день недели при любом дне месяца", "0 12 * * 3", "2024-01-01 00:00", "2024-01-03 12:00"},
+		{"переход через год", "0 0 1 1 *", "2024-06-15 12:00", "2025-01-01 00:00"},
+		{"29 февраля", "0 12 29 2 *", "2024-03-01 00:00", "2028-02-29 12:00"},
+		{"несуществующая дата", "0 0 30 2 *", "2024-01-01 00:00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCronSchedule(%q): %v", tt.expr, err)
+			}
+			got := schedule.Next(cronTime(t, tt.after))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Fatalf("Next() = %v, ожидалось нулевое время", got)
+				}
+				return
+			}
+			if want := cronTime(t, tt.want); !got.Equal(want) {
+				t.Fatalf("Next() = %v, ожидалось %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleActiveAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		duration time.Duration
+		at       string
+		want     bool
+	}{
+		{"в момент запуска", "0 22 * * *", 8 * time.Hour, "2024-01-01 22:00", true},
+		{"до запуска", "0 22 * * *", 8 * time.Hour, "2024-01-01 21:59", false},
+		{"окно через полночь", "0 22 * * *", 8 * time.Hour, "2024-01-02 05:59", true},
+		{"после окна", "0 22 * * *", 8 * time.Hour, "2024-01-02 06:00", false},
+		{"окно только в рабочие дни", "0 22 * * 1-5", 8 * time.Hour, "2024-01-07 01:00", false},
+		{"недельное окно в конце", "0 0 * * 1", 7 * 24 * time.Hour, "2024-01-07 23:59", true},
+		{"недельное окно до следующего запуска", "0 0 * * 1", 6 * 24 * time.Hour, "2024-01-06 23:59", true},
+		{"после недельного окна", "0 0 * * 1", 6 * 24 * time.Hour, "2024-01-07 00:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCronSchedule(%q): %v", tt.expr, err)
+			}
+			if got := schedule.ActiveAt(cronTime(t, tt.at), tt.duration); got != tt.want {
+				t.Fatalf("ActiveAt(%s, %v) = %v, ожидалось %v", tt.at, tt.duration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertSilenceWindowLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		durationSec int
+		wantErr     bool
+	}{
+		{"нулевая длительность", 0, true},
+		{"ровно 7 дней", maxSilenceWindowSec, false},
+		{"больше 7 дней", maxSilenceWindowSec + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silence := &AlertSilence{CreatedBy: "test", Schedule: "0 0 * * 1", DurationSec: tt.durationSec}
+			if err := ValidateAlertSilence(silence); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateAlertSilence() = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlertSilenceActiveAt(t *testing.T) {
+	ends := cronTime(t, "2024-01-10 00:00")
+	silence := &AlertSilence{
+		Enabled:     true,
+		StartsAt:    cronTime(t, "2024-01-01 00:00"),
+		EndsAt:      &ends,
+		Schedule:    "0 0 * * 1",
+		DurationSec: maxSilenceWindowSec,
+	}
+
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2023-12-31 23:59", false},
+		{"2024-01-01 00:00", true},
+		{"2024-01-07 23:59", true},
+		{"2024-01-09 23:59", true},
+		{"2024-01-10 00:00", false},
+	}
+
+	for _, tt := range tests {
+		if got := silence.ActiveAt(cronTime(t, tt.at)); got != tt.want {
+			t.Errorf("ActiveAt(%s) = %v, ожидалось %v", tt.at, got, tt.want)
+		}
+	}
+
+	silence.Enabled = false
+	if silence.ActiveAt(cronTime(t, "2024-01-02 00:00")) {
+		t.Error("выключенная тишина считается действующей")
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/RZhurakovskiy/agent/server/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/* Создает временную базу данных со схемой агента и подключает ее к сервисам на время теста */
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("не удалось открыть базу данных: %v", err)
+	}
+	if _, err := sqlDB.Exec(db.SchemaSQL); err != nil {
+		t.Fatalf("не удалось создать схему: %v", err)
+	}
+
+	SetDB(sqlDB)
+	t.Cleanup(func() {
+		SetDB(nil)
+		sqlDB.Close()
+	})
+	return sqlDB
+}
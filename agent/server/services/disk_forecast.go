@@ -0,0 +1,427 @@
+/* Сервисы для прогнозирования заполнения файловых систем */
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+)
+
+/* Настройки сбора истории и прогноза заполнения дисков */
+type DiskForecastConfig struct {
+	Enabled           bool    `json:"enabled"`
+	SampleIntervalSec int     `json:"sampleIntervalSec"` // Как часто сохранять заполненность файловых систем
+	RetentionDays     int     `json:"retentionDays"`     // Сколько дней хранить историю заполненности
+	Method            string  `json:"method"`            // "linear" - линейная регрессия, "holt" - экспоненциальное сглаживание Холта
+	LookbackDays      int     `json:"lookbackDays"`      // За сколько дней истории строится прогноз
+	MinSamples        int     `json:"minSamples"`        // Сколько замеров нужно до построения прогноза
+	Alpha             float64 `json:"alpha"`             // Сглаживание уровня для метода Холта
+	Beta              float64 `json:"beta"`              // Сглаживание тренда для метода Холта
+	HorizonDays       float64 `json:"horizonDays"`       // Алерт создается, если до заполнения осталось меньше этого числа дней, 0 - алерты отключены
+	Severity          string  `json:"severity"`
+}
+
+/* Прогноз заполнения одной файловой системы */
+type DiskForecast struct {
+	Mountpoint         string     `json:"mountpoint"`
+	Device             string     `json:"device"`
+	Fstype             string     `json:"fstype"`
+	Method             string     `json:"method"`
+	Samples            int        `json:"samples"`
+	LastSampleAt       time.Time  `json:"lastSampleAt"`
+	TotalBytes         uint64     `json:"totalBytes"`
+	UsedBytes          uint64     `json:"usedBytes"`
+	FreeBytes          uint64     `json:"freeBytes"`
+	UsedPercent        float64    `json:"usedPercent"`
+	GrowthBytesPerDay  float64    `json:"growthBytesPerDay"`
+	DaysUntilFull      *float64   `json:"daysUntilFull"`
+	FullAt             *time.Time `json:"fullAt"`
+	InodesTotal        uint64     `json:"inodesTotal"`
+	InodesUsed         uint64     `json:"inodesUsed"`
+	InodesUsedPercent  float64    `json:"inodesUsedPercent"`
+	InodeGrowthPerDay  float64    `json:"inodeGrowthPerDay"`
+	InodeDaysUntilFull *float64   `json:"inodeDaysUntilFull"`
+	InodesFullAt       *time.Time `json:"inodesFullAt"`
+}
+
+/* Замер заполненности файловой системы из истории */
+type DiskUsageSample struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Mountpoint  string    `json:"mountpoint"`
+	Device      string    `json:"device"`
+	Fstype      string    `json:"fstype"`
+	TotalBytes  uint64    `json:"totalBytes"`
+	UsedBytes   uint64    `json:"usedBytes"`
+	FreeBytes   uint64    `json:"freeBytes"`
+	InodesTotal uint64    `json:"inodesTotal"`
+	InodesUsed  uint64    `json:"inodesUsed"`
+}
+
+/* Точка временного ряда для прогноза: секунды от первого замера и значение */
+type forecastPoint struct {
+	x float64
+	y float64
+}
+
+/* Прогноз дальше этого горизонта считается бесконечным */
+const diskForecastMaxDays = 3650
+
+var (
+	diskForecastConfig = DiskForecastConfig{
+		Enabled:           true,
+		SampleIntervalSec: 300,
+		RetentionDays:     30,
+		Method:            "linear",
+		LookbackDays:      7,
+		MinSamples:        12,
+		Alpha:             0.3,
+		Beta:              0.1,
+		HorizonDays:       7,
+		Severity:          "warning",
+	}
+	lastDiskSampleAt  time.Time
+	diskForecastMutex sync.Mutex
+)
+
+/* Устанавливает настройки прогноза заполнения дисков */
+func SetDiskForecastConfig(cfg DiskForecastConfig) error {
+	if cfg.Method != "linear" && cfg.Method != "holt" {
+		return fmt.Errorf("метод прогноза должен быть linear или holt")
+	}
+	if cfg.SampleIntervalSec < 10 {
+		return fmt.Errorf("sampleIntervalSec должен быть не меньше 10 секунд")
+	}
+	if cfg.RetentionDays <= 0 || cfg.LookbackDays <= 0 || cfg.LookbackDays > cfg.RetentionDays {
+		return fmt.Errorf("lookbackDays должен быть больше 0 и не больше retentionDays")
+	}
+	if cfg.MinSamples < 2 {
+		return fmt.Errorf("minSamples должен быть не меньше 2")
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 || cfg.Beta <= 0 || cfg.Beta > 1 {
+		return fmt.Errorf("alpha и beta должны быть в диапазоне от 0 до 1")
+	}
+	if cfg.HorizonDays < 0 {
+		return fmt.Errorf("horizonDays не может быть отрицательным")
+	}
+	if !alertSeverities[cfg.Severity] {
+		return fmt.Errorf("неизвестная важность: %s", cfg.Severity)
+	}
+
+	diskForecastMutex.Lock()
+	defer diskForecastMutex.Unlock()
+	diskForecastConfig = cfg
+	return nil
+}
+
+/* Возвращает текущие настройки прогноза заполнения дисков */
+func GetDiskForecastConfig() DiskForecastConfig {
+	diskForecastMutex.Lock()
+	defer diskForecastMutex.Unlock()
+	return diskForecastConfig
+}
+
+/*
+Сохраняет заполненность файловых систем и проверяет прогноз заполнения
+
+	Вызывается при каждом обновлении метрик памяти, замер сохраняется не чаще sampleIntervalSec.
+	Если до заполнения объема или inode осталось меньше horizonDays дней, создается алерт типа disk_full
+*/
+func CheckDiskForecast() {
+	now := time.Now()
+
+	diskForecastMutex.Lock()
+	cfg := diskForecastConfig
+	if !cfg.Enabled || now.Sub(lastDiskSampleAt) < time.Duration(cfg.SampleIntervalSec)*time.Second {
+		diskForecastMutex.Unlock()
+		return
+	}
+	lastDiskSampleAt = now
+	diskForecastMutex.Unlock()
+
+	if err := saveDiskUsage(now, cfg.RetentionDays); err != nil {
+		log.Printf("Ошибка сохранения заполненности дисков: %v", err)
+		return
+	}
+
+	forecasts, err := GetDiskForecasts(cfg.Method)
+	if err != nil {
+		log.Printf("Ошибка прогноза заполнения дисков: %v", err)
+		return
+	}
+
+	rule := AlertRule{
+		Metric:     "disk_full",
+		Comparator: "<",
+		Threshold:  cfg.HorizonDays,
+		Severity:   cfg.Severity,
+	}
+	clearThreshold := cfg.HorizonDays * 1.25
+	rule.ClearThreshold = &clearThreshold
+
+	alertEngineMutex.Lock()
+	defer alertEngineMutex.Unlock()
+
+	restoreFiringAlerts()
+	seen := make(map[string]bool)
+	for _, f := range forecasts {
+		if cfg.HorizonDays == 0 {
+			continue
+		}
+		if f.DaysUntilFull != nil {
+			obs := alertObservation{
+				fingerprint: "disk:" + f.Mountpoint + ":bytes",
+				value:       *f.DaysUntilFull,
+				message: fmt.Sprintf("Диск %s заполнится примерно через %.1f дн. (занято %.1f%%, рост %s в день)",
+					f.Mountpoint, *f.DaysUntilFull, f.UsedPercent, formatBytes(f.GrowthBytesPerDay)),
+			}
+			evaluateAlertRule(rule, obs, now)
+			seen[obs.fingerprint] = true
+		}
+		if f.InodeDaysUntilFull != nil {
+			obs := alertObservation{
+				fingerprint: "disk:" + f.Mountpoint + ":inodes",
+				value:       *f.InodeDaysUntilFull,
+				message: fmt.Sprintf("Inode на %s закончатся примерно через %.1f дн. (занято %.1f%%, рост %.0f в день)",
+					f.Mountpoint, *f.InodeDaysUntilFull, f.InodesUsedPercent, f.InodeGrowthPerDay),
+			}
+			evaluateAlertRule(rule, obs, now)
+			seen[obs.fingerprint] = true
+		}
+	}
+	resolveUnseenAlerts("disk:", seen, now)
+}
+
+/* Сохраняет текущую заполненность файловых систем и удаляет записи старше срока хранения */
+func saveDiskUsage(now time.Time, retentionDays int) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	stats, err := getmetrics.GetDiskUsage()
+	if err != nil {
+		return err
+	}
+
+	timestamp := now.Format("2006-01-02 15:04:05")
+	for _, s := range stats {
+		_, err := db.Exec(
+			"INSERT INTO disk_usage_history (timestamp, mountpoint, device, fstype, total_bytes, used_bytes, free_bytes, inodes_total, inodes_used) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			timestamp, s.Mountpoint, s.Device, s.Fstype, s.TotalBytes, s.UsedBytes, s.FreeBytes, s.InodesTotal, s.InodesUsed,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(
+		"DELETE FROM disk_usage_history WHERE timestamp < ?",
+		now.AddDate(0, 0, -retentionDays).Format("2006-01-02 15:04:05"),
+	)
+	return err
+}
+
+/* Возвращает историю заполненности файловых систем за период, пустой mountpoint выбирает все файловые системы */
+func GetDiskUsageHistory(mountpoint string, from, to time.Time) ([]DiskUsageSample, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	query := "SELECT timestamp, mountpoint, COALESCE(device, ''), COALESCE(fstype, ''), total_bytes, used_bytes, COALESCE(free_bytes, total_bytes - used_bytes), COALESCE(inodes_total, 0), COALESCE(inodes_used, 0) FROM disk_usage_history WHERE timestamp >= ? AND timestamp <= ?"
+	args := []interface{}{from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05")}
+	if mountpoint != "" {
+		query += " AND mountpoint = ?"
+		args = append(args, mountpoint)
+	}
+	query += " ORDER BY mountpoint, timestamp"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []DiskUsageSample{}
+	for rows.Next() {
+		var s DiskUsageSample
+		var timestamp string
+		if err := rows.Scan(&timestamp, &s.Mountpoint, &s.Device, &s.Fstype, &s.TotalBytes, &s.UsedBytes, &s.FreeBytes, &s.InodesTotal, &s.InodesUsed); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(timestamp); ok {
+			s.Timestamp = parsed
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+/*
+Строит прогноз заполнения по объему и inode для каждой файловой системы
+
+	Используется история за lookbackDays дней, method переопределяет метод из настроек, если не пустой.
+	Файловые системы с числом замеров меньше minSamples возвращаются без прогноза
+*/
+func GetDiskForecasts(method string) ([]DiskForecast, error) {
+	cfg := GetDiskForecastConfig()
+	if method == "" {
+		method = cfg.Method
+	}
+	if method != "linear" && method != "holt" {
+		return nil, fmt.Errorf("метод прогноза должен быть linear или holt")
+	}
+
+	now := time.Now()
+	samples, err := GetDiskUsageHistory("", now.AddDate(0, 0, -cfg.LookbackDays), now)
+	if err != nil {
+		return nil, err
+	}
+
+	byMountpoint := make(map[string][]DiskUsageSample)
+	for _, s := range samples {
+		byMountpoint[s.Mountpoint] = append(byMountpoint[s.Mountpoint], s)
+	}
+
+	forecasts := make([]DiskForecast, 0, len(byMountpoint))
+	for mountpoint, series := range byMountpoint {
+		last := series[len(series)-1]
+		f := DiskForecast{
+			Mountpoint:   mountpoint,
+			Device:       last.Device,
+			Fstype:       last.Fstype,
+			Method:       method,
+			Samples:      len(series),
+			LastSampleAt: last.Timestamp,
+			TotalBytes:   last.TotalBytes,
+			UsedBytes:    last.UsedBytes,
+			FreeBytes:    last.FreeBytes,
+			InodesTotal:  last.InodesTotal,
+			InodesUsed:   last.InodesUsed,
+		}
+		// Диск заполняется при used+free, блоки, зарезервированные для root, в него не входят
+		capacity := float64(last.UsedBytes + last.FreeBytes)
+		if capacity > 0 {
+			f.UsedPercent = math.Round(float64(last.UsedBytes)/capacity*10000) / 100
+		}
+		if last.InodesTotal > 0 {
+			f.InodesUsedPercent = math.Round(float64(last.InodesUsed)/float64(last.InodesTotal)*10000) / 100
+		}
+
+		if len(series) >= cfg.MinSamples {
+			bytesPoints := make([]forecastPoint, len(series))
+			inodePoints := make([]forecastPoint, len(series))
+			origin := series[0].Timestamp
+			for i, s := range series {
+				x := s.Timestamp.Sub(origin).Seconds()
+				bytesPoints[i] = forecastPoint{x: x, y: float64(s.UsedBytes)}
+				inodePoints[i] = forecastPoint{x: x, y: float64(s.InodesUsed)}
+			}
+
+			level, perSec := forecastTrend(method, bytesPoints, cfg.Alpha, cfg.Beta)
+			f.GrowthBytesPerDay = math.Round(perSec * 86400)
+			f.DaysUntilFull, f.FullAt = daysUntilFull(capacity, level, perSec, last.Timestamp)
+
+			if last.InodesTotal > 0 {
+				level, perSec := forecastTrend(method, inodePoints, cfg.Alpha, cfg.Beta)
+				f.InodeGrowthPerDay = math.Round(perSec * 86400)
+				f.InodeDaysUntilFull, f.InodesFullAt = daysUntilFull(float64(last.InodesTotal), level, perSec, last.Timestamp)
+			}
+		}
+
+		forecasts = append(forecasts, f)
+	}
+
+	sort.Slice(forecasts, func(i, j int) bool {
+		return forecasts[i].Mountpoint < forecasts[j].Mountpoint
+	})
+	return forecasts, nil
+}
+
+/* Оценивает текущий уровень ряда и скорость его роста в единицах в секунду выбранным методом */
+func forecastTrend(method string, points []forecastPoint, alpha, beta float64) (float64, float64) {
+	if method == "holt" {
+		return holtTrend(points, alpha, beta)
+	}
+	return linearTrend(points)
+}
+
+/* Линейная регрессия по методу наименьших квадратов, уровень берется на момент последнего замера */
+func linearTrend(points []forecastPoint) (float64, float64) {
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += p.x
+		sumY += p.y
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for _, p := range points {
+		sxx += (p.x - meanX) * (p.x - meanX)
+		sxy += (p.x - meanX) * (p.y - meanY)
+	}
+	if sxx == 0 {
+		return points[len(points)-1].y, 0
+	}
+
+	slope := sxy / sxx
+	return meanY + slope*(points[len(points)-1].x-meanX), slope
+}
+
+/*
+Двойное экспоненциальное сглаживание Холта для неравномерных замеров
+
+	Тренд хранится в единицах в секунду, поэтому пропуски в истории не искажают скорость роста
+*/
+func holtTrend(points []forecastPoint, alpha, beta float64) (float64, float64) {
+	level := points[0].y
+	trend := 0.0
+	if dx := points[1].x - points[0].x; dx > 0 {
+		trend = (points[1].y - points[0].y) / dx
+	}
+
+	for i := 1; i < len(points); i++ {
+		dx := points[i].x - points[i-1].x
+		if dx <= 0 {
+			continue
+		}
+		prevLevel := level
+		level = alpha*points[i].y + (1-alpha)*(level+trend*dx)
+		trend = beta*(level-prevLevel)/dx + (1-beta)*trend
+	}
+	return level, trend
+}
+
+/* Считает, через сколько дней и когда ряд достигнет емкости при текущей скорости роста */
+func daysUntilFull(capacity, level, perSec float64, from time.Time) (*float64, *time.Time) {
+	if perSec <= 0 {
+		return nil, nil
+	}
+	days := (capacity - level) / perSec / 86400
+	if days < 0 {
+		days = 0
+	}
+	if days > diskForecastMaxDays {
+		return nil, nil
+	}
+	days = math.Round(days*100) / 100
+	at := from.Add(time.Duration(days * 86400 * float64(time.Second)))
+	return &days, &at
+}
+
+/* Форматирует количество байт в человекочитаемый вид */
+func formatBytes(value float64) string {
+	units := []string{"Б", "КБ", "МБ", "ГБ", "ТБ"}
+	i := 0
+	for math.Abs(value) >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+/* Точки ряда из пар x, y */
+func forecastPoints(values ...float64) []forecastPoint {
+	points := make([]forecastPoint, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		points = append(points, forecastPoint{x: values[i], y: values[i+1]})
+	}
+	return points
+}
+
+func TestLinearTrend(t *testing.T) {
+	tests := []struct {
+		name      string
+		points    []forecastPoint
+		wantLevel float64
+		wantSlope float64
+	}{
+		{"прямая", forecastPoints(0, 100, 10, 120, 20, 140, 30, 160), 160, 2},
+		{"снижение", forecastPoints(0, 50, 5, 40, 10, 30), 30, -2},
+		{"шум: уровень по прямой, а не по последнему замеру", forecastPoints(0, 0, 1, 2, 2, 1, 3, 3), 2.7, 0.8},
+		{"постоянное значение", forecastPoints(0, 7, 60, 7, 120, 7), 7, 0},
+		{"замеры в один момент", forecastPoints(5, 1, 5, 3), 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, slope := linearTrend(tt.points)
+			if math.Abs(level-tt.wantLevel) > 1e-9 || math.Abs(slope-tt.wantSlope) > 1e-9 {
+				t.Fatalf("linearTrend() = (%v, %v), ожидалось (%v, %v)", level, slope, tt.wantLevel, tt.wantSlope)
+			}
+		})
+	}
+}
+
+func TestHoltTrend(t *testing.T) {
+	tests := []struct {
+		name        string
+		points      []forecastPoint
+		alpha, beta float64
+		wantLevel   float64
+		wantTrend   float64
+	}{
+		{"прямая", forecastPoints(0, 0, 1, 2, 2, 4, 3, 6), 0.3, 0.1, 6, 2},
+		{"прямая с пропуском в истории", forecastPoints(0, 0, 1, 2, 5, 10, 6, 12), 0.3, 0.1, 12, 2},
+		{"постоянное значение", forecastPoints(0, 9, 10, 9, 20, 9), 0.5, 0.5, 9, 0},
+		{"скачок сглаживается", forecastPoints(0, 0, 1, 0, 2, 10), 0.5, 0.5, 5, 2.5},
+		{"без сглаживания", forecastPoints(0, 0, 1, 1, 2, 5), 1, 1, 5, 4},
+		{"повторный замер пропускается", forecastPoints(0, 0, 1, 2, 1, 100, 2, 4), 0.5, 0.5, 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, trend := holtTrend(tt.points, tt.alpha, tt.beta)
+			if math.Abs(level-tt.wantLevel) > 1e-9 || math.Abs(trend-tt.wantTrend) > 1e-9 {
+				t.Fatalf("holtTrend() = (%v, %v), ожидалось (%v, %v)", level, trend, tt.wantLevel, tt.wantTrend)
+			}
+		})
+	}
+}
+
+func TestDaysUntilFull(t *testing.T) {
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		capacity float64
+		level    float64
+		perSec   float64
+		wantDays float64 // -1 - прогноз не строится
+	}{
+		{"один день", 1000, 500, 500.0 / 86400, 1},
+		{"округление до сотых", 1000, 0, 3000.0 / 86400, 0.33},
+		{"уже заполнено", 1000, 1200, 1, 0},
+		{"без роста", 1000, 500, 0, -1},
+		{"уменьшение", 1000, 500, -1, -1},
+		{"дальше горизонта", 1000, 0, 1000.0 / 86400 / (diskForecastMaxDays + 1), -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days, at := daysUntilFull(tt.capacity, tt.level, tt.perSec, from)
+			if tt.wantDays < 0 {
+				if days != nil || at != nil {
+					t.Fatalf("daysUntilFull() = %v, ожидался пустой прогноз", *days)
+				}
+				return
+			}
+			if days == nil || at == nil {
+				t.Fatal("daysUntilFull() не построил прогноз")
+			}
+			wantAt := from.Add(time.Duration(tt.wantDays * 86400 * float64(time.Second)))
+			if *days != tt.wantDays || !at.Equal(wantAt) {
+				t.Fatalf("daysUntilFull() = (%v, %v), ожидалось (%v, %v)", *days, *at, tt.wantDays, wantAt)
+			}
+		})
+	}
+}
+
+func TestGetDiskForecastsCapacity(t *testing.T) {
+	tests := []struct {
+		name        string
+		withFree    bool // false - замеры сохранены до появления free_bytes
+		wantPercent float64
+		wantDays    float64
+	}{
+		// 1000 байт всего, 50 зарезервировано для root: диск заполнится при 950, а не при 1000
+		{"зарезервированные блоки не входят в емкость", true, 60, 0.13},
+		{"старые замеры без free_bytes", false, 57, 0.15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+
+			// 12 замеров раз в 5 минут с ростом 10 байт, то есть 2880 байт в сутки, в последнем занято 570 байт
+			now := time.Now().Truncate(time.Second)
+			for i := 0; i < 12; i++ {
+				used := 460 + 10*i
+				var free interface{}
+				if tt.withFree {
+					free = 950 - used
+				}
+				_, err := db.Exec(
+					"INSERT INTO disk_usage_history (timestamp, mountpoint, device, fstype, total_bytes, used_bytes, free_bytes, inodes_total, inodes_used) VALUES (?, '/data', '/dev/sdb1', 'ext4', 1000, ?, ?, 0, 0)",
+					now.Add(time.Duration(i-11)*5*time.Minute).Format("2006-01-02 15:04:05"), used, free,
+				)
+				if err != nil {
+					t.Fatalf("не удалось сохранить замер: %v", err)
+				}
+			}
+
+			forecasts, err := GetDiskForecasts("linear")
+			if err != nil || len(forecasts) != 1 {
+				t.Fatalf("GetDiskForecasts() = %v, %v", forecasts, err)
+			}
+			f := forecasts[0]
+			if f.UsedPercent != tt.wantPercent {
+				t.Errorf("usedPercent = %v, ожидалось %v", f.UsedPercent, tt.wantPercent)
+			}
+			if f.DaysUntilFull == nil || *f.DaysUntilFull != tt.wantDays {
+				t.Fatalf("daysUntilFull = %v, ожидалось %v", f.DaysUntilFull, tt.wantDays)
+			}
+		})
+	}
+}
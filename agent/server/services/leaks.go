@@ -0,0 +1,294 @@
+/* Сервисы для обнаружения утечек памяти по тренду RSS процессов */
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Настройки детектора утечек памяти */
+type LeakConfig struct {
+	Enabled            bool    `json:"enabled"`
+	SampleIntervalSec  int     `json:"sampleIntervalSec"`  // Как часто сохранять замер RSS процесса
+	WindowHours        float64 `json:"windowHours"`        // За сколько часов строится тренд
+	MinObservationMin  int     `json:"minObservationMin"`  // Сколько минут процесс должен наблюдаться до оценки тренда
+	MinGrowthMBPerHour float64 `json:"minGrowthMBPerHour"` // Минимальный рост RSS для подозрения на утечку
+	MinRSquared        float64 `json:"minRSquared"`        // Минимальный коэффициент детерминации R², насколько рост похож на прямую
+	MinRSSMB           uint64  `json:"minRSSMB"`           // Процессы с меньшим RSS не отслеживаются
+}
+
+/* Оценка тренда памяти одного процесса */
+type ProcessLeak struct {
+	PID               int32      `json:"pid"`
+	Name              string     `json:"name"`
+	Username          string     `json:"username"`
+	CreateTime        int64      `json:"createTime"`
+	CurrentRSS        uint64     `json:"currentRss"`
+	FirstRSS          uint64     `json:"firstRss"`
+	Samples           int        `json:"samples"`
+	ObservedSec       int64      `json:"observedSec"`
+	GrowthMBPerHour   float64    `json:"growthMBPerHour"`
+	RSquared          float64    `json:"rSquared"`
+	Suspected         bool       `json:"suspected"`
+	TimeToOOMSec      *int64     `json:"timeToOomSec"`
+	ProjectedOOMAt    *time.Time `json:"projectedOomAt"`
+	growthBytesPerSec float64
+}
+
+/* Отчет об утечках памяти с прогнозом исчерпания памяти хоста */
+type LeakReport struct {
+	GeneratedAt          time.Time     `json:"generatedAt"`
+	MemoryTotal          uint64        `json:"memoryTotal"`
+	MemoryAvailable      uint64        `json:"memoryAvailable"`
+	TotalGrowthMBPerHour float64       `json:"totalGrowthMBPerHour"` // Суммарный рост процессов с подозрением на утечку
+	TimeToOOMSec         *int64        `json:"timeToOomSec"`
+	ProjectedOOMAt       *time.Time    `json:"projectedOomAt"`
+	Processes            []ProcessLeak `json:"processes"`
+}
+
+/* Замер RSS процесса */
+type rssSample struct {
+	at  time.Time
+	rss uint64
+}
+
+/* Накопленные замеры одного процесса */
+type leakTracker struct {
+	pid        int32
+	name       string
+	username   string
+	createTime int64
+	samples    []rssSample
+}
+
+/* Горизонт, дальше которого прогноз исчерпания памяти не имеет смысла */
+const leakMaxProjection = 365 * 24 * time.Hour
+
+var (
+	leakConfig = LeakConfig{
+		Enabled:            true,
+		SampleIntervalSec:  60,
+		WindowHours:        6,
+		MinObservationMin:  60,
+		MinGrowthMBPerHour: 10,
+		MinRSquared:        0.8,
+		MinRSSMB:           20,
+	}
+	leakTrackers = make(map[string]*leakTracker)
+	leakMutex    sync.Mutex
+)
+
+/* Устанавливает настройки детектора утечек памяти */
+func SetLeakConfig(cfg LeakConfig) error {
+	if cfg.SampleIntervalSec <= 0 {
+		return fmt.Errorf("sampleIntervalSec должен быть больше 0")
+	}
+	if cfg.WindowHours <= 0 || cfg.WindowHours > 72 {
+		return fmt.Errorf("windowHours должен быть в диапазоне от 0 до 72 часов")
+	}
+	if cfg.MinObservationMin < 0 || cfg.MinGrowthMBPerHour < 0 {
+		return fmt.Errorf("параметры детектора не могут быть отрицательными")
+	}
+	if cfg.MinRSquared < 0 || cfg.MinRSquared > 1 {
+		return fmt.Errorf("minRSquared должен быть в диапазоне от 0 до 1")
+	}
+
+	leakMutex.Lock()
+	defer leakMutex.Unlock()
+	leakConfig = cfg
+	return nil
+}
+
+/* Возвращает текущие настройки детектора утечек памяти */
+func GetLeakConfig() LeakConfig {
+	leakMutex.Lock()
+	defer leakMutex.Unlock()
+	return leakConfig
+}
+
+/*
+Сохраняет замеры RSS процессов из очередного снимка
+
+	Вызывается при каждом обновлении кэша процессов, замер сохраняется не чаще sampleIntervalSec,
+	замеры старше окна тренда отбрасываются, завершившиеся процессы забываются
+*/
+func TrackProcessMemory(procs []models.ProcessInfo) {
+	now := time.Now()
+
+	leakMutex.Lock()
+	defer leakMutex.Unlock()
+
+	cfg := leakConfig
+	if !cfg.Enabled {
+		if len(leakTrackers) > 0 {
+			leakTrackers = make(map[string]*leakTracker)
+		}
+		return
+	}
+
+	interval := time.Duration(cfg.SampleIntervalSec) * time.Second
+	windowStart := now.Add(-time.Duration(cfg.WindowHours * float64(time.Hour)))
+	seen := make(map[string]bool, len(procs))
+
+	for i := range procs {
+		p := &procs[i]
+		key := fmt.Sprintf("%d:%d", p.PID, p.CreateTime)
+
+		tracker, ok := leakTrackers[key]
+		if !ok {
+			if p.MemoryRSS < cfg.MinRSSMB*1024*1024 {
+				continue
+			}
+			tracker = &leakTracker{pid: p.PID, createTime: p.CreateTime}
+			leakTrackers[key] = tracker
+		}
+		seen[key] = true
+		tracker.name = p.Name
+		tracker.username = p.Username
+
+		if n := len(tracker.samples); n > 0 && now.Sub(tracker.samples[n-1].at) < interval {
+			continue
+		}
+		tracker.samples = append(tracker.samples, rssSample{at: now, rss: p.MemoryRSS})
+
+		drop := 0
+		for drop < len(tracker.samples) && tracker.samples[drop].at.Before(windowStart) {
+			drop++
+		}
+		if drop > 0 {
+			tracker.samples = append(tracker.samples[:0], tracker.samples[drop:]...)
+		}
+	}
+
+	for key := range leakTrackers {
+		if !seen[key] {
+			delete(leakTrackers, key)
+		}
+	}
+}
+
+/*
+Формирует отчет об утечках памяти
+
+	Для каждого процесса строится линейная регрессия RSS по времени, процесс считается подозрительным,
+	если он наблюдается достаточно долго, растет быстрее minGrowthMBPerHour и рост близок к прямой (R² не меньше minRSquared).
+	Время до исчерпания памяти считается как доступная память, деленная на скорость роста.
+	При includeAll в отчет попадают все процессы с оцененным трендом, иначе только подозрительные
+*/
+func GetLeakReport(includeAll bool) (LeakReport, error) {
+	available, total, err := getmetrics.AvailableMemory()
+	if err != nil {
+		return LeakReport{}, fmt.Errorf("ошибка получения доступной памяти: %v", err)
+	}
+
+	now := time.Now()
+	report := LeakReport{
+		GeneratedAt:     now,
+		MemoryTotal:     total,
+		MemoryAvailable: available,
+		Processes:       []ProcessLeak{},
+	}
+
+	leakMutex.Lock()
+	cfg := leakConfig
+	minObservation := time.Duration(cfg.MinObservationMin) * time.Minute
+	var totalGrowth float64
+
+	for _, tracker := range leakTrackers {
+		if len(tracker.samples) < 3 {
+			continue
+		}
+		first := tracker.samples[0]
+		last := tracker.samples[len(tracker.samples)-1]
+		observed := last.at.Sub(first.at)
+		if observed <= 0 || observed < minObservation {
+			continue
+		}
+
+		slope, r2 := rssTrend(tracker.samples)
+		leak := ProcessLeak{
+			PID:               tracker.pid,
+			Name:              tracker.name,
+			Username:          tracker.username,
+			CreateTime:        tracker.createTime,
+			CurrentRSS:        last.rss,
+			FirstRSS:          first.rss,
+			Samples:           len(tracker.samples),
+			ObservedSec:       int64(observed.Seconds()),
+			GrowthMBPerHour:   math.Round(slope*3600/1024/1024*100) / 100,
+			RSquared:          math.Round(r2*1000) / 1000,
+			growthBytesPerSec: slope,
+		}
+		leak.Suspected = slope*3600/1024/1024 >= cfg.MinGrowthMBPerHour && slope > 0 && r2 >= cfg.MinRSquared
+		if leak.Suspected {
+			totalGrowth += slope
+		}
+		if slope > 0 {
+			leak.TimeToOOMSec, leak.ProjectedOOMAt = projectOOM(available, slope, now)
+		}
+
+		if leak.Suspected || includeAll {
+			report.Processes = append(report.Processes, leak)
+		}
+	}
+	leakMutex.Unlock()
+
+	sort.Slice(report.Processes, func(i, j int) bool {
+		return report.Processes[i].growthBytesPerSec > report.Processes[j].growthBytesPerSec
+	})
+
+	report.TotalGrowthMBPerHour = math.Round(totalGrowth*3600/1024/1024*100) / 100
+	if totalGrowth > 0 {
+		report.TimeToOOMSec, report.ProjectedOOMAt = projectOOM(available, totalGrowth, now)
+	}
+
+	return report, nil
+}
+
+/* Строит линейную регрессию RSS по времени, возвращает наклон в байтах в секунду и коэффициент детерминации */
+func rssTrend(samples []rssSample) (float64, float64) {
+	origin := samples[0].at
+	n := float64(len(samples))
+
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.at.Sub(origin).Seconds()
+		sumY += float64(s.rss)
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for _, s := range samples {
+		dx := s.at.Sub(origin).Seconds() - meanX
+		dy := float64(s.rss) - meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+
+	slope := sxy / sxx
+	if syy == 0 {
+		return slope, 0
+	}
+	return slope, (sxy * sxy) / (sxx * syy)
+}
+
+/* Оценивает, через сколько секунд и когда будет исчерпана доступная память при заданной скорости роста, прогноз дальше года не строится */
+func projectOOM(available uint64, bytesPerSec float64, now time.Time) (*int64, *time.Time) {
+	seconds := float64(available) / bytesPerSec
+	if seconds > leakMaxProjection.Seconds() {
+		return nil, nil
+	}
+	secs := int64(seconds)
+	at := now.Add(time.Duration(secs) * time.Second)
+	return &secs, &at
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRSSTrend(t *testing.T) {
+	origin := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	/* Замеры RSS через одинаковые промежутки step секунд */
+	series := func(step int, values ...uint64) []rssSample {
+		samples := make([]rssSample, len(values))
+		for i, value := range values {
+			samples[i] = rssSample{at: origin.Add(time.Duration(i*step) * time.Second), rss: value}
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name      string
+		samples   []rssSample
+		wantSlope float64
+		wantR2    float64
+	}{
+		{"равномерный рост", series(60, 1000, 1600, 2200, 2800, 3400), 10, 1},
+		{"равномерное снижение", series(10, 500, 450, 400, 350), -5, 1},
+		{"постоянное значение", series(60, 4096, 4096, 4096), 0, 0},
+		{"рост с шумом", series(1, 0, 2, 1, 3), 0.8, 0.64},
+		{"замеры в один момент", []rssSample{{origin, 100}, {origin, 200}}, 0, 0},
+		{
+			"неравномерные замеры",
+			[]rssSample{{origin, 0}, {origin.Add(10 * time.Second), 100}, {origin.Add(40 * time.Second), 400}},
+			10, 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slope, r2 := rssTrend(tt.samples)
+			if math.Abs(slope-tt.wantSlope) > 1e-9 || math.Abs(r2-tt.wantR2) > 1e-9 {
+				t.Fatalf("rssTrend() = (%v, %v), ожидалось (%v, %v)", slope, r2, tt.wantSlope, tt.wantR2)
+			}
+		})
+	}
+}
+
+func TestProjectOOM(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		available   uint64
+		bytesPerSec float64
+		wantSec     int64 // -1 - прогноз не строится
+	}{
+		{"час до исчерпания", 3600 * 1024, 1024, 3600},
+		{"дробные секунды отбрасываются", 1000, 3, 333},
+		{"ровно год", uint64(leakMaxProjection.Seconds()), 1, int64(leakMaxProjection.Seconds())},
+		{"дальше года", uint64(leakMaxProjection.Seconds()) + 1, 1, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secs, at := projectOOM(tt.available, tt.bytesPerSec, now)
+			if tt.wantSec < 0 {
+				if secs != nil || at != nil {
+					t.Fatalf("projectOOM() = %v, ожидался пустой прогноз", *secs)
+				}
+				return
+			}
+			if secs == nil || at == nil {
+				t.Fatal("projectOOM() не построил прогноз")
+			}
+			if *secs != tt.wantSec || !at.Equal(now.Add(time.Duration(tt.wantSec)*time.Second)) {
+				t.Fatalf("projectOOM() = (%d, %v), ожидалось %d секунд", *secs, *at, tt.wantSec)
+			}
+		})
+	}
+}
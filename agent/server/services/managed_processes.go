@@ -0,0 +1,655 @@
+/* Сервисы для запуска процессов под управлением агента с политиками перезапуска */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+/*
+Процесс под управлением агента
+
+	restartPolicy определяет, что делать после завершения процесса: never - не перезапускать, on-failure - перезапускать
+	при ненулевом коде выхода или завершении сигналом, always - перезапускать всегда. Перед перезапуском агент ждет
+	backoffSec секунд, каждый следующий подряд перезапуск ждет вдвое дольше. Параметры запуска (env, user, rlimits,
+	maxRuntimeSec, stdin) применяются при каждом запуске, остановка по maxRuntimeSec считается завершением с ошибкой.
+	В ответах API значения env и stdin заменяются маской
+*/
+type ManagedProcess struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	Name          string     `json:"name"`
+	Command       string     `json:"command"`
+	Args          []string   `json:"args"`
+	Cwd           string     `json:"cwd,omitempty"`
+	RestartPolicy string     `json:"restartPolicy"`
+	MaxRestarts   int        `json:"maxRestarts"` // Перезапусков подряд до перехода в failed, 0 - без ограничения
+	BackoffSec    int        `json:"backoffSec"`
+	Status        string     `json:"status"` // running, backoff, stopped, exited или failed
+	PID           int32      `json:"pid,omitempty"`
+	Restarts      int        `json:"restarts"` // Перезапусков подряд, сбрасывается после стабильной работы
+	LastStartedAt *time.Time `json:"lastStartedAt"`
+	LastExitedAt  *time.Time `json:"lastExitedAt"`
+	LastExitCode  *int       `json:"lastExitCode"`
+	LastError     string     `json:"lastError,omitempty"`
+	pidCreateTime int64
+
+	models.ProcessLaunchOptions
+}
+
+/* Запущенный процесс, за которым следит агент */
+type managedRunner struct {
+	id        int64
+	cmd       *exec.Cmd // nil, если процесс запущен предыдущим экземпляром агента
+	pid       int32
+	output    *processLog
+	startedAt time.Time
+	stopLimit func() // Отменяет остановку по maxRuntimeSec
+	stopping  bool
+	restart   bool
+	wake      chan struct{}
+	done      chan struct{} // Закрывается, когда runner перестает следить за процессом
+}
+
+/* Задержки перезапуска и время работы, после которого счетчик перезапусков сбрасывается */
+const (
+	defaultManagedBackoffSec = 1
+	maxManagedBackoff        = 60 * time.Second
+	managedStableRunTime     = 60 * time.Second
+	managedPollInterval      = time.Second
+	managedStopGraceSec      = 10
+)
+
+var (
+	managedRestartPolicies = map[string]bool{"never": true, "on-failure": true, "always": true}
+	managedRunners         = make(map[int64]*managedRunner)
+	managedMutex           sync.Mutex
+	managedRestoreOnce     sync.Once
+)
+
+/* Проверяет параметры управляемого процесса и заполняет значения по умолчанию */
+func validateManagedProcess(mp *ManagedProcess) error {
+	if err := validateProcessLaunch(mp.Command, mp.Args, mp.Cwd); err != nil {
+		return err
+	}
+	if err := validateLaunchOptions(mp.ProcessLaunchOptions); err != nil {
+		return err
+	}
+	if mp.RestartPolicy == "" {
+		mp.RestartPolicy = "on-failure"
+	}
+	if !managedRestartPolicies[mp.RestartPolicy] {
+		return fmt.Errorf("restartPolicy должен быть never, on-failure или always")
+	}
+	if mp.MaxRestarts < 0 {
+		return fmt.Errorf("maxRestarts не может быть отрицательным")
+	}
+	if mp.BackoffSec == 0 {
+		mp.BackoffSec = defaultManagedBackoffSec
+	}
+	if mp.BackoffSec < 0 || time.Duration(mp.BackoffSec)*time.Second > maxManagedBackoff {
+		return fmt.Errorf("backoffSec должен быть от 1 до %d секунд", int(maxManagedBackoff.Seconds()))
+	}
+	if mp.Name == "" {
+		mp.Name = filepath.Base(mp.Command)
+	}
+	return nil
+}
+
+/*
+Запускает процесс под управлением агента и сохраняет его в базе данных, чтобы восстановить после перезапуска агента
+
+	Ошибка первого запуска возвращается сразу, и процесс не сохраняется
+*/
+func CreateManagedProcess(mp ManagedProcess) (*ManagedProcess, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+	if err := validateManagedProcess(&mp); err != nil {
+		return nil, err
+	}
+
+	argsJSON, err := json.Marshal(mp.Args)
+	if err != nil {
+		return nil, err
+	}
+	optionsJSON, err := json.Marshal(mp.ProcessLaunchOptions)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.Exec(
+		"INSERT INTO managed_processes (created_at, name, command, args, cwd, options, restart_policy, max_restarts, backoff_sec, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'running')",
+		time.Now().Format("2006-01-02 15:04:05"),
+		mp.Name,
+		mp.Command,
+		string(argsJSON),
+		mp.Cwd,
+		string(optionsJSON),
+		mp.RestartPolicy,
+		mp.MaxRestarts,
+		mp.BackoffSec,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mp.ID, _ = result.LastInsertId()
+
+	runner := newManagedRunner(mp.ID)
+	managedMutex.Lock()
+	err = runner.start(&mp, 0)
+	managedMutex.Unlock()
+	if err != nil {
+		db.Exec("DELETE FROM managed_processes WHERE id = ?", mp.ID)
+		return nil, fmt.Errorf("не удалось запустить: %w", err)
+	}
+	go superviseManagedProcess(runner)
+
+	return GetManagedProcess(mp.ID)
+}
+
+/* Создает runner для управляемого процесса */
+func newManagedRunner(id int64) *managedRunner {
+	return &managedRunner{id: id, startedAt: time.Now(), wake: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+/* Запускает команду управляемого процесса и регистрирует runner, вызывается под managedMutex */
+func (r *managedRunner) start(mp *ManagedProcess, restarts int) error {
+	cmd := exec.Command(mp.Command, mp.Args...)
+	if mp.Cwd != "" {
+		cmd.Dir = mp.Cwd
+	}
+	detachManagedCommand(cmd)
+	helper, err := applyLaunchOptions(cmd, mp.ProcessLaunchOptions)
+	if err != nil {
+		return err
+	}
+
+	output, err := newProcessLog()
+	if err != nil {
+		helper.abort()
+		return err
+	}
+	cmd.Stdout = output.writer
+	cmd.Stderr = output.writer
+
+	if err := cmd.Start(); err != nil {
+		helper.abort()
+		output.discard()
+		return err
+	}
+	if err := helper.confirm(cmd); err != nil {
+		output.discard()
+		return err
+	}
+
+	r.cmd = cmd
+	r.pid = int32(cmd.Process.Pid)
+	r.output = output
+	r.startedAt = time.Now()
+	r.stopLimit = startRuntimeLimit(r.pid, r.startedAt, mp.MaxRuntimeSec, output)
+	managedRunners[r.id] = r
+	output.open(r.pid, fmt.Sprintf("процесс %s (ID=%d) запущен: %s", mp.Name, mp.ID, formatManagedCommand(mp)))
+
+	var createTime int64
+	if proc, err := process.NewProcess(r.pid); err == nil {
+		createTime, _ = proc.CreateTime()
+	}
+	updateManagedProcess(r.id,
+		"status = 'running', pid = ?, pid_create_time = ?, restarts = ?, last_started_at = ?, last_error = NULL",
+		r.pid, createTime, restarts, r.startedAt.Format("2006-01-02 15:04:05"))
+	log.Printf("Управляемый процесс %s (ID=%d) запущен, PID=%d: %s", mp.Name, mp.ID, r.pid, formatManagedCommand(mp))
+	return nil
+}
+
+/* Обновляет поля управляемого процесса в базе данных */
+func updateManagedProcess(id int64, set string, args ...interface{}) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+	if _, err := db.Exec("UPDATE managed_processes SET "+set+" WHERE id = ?", append(args, id)...); err != nil {
+		log.Printf("Ошибка обновления управляемого процесса %d: %v", id, err)
+	}
+}
+
+/* Ждет завершения процесса и возвращает код выхода и описание ошибки, для процесса предыдущего экземпляра агента код неизвестен */
+func (r *managedRunner) wait() (*int, string) {
+	exitCode, exitErr := r.waitProcess()
+	if r.stopLimit != nil {
+		r.stopLimit()
+	}
+	if r.output != nil {
+		r.output.close("процесс завершился: " + describeManagedExit(exitCode, exitErr))
+	}
+	return exitCode, exitErr
+}
+
+/* Ждет завершения процесса: своего через Wait, унаследованного от предыдущего экземпляра агента - опросом */
+func (r *managedRunner) waitProcess() (*int, string) {
+	if r.cmd != nil {
+		r.cmd.Wait()
+		code := r.cmd.ProcessState.ExitCode()
+		if r.cmd.ProcessState.Success() {
+			return &code, ""
+		}
+		return &code, r.cmd.ProcessState.String()
+	}
+
+	if proc, err := process.NewProcess(r.pid); r.pid > 0 && err == nil {
+		for !processStopped(proc) {
+			time.Sleep(managedPollInterval)
+		}
+	}
+	return nil, "процесс завершился, код выхода неизвестен"
+}
+
+/* Возвращает задержку перед перезапуском: backoffSec, удвоенный за каждый перезапуск подряд, но не больше maxManagedBackoff */
+func managedRestartDelay(backoffSec, restarts int) time.Duration {
+	delay := time.Duration(backoffSec) * time.Second
+	for i := 0; i < restarts && delay < maxManagedBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxManagedBackoff {
+		delay = maxManagedBackoff
+	}
+	return delay
+}
+
+/* Удаляет процесс из списка наблюдаемых, если им все еще управляет этот runner */
+func finishManagedRunner(runner *managedRunner) {
+	managedMutex.Lock()
+	if managedRunners[runner.id] == runner {
+		delete(managedRunners, runner.id)
+	}
+	managedMutex.Unlock()
+	close(runner.done)
+}
+
+/*
+Следит за управляемым процессом: после завершения записывает код выхода и перезапускает процесс по его политике
+
+	Перезапуск, запрошенный через RestartManagedProcess, выполняется сразу и сбрасывает счетчик перезапусков
+*/
+func superviseManagedProcess(runner *managedRunner) {
+	defer finishManagedRunner(runner)
+
+	for {
+		exitCode, exitErr := runner.wait()
+		ranFor := time.Since(runner.startedAt)
+
+		mp, err := loadManagedProcess(runner.id)
+		if err != nil || mp == nil {
+			return
+		}
+
+		managedMutex.Lock()
+		stopping, restart := runner.stopping, runner.restart
+		runner.restart = false
+		runner.cmd, runner.pid, runner.output = nil, 0, nil
+		// Сигнал wake от остановки или перезапуска уже учтен во флагах, иначе следующее падение пропустит задержку
+		select {
+		case <-runner.wake:
+		default:
+		}
+		managedMutex.Unlock()
+
+		updateManagedProcess(runner.id, "pid = NULL, last_exited_at = ?, last_exit_code = ?, last_error = ?",
+			time.Now().Format("2006-01-02 15:04:05"), exitCode, nullableString(exitErr))
+		log.Printf("Управляемый процесс %s (ID=%d) завершился: %s", mp.Name, mp.ID, describeManagedExit(exitCode, exitErr))
+
+		if stopping {
+			updateManagedProcess(runner.id, "status = 'stopped'")
+			return
+		}
+
+		restarts := mp.Restarts
+		if restart {
+			restarts = 0
+		} else {
+			failed := exitCode == nil || *exitCode != 0
+			if mp.RestartPolicy == "never" || (mp.RestartPolicy == "on-failure" && !failed) {
+				updateManagedProcess(runner.id, "status = 'exited'")
+				return
+			}
+			if ranFor >= managedStableRunTime {
+				restarts = 0
+			}
+			if mp.MaxRestarts > 0 && restarts >= mp.MaxRestarts {
+				updateManagedProcess(runner.id, "status = 'failed', last_error = ?",
+					fmt.Sprintf("превышено число перезапусков подряд (%d)", mp.MaxRestarts))
+				log.Printf("Управляемый процесс %s (ID=%d) не будет перезапущен: превышено число перезапусков", mp.Name, mp.ID)
+				return
+			}
+
+			delay := managedRestartDelay(mp.BackoffSec, restarts)
+			updateManagedProcess(runner.id, "status = 'backoff', restarts = ?", restarts)
+			select {
+			case <-time.After(delay):
+			case <-runner.wake:
+			}
+
+			managedMutex.Lock()
+			stopping, restart = runner.stopping, runner.restart
+			runner.restart = false
+			managedMutex.Unlock()
+			if stopping {
+				updateManagedProcess(runner.id, "status = 'stopped'")
+				return
+			}
+			if restart {
+				restarts = 0
+			} else {
+				restarts++
+			}
+		}
+
+		for {
+			managedMutex.Lock()
+			if runner.stopping {
+				managedMutex.Unlock()
+				updateManagedProcess(runner.id, "status = 'stopped'")
+				return
+			}
+			err := runner.start(mp, restarts)
+			managedMutex.Unlock()
+			if err == nil {
+				break
+			}
+
+			log.Printf("Не удалось перезапустить управляемый процесс %s (ID=%d): %v", mp.Name, mp.ID, err)
+			if mp.MaxRestarts > 0 && restarts >= mp.MaxRestarts {
+				updateManagedProcess(runner.id, "status = 'failed', last_error = ?", "не удалось запустить: "+err.Error())
+				return
+			}
+			updateManagedProcess(runner.id, "status = 'backoff', restarts = ?, last_error = ?", restarts, "не удалось запустить: "+err.Error())
+			select {
+			case <-time.After(managedRestartDelay(mp.BackoffSec, restarts)):
+			case <-runner.wake:
+			}
+			restarts++
+		}
+	}
+}
+
+/* Описывает завершение процесса для журнала */
+func describeManagedExit(exitCode *int, exitErr string) string {
+	if exitErr != "" {
+		return exitErr
+	}
+	return fmt.Sprintf("код выхода %d", *exitCode)
+}
+
+/* Возвращает nil для пустой строки, чтобы записать в базу NULL */
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+/*
+Останавливает управляемый процесс без перезапуска: SIGTERM его группе процессов, затем SIGKILL через managedStopGraceSec секунд
+
+	Остановленный процесс не запускается после перезапуска агента
+*/
+func StopManagedProcess(id int64) (*ManagedProcess, error) {
+	mp, err := GetManagedProcess(id)
+	if err != nil {
+		return nil, err
+	}
+	if mp == nil {
+		return nil, fmt.Errorf("управляемый процесс с ID %d не найден", id)
+	}
+
+	if err := stopManagedRunner(id, false); err != nil {
+		return nil, err
+	}
+	updateManagedProcess(id, "status = 'stopped'")
+	return GetManagedProcess(id)
+}
+
+/*
+Отмечает runner остановленным или перезапускаемым и завершает его процесс вместе с потомками из его группы
+
+	При остановке ждет, пока runner запишет результат и перестанет следить за процессом,
+	чтобы следующий запуск не пересекался с ним
+*/
+func stopManagedRunner(id int64, restart bool) error {
+	managedMutex.Lock()
+	runner, ok := managedRunners[id]
+	if !ok {
+		managedMutex.Unlock()
+		return nil
+	}
+	if restart {
+		runner.restart = true
+	} else {
+		runner.stopping = true
+	}
+	pid := runner.pid
+	select {
+	case runner.wake <- struct{}{}:
+	default:
+	}
+	managedMutex.Unlock()
+
+	if proc, err := process.NewProcess(pid); pid > 0 && err == nil && !processStopped(proc) {
+		result, err := stopProcessGroup(pid, managedStopGraceSec)
+		if err != nil {
+			return fmt.Errorf("не удалось остановить процесс %d: %w", pid, err)
+		}
+		if result.Outcome == "running" {
+			return fmt.Errorf("процесс %d не остановлен: %s", pid, result.Message)
+		}
+	}
+
+	if !restart {
+		<-runner.done
+	}
+	return nil
+}
+
+/* Перезапускает управляемый процесс: останавливает текущий экземпляр и сразу запускает новый, счетчик перезапусков сбрасывается */
+func RestartManagedProcess(id int64) (*ManagedProcess, error) {
+	mp, err := loadManagedProcess(id)
+	if err != nil {
+		return nil, err
+	}
+	if mp == nil {
+		return nil, fmt.Errorf("управляемый процесс с ID %d не найден", id)
+	}
+
+	managedMutex.Lock()
+	_, supervised := managedRunners[id]
+	if !supervised {
+		if err := validateProcessLaunch(mp.Command, mp.Args, mp.Cwd); err != nil {
+			managedMutex.Unlock()
+			return nil, err
+		}
+		if err := validateLaunchOptions(mp.ProcessLaunchOptions); err != nil {
+			managedMutex.Unlock()
+			return nil, err
+		}
+		runner := newManagedRunner(id)
+		err := runner.start(mp, 0)
+		managedMutex.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось запустить: %w", err)
+		}
+		go superviseManagedProcess(runner)
+		return GetManagedProcess(id)
+	}
+	managedMutex.Unlock()
+
+	if err := stopManagedRunner(id, true); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(killWaitTimeout)
+	for time.Now().Before(deadline) {
+		if current, err := GetManagedProcess(id); err == nil && current != nil && current.Status == "running" && current.PID != 0 && current.PID != mp.PID {
+			return current, nil
+		}
+		time.Sleep(stopPollInterval)
+	}
+	return GetManagedProcess(id)
+}
+
+/* Останавливает управляемый процесс и удаляет его из базы данных */
+func RemoveManagedProcess(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+	mp, err := GetManagedProcess(id)
+	if err != nil {
+		return err
+	}
+	if mp == nil {
+		return fmt.Errorf("управляемый процесс с ID %d не найден", id)
+	}
+
+	if err := stopManagedRunner(id, false); err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM managed_processes WHERE id = ?", id)
+	return err
+}
+
+/* Получает список управляемых процессов в порядке создания, значения env и stdin заменены маской */
+func GetManagedProcesses() ([]ManagedProcess, error) {
+	processes, err := queryManagedProcesses("")
+	for i := range processes {
+		processes[i].ProcessLaunchOptions = maskLaunchOptions(processes[i].ProcessLaunchOptions)
+	}
+	return processes, err
+}
+
+/* Получает управляемый процесс по ID, значения env и stdin заменены маской, возвращает nil если процесс не найден */
+func GetManagedProcess(id int64) (*ManagedProcess, error) {
+	mp, err := loadManagedProcess(id)
+	if mp != nil {
+		mp.ProcessLaunchOptions = maskLaunchOptions(mp.ProcessLaunchOptions)
+	}
+	return mp, err
+}
+
+/* Читает управляемый процесс по ID с исходными параметрами запуска для перезапуска, возвращает nil если процесс не найден */
+func loadManagedProcess(id int64) (*ManagedProcess, error) {
+	processes, err := queryManagedProcesses(" WHERE id = ?", id)
+	if err != nil || len(processes) == 0 {
+		return nil, err
+	}
+	return &processes[0], nil
+}
+
+/* Читает управляемые процессы с дополнительным условием запроса */
+func queryManagedProcesses(where string, args ...interface{}) ([]ManagedProcess, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	rows, err := db.Query(
+		"SELECT id, created_at, name, command, args, cwd, options, restart_policy, max_restarts, backoff_sec, status, pid, pid_create_time, restarts, last_started_at, last_exited_at, last_exit_code, last_error FROM managed_processes"+where+" ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	processes := []ManagedProcess{}
+	for rows.Next() {
+		var mp ManagedProcess
+		var createdAt string
+		var argsJSON, cwd, optionsJSON, lastStartedAt, lastExitedAt, lastError sql.NullString
+		var pid, pidCreateTime, lastExitCode sql.NullInt64
+
+		if err := rows.Scan(&mp.ID, &createdAt, &mp.Name, &mp.Command, &argsJSON, &cwd, &optionsJSON, &mp.RestartPolicy, &mp.MaxRestarts, &mp.BackoffSec, &mp.Status, &pid, &pidCreateTime, &mp.Restarts, &lastStartedAt, &lastExitedAt, &lastExitCode, &lastError); err != nil {
+			return nil, err
+		}
+
+		if parsed, ok := parseDBTime(createdAt); ok {
+			mp.CreatedAt = parsed
+		}
+		if parsed, ok := parseDBTime(lastStartedAt.String); ok {
+			mp.LastStartedAt = &parsed
+		}
+		if parsed, ok := parseDBTime(lastExitedAt.String); ok {
+			mp.LastExitedAt = &parsed
+		}
+		if lastExitCode.Valid {
+			code := int(lastExitCode.Int64)
+			mp.LastExitCode = &code
+		}
+		mp.Args = []string{}
+		if argsJSON.Valid {
+			json.Unmarshal([]byte(argsJSON.String), &mp.Args)
+		}
+		if optionsJSON.Valid {
+			json.Unmarshal([]byte(optionsJSON.String), &mp.ProcessLaunchOptions)
+		}
+		mp.Cwd = cwd.String
+		mp.PID = int32(pid.Int64)
+		mp.pidCreateTime = pidCreateTime.Int64
+		mp.LastError = lastError.String
+		processes = append(processes, mp)
+	}
+	return processes, rows.Err()
+}
+
+/*
+Восстанавливает управляемые процессы после запуска агента, повторные вызовы ничего не делают
+
+	Процесс, который продолжает работать после перезапуска агента, берется под наблюдение без повторного запуска.
+	Процессы, завершившиеся пока агент был выключен, перезапускаются по своей политике
+*/
+func RestoreManagedProcesses() {
+	managedRestoreOnce.Do(func() {
+		processes, err := queryManagedProcesses(" WHERE status IN ('running', 'backoff')")
+		if err != nil {
+			log.Printf("Ошибка восстановления управляемых процессов: %v", err)
+			return
+		}
+
+		for i := range processes {
+			mp := &processes[i]
+			runner := newManagedRunner(mp.ID)
+			if mp.LastStartedAt != nil {
+				runner.startedAt = *mp.LastStartedAt
+			}
+			if mp.PID > 0 {
+				if proc, err := process.NewProcess(mp.PID); err == nil && !processStopped(proc) {
+					if createTime, err := proc.CreateTime(); err == nil && createTime == mp.pidCreateTime {
+						runner.pid = mp.PID
+						runner.output = resumeProcessLog(mp.PID)
+						runner.stopLimit = startRuntimeLimit(mp.PID, runner.startedAt, mp.MaxRuntimeSec, runner.output)
+						log.Printf("Управляемый процесс %s (ID=%d, PID=%d) продолжает работать, наблюдение восстановлено", mp.Name, mp.ID, mp.PID)
+					}
+				}
+			}
+
+			managedMutex.Lock()
+			managedRunners[mp.ID] = runner
+			managedMutex.Unlock()
+			go superviseManagedProcess(runner)
+		}
+	})
+}
+
+/* Возвращает команду с аргументами для записи в журнал */
+func formatManagedCommand(mp *ManagedProcess) string {
+	return strings.TrimSpace(mp.Command + " " + strings.Join(mp.Args, " "))
+}
@@ -25,19 +25,47 @@ func GetDB() *sql.DB {
 	return dbInstance
 }
 
+/*
+Разбирает время, прочитанное из колонки DATETIME
+
+	Время хранится как локальное, но драйвер sqlite возвращает его в формате RFC3339 с зоной UTC
+*/
+func parseDBTime(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return time.Date(parsed.Year(), parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), parsed.Second(), 0, time.Local), true
+		}
+	}
+	return time.Time{}, false
+}
+
 /* Сохраняет метрики CPU и памяти в базу данных с текущей временной меткой */
+/* Нагрузка и сетевой трафик берутся из последнего снимка хоста, если он уже был снят */
 func SaveMetricsHistory(cpuPercent, memoryPercent float64, memoryUsedMB, memoryTotalMB uint64) error {
 	db := GetDB()
 	if db == nil {
 		return nil
 	}
+
+	var load1, netRx, netTx interface{}
+	if sample, ok := LatestHostSample(); ok {
+		load1 = sample.Load1
+		if sample.HasNetwork {
+			netRx = sample.NetRxBps
+			netTx = sample.NetTxBps
+		}
+	}
+
 	_, err := db.Exec(
-		"INSERT INTO metrics_history (timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb) VALUES (?, ?, ?, ?, ?)",
+		"INSERT INTO metrics_history (timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb, load1, net_rx_bps, net_tx_bps) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		time.Now().Format("2006-01-02 15:04:05"),
 		cpuPercent,
 		memoryPercent,
 		memoryUsedMB,
 		memoryTotalMB,
+		load1,
+		netRx,
+		netTx,
 	)
 	return err
 }
@@ -57,7 +85,7 @@ func GetMetricsHistory(from, to time.Time, limit int) ([]map[string]interface{},
 
 	if limit > 0 {
 		query := `
-			SELECT timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb
+			SELECT timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb, load1, net_rx_bps, net_tx_bps
 			FROM metrics_history
 			WHERE timestamp >= ? AND timestamp <= ?
 			ORDER BY timestamp DESC
@@ -66,7 +94,7 @@ func GetMetricsHistory(from, to time.Time, limit int) ([]map[string]interface{},
 		rows, err = db.Query(query, from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05"), limit)
 	} else {
 		query := `
-			SELECT timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb
+			SELECT timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb, load1, net_rx_bps, net_tx_bps
 			FROM metrics_history
 			WHERE timestamp >= ? AND timestamp <= ?
 			ORDER BY timestamp DESC
@@ -83,8 +111,9 @@ func GetMetricsHistory(from, to time.Time, limit int) ([]map[string]interface{},
 		var timestamp string
 		var cpuPercent, memoryPercent float64
 		var memoryUsedMB, memoryTotalMB int64
+		var load1, netRx, netTx sql.NullFloat64
 
-		if err := rows.Scan(&timestamp, &cpuPercent, &memoryPercent, &memoryUsedMB, &memoryTotalMB); err != nil {
+		if err := rows.Scan(&timestamp, &cpuPercent, &memoryPercent, &memoryUsedMB, &memoryTotalMB, &load1, &netRx, &netTx); err != nil {
 			return nil, err
 		}
 
@@ -94,6 +123,9 @@ func GetMetricsHistory(from, to time.Time, limit int) ([]map[string]interface{},
 			"memoryPercent": memoryPercent,
 			"memoryUsedMB":  memoryUsedMB,
 			"memoryTotalMB": memoryTotalMB,
+			"load1":         nullableFloat(load1),
+			"netRxBps":      nullableFloat(netRx),
+			"netTxBps":      nullableFloat(netTx),
 		})
 	}
 
@@ -120,3 +152,11 @@ func ClearMetricsHistory() error {
 	_, err := db.Exec("DELETE FROM metrics_history")
 	return err
 }
+
+/* Возвращает значение колонки или nil, если в записи его нет */
+func nullableFloat(value sql.NullFloat64) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.Float64
+}
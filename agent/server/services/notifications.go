@@ -0,0 +1,565 @@
+/* Сервисы для управления каналами уведомлений и рассылки алертов */
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/* Структура для хранения канала уведомлений */
+type NotificationChannel struct {
+	ID          int64              `json:"id"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`        // "webhook", "email", "slack", "telegram" или "script"
+	Config      NotificationConfig `json:"config"`      // Параметры доставки, набор полей зависит от типа
+	Template    string             `json:"template"`    // Шаблон text/template, пустой - шаблон по умолчанию
+	MinSeverity string             `json:"minSeverity"` // Минимальная важность алерта для отправки
+	IsDefault   bool               `json:"isDefault"`   // Используется для правил без явно указанных каналов
+	Enabled     bool               `json:"enabled"`
+}
+
+/* Параметры доставки уведомлений для всех типов каналов */
+type NotificationConfig struct {
+	URL     string            `json:"url,omitempty"`     // webhook, slack, в ответах API путь и параметры заменяются маской
+	Headers map[string]string `json:"headers,omitempty"` // webhook, в ответах API значения заменяются маской
+
+	SMTPHost string   `json:"smtpHost,omitempty"` // email
+	SMTPPort int      `json:"smtpPort,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"` // В ответах API заменяется маской
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	APIURL   string `json:"apiUrl,omitempty"`   // telegram, по умолчанию https://api.telegram.org
+	BotToken string `json:"botToken,omitempty"` // В ответах API заменяется маской
+	ChatID   string `json:"chatId,omitempty"`
+
+	Path       string   `json:"path,omitempty"` // script
+	Args       []string `json:"args,omitempty"`
+	TimeoutSec int      `json:"timeoutSec,omitempty"`
+}
+
+/* Структура для хранения записи журнала доставки уведомлений */
+type NotificationDelivery struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	AlertID   int64     `json:"alertId"`
+	ChannelID int64     `json:"channelId"`
+	Event     string    `json:"event"`
+	Attempts  int       `json:"attempts"`
+	Status    string    `json:"status"` // "sent" или "failed"
+	Error     string    `json:"error"`
+}
+
+/* Данные, доступные в шаблоне сообщения */
+type NotificationMessage struct {
+	Event string `json:"event"` // "fired", "resolved" или "test"
+	Host  string `json:"host"`
+	Alert Alert  `json:"alert"`
+	Text  string `json:"text"`
+}
+
+/* Значение, которым в ответах API заменяются секреты */
+const secretMask = "********"
+
+const defaultNotificationTemplate = `{{if eq .Event "resolved"}}[RESOLVED]{{else}}[{{upper .Alert.Severity}}]{{end}} {{.Host}}: {{.Alert.Message}}`
+
+var (
+	/* Количество попыток доставки и начальная задержка между ними, задержка удваивается с каждой попыткой */
+	notificationMaxAttempts = 4
+	notificationBaseBackoff = 2 * time.Second
+)
+
+var severityRank = map[string]int{
+	"info":     0,
+	"warning":  1,
+	"critical": 2,
+}
+
+var notificationTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+/* Проверяет корректность канала уведомлений и подставляет значения по умолчанию */
+func ValidateNotificationChannel(channel *NotificationChannel) error {
+	if channel.Name == "" {
+		return fmt.Errorf("поле 'name' обязательно")
+	}
+	if channel.MinSeverity == "" {
+		channel.MinSeverity = "info"
+	}
+	if !alertSeverities[channel.MinSeverity] {
+		return fmt.Errorf("неизвестная важность '%s'", channel.MinSeverity)
+	}
+	if channel.Template != "" {
+		if _, err := template.New("message").Funcs(notificationTemplateFuncs).Parse(channel.Template); err != nil {
+			return fmt.Errorf("некорректный шаблон: %v", err)
+		}
+	}
+
+	cfg := channel.Config
+	switch channel.Type {
+	case "webhook", "slack":
+		if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+			return fmt.Errorf("для канала '%s' нужен url с http:// или https://", channel.Type)
+		}
+	case "email":
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return fmt.Errorf("для канала email нужны smtpHost, from и to")
+		}
+		if cfg.SMTPPort == 0 {
+			channel.Config.SMTPPort = 25
+		}
+	case "telegram":
+		if cfg.BotToken == "" || cfg.ChatID == "" {
+			return fmt.Errorf("для канала telegram нужны botToken и chatId")
+		}
+	case "script":
+		if cfg.Path == "" {
+			return fmt.Errorf("для канала script нужен path")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип канала '%s'", channel.Type)
+	}
+	return nil
+}
+
+/* Создаёт новый канал уведомлений и возвращает его ID */
+func CreateNotificationChannel(channel NotificationChannel) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if err := ValidateNotificationChannel(&channel); err != nil {
+		return 0, err
+	}
+
+	config, err := json.Marshal(channel.Config)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO notification_channels (created_at, name, type, config, template, min_severity, is_default, enabled) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		channel.Name,
+		channel.Type,
+		string(config),
+		channel.Template,
+		channel.MinSeverity,
+		channel.IsDefault,
+		channel.Enabled,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+/* Обновляет канал уведомлений по его ID, секреты, переданные маской, остаются прежними */
+func UpdateNotificationChannel(channel NotificationChannel) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+	if err := restoreNotificationSecrets(&channel); err != nil {
+		return err
+	}
+	if err := ValidateNotificationChannel(&channel); err != nil {
+		return err
+	}
+
+	config, err := json.Marshal(channel.Config)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec(
+		"UPDATE notification_channels SET name = ?, type = ?, config = ?, template = ?, min_severity = ?, is_default = ?, enabled = ? WHERE id = ?",
+		channel.Name,
+		channel.Type,
+		string(config),
+		channel.Template,
+		channel.MinSeverity,
+		channel.IsDefault,
+		channel.Enabled,
+		channel.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("канал с ID %d не найден", channel.ID)
+	}
+	return nil
+}
+
+/* Удаляет канал уведомлений по его ID */
+func DeleteNotificationChannel(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	result, err := db.Exec("DELETE FROM notification_channels WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("канал с ID %d не найден", id)
+	}
+	return nil
+}
+
+/* Подставляет сохраненные секреты вместо маски, которую клиент получил в списке каналов */
+func restoreNotificationSecrets(channel *NotificationChannel) error {
+	cfg := &channel.Config
+	masked := cfg.Password == secretMask || cfg.BotToken == secretMask || strings.Contains(cfg.URL, secretMask)
+	for _, value := range cfg.Headers {
+		masked = masked || value == secretMask
+	}
+	if !masked {
+		return nil
+	}
+
+	channels, err := queryNotificationChannels()
+	if err != nil {
+		return err
+	}
+	for _, stored := range channels {
+		if stored.ID != channel.ID {
+			continue
+		}
+		if cfg.Password == secretMask {
+			cfg.Password = stored.Config.Password
+		}
+		if cfg.BotToken == secretMask {
+			cfg.BotToken = stored.Config.BotToken
+		}
+		if strings.Contains(cfg.URL, secretMask) {
+			// Маску можно вернуть только целиком, иначе неизвестно, какую часть адреса клиент изменил
+			if cfg.URL != maskNotificationURL(stored.Config.URL) {
+				return fmt.Errorf("url содержит маску, но не совпадает с сохраненным адресом, передайте адрес целиком")
+			}
+			cfg.URL = stored.Config.URL
+		}
+		for name, value := range cfg.Headers {
+			if value != secretMask {
+				continue
+			}
+			storedValue, ok := stored.Config.Headers[name]
+			if !ok {
+				return fmt.Errorf("значение заголовка '%s' передано маской, но у канала нет такого заголовка", name)
+			}
+			cfg.Headers[name] = storedValue
+		}
+		return nil
+	}
+	return fmt.Errorf("канал с ID %d не найден", channel.ID)
+}
+
+/* Заменяет маской путь, параметры и данные пользователя в адресе, схема и хост остаются видны */
+/* В адресах входящих вебхуков Slack и подобных сервисов секретом является сам путь */
+func maskNotificationURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		if raw == "" {
+			return ""
+		}
+		return secretMask
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == "" {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host + "/" + secretMask
+}
+
+/* Возвращает настройки канала, в которых секреты заменены маской */
+func maskNotificationConfig(cfg NotificationConfig) NotificationConfig {
+	if cfg.Password != "" {
+		cfg.Password = secretMask
+	}
+	if cfg.BotToken != "" {
+		cfg.BotToken = secretMask
+	}
+	cfg.URL = maskNotificationURL(cfg.URL)
+	if len(cfg.Headers) > 0 {
+		headers := make(map[string]string, len(cfg.Headers))
+		for name := range cfg.Headers {
+			headers[name] = secretMask
+		}
+		cfg.Headers = headers
+	}
+	return cfg
+}
+
+/* Получает список всех каналов уведомлений, секреты заменены маской */
+func GetNotificationChannels() ([]NotificationChannel, error) {
+	channels, err := queryNotificationChannels()
+	for i := range channels {
+		channels[i].Config = maskNotificationConfig(channels[i].Config)
+	}
+	return channels, err
+}
+
+/* Читает все каналы уведомлений с исходными настройками для отправки */
+func queryNotificationChannels() ([]NotificationChannel, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT id, created_at, name, type, config, template, min_severity, is_default, enabled FROM notification_channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []NotificationChannel{}
+	for rows.Next() {
+		var c NotificationChannel
+		var createdAtStr, config string
+		var tmpl, minSeverity sql.NullString
+		var isDefault, enabled int
+
+		if err := rows.Scan(&c.ID, &createdAtStr, &c.Name, &c.Type, &config, &tmpl, &minSeverity, &isDefault, &enabled); err != nil {
+			return nil, err
+		}
+
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			c.CreatedAt = parsed
+		}
+		if err := json.Unmarshal([]byte(config), &c.Config); err != nil {
+			log.Printf("Ошибка разбора настроек канала %d: %v", c.ID, err)
+		}
+		c.Template = tmpl.String
+		c.MinSeverity = minSeverity.String
+		c.IsDefault = isDefault == 1
+		c.Enabled = enabled == 1
+
+		channels = append(channels, c)
+	}
+
+	return channels, rows.Err()
+}
+
+/*
+Рассылает уведомление о событии алерта по каналам его правила
+
+	Если у правила нет каналов, используются каналы по умолчанию, каналы с более высокой минимальной важностью пропускаются.
+	По алертам, созданным или отложенным во время тишины, уведомления не отправляются
+*/
+func notifyAlert(event string, alertID int64) {
+	alert, err := GetAlertByID(alertID)
+	if err != nil || alert == nil || alert.Silenced {
+		return
+	}
+
+	channels, err := queryNotificationChannels()
+	if err != nil {
+		log.Printf("Ошибка получения каналов уведомлений: %v", err)
+		return
+	}
+
+	var routed map[int64]bool
+	if alert.RuleID != nil {
+		if rule, ok := findAlertRule(*alert.RuleID); ok && len(rule.ChannelIDs) > 0 {
+			routed = make(map[int64]bool, len(rule.ChannelIDs))
+			for _, id := range rule.ChannelIDs {
+				routed[id] = true
+			}
+		}
+	}
+
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+		if routed != nil && !routed[channel.ID] {
+			continue
+		}
+		if routed == nil && !channel.IsDefault {
+			continue
+		}
+		if severityRank[alert.Severity] < severityRank[channel.MinSeverity] {
+			continue
+		}
+		go deliverNotification(channel, event, *alert)
+	}
+}
+
+/* Отправляет уведомление в канал с повторами и экспоненциальной задержкой, результат записывается в журнал */
+func deliverNotification(channel NotificationChannel, event string, alert Alert) {
+	backoff := notificationBaseBackoff
+	var err error
+	attempts := 0
+
+	for attempts < notificationMaxAttempts {
+		attempts++
+		if err = SendNotification(channel, event, alert); err == nil {
+			break
+		}
+		log.Printf("Ошибка отправки уведомления в канал '%s' (попытка %d): %v", channel.Name, attempts, err)
+		if attempts < notificationMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	saveNotificationDelivery(alert.ID, channel.ID, event, attempts, err)
+}
+
+/* Формирует текст сообщения по шаблону канала и отправляет его одной попыткой */
+func SendNotification(channel NotificationChannel, event string, alert Alert) error {
+	host, _ := os.Hostname()
+	msg := NotificationMessage{Event: event, Host: host, Alert: alert}
+
+	text, err := renderNotification(channel.Template, msg)
+	if err != nil {
+		return err
+	}
+	msg.Text = text
+
+	sender, ok := notificationSenders[channel.Type]
+	if !ok {
+		return fmt.Errorf("неизвестный тип канала '%s'", channel.Type)
+	}
+	return sender(channel.Config, msg)
+}
+
+/* Отправляет тестовое уведомление в канал по его ID без повторов */
+func TestNotificationChannel(id int64) error {
+	channels, err := queryNotificationChannels()
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if channel.ID != id {
+			continue
+		}
+		now := time.Now()
+		alert := Alert{
+			CreatedAt: now,
+			Type:      "test",
+			Message:   "Тестовое уведомление",
+			Severity:  "info",
+			Status:    "firing",
+			StartedAt: &now,
+		}
+		err := SendNotification(channel, "test", alert)
+		saveNotificationDelivery(0, channel.ID, "test", 1, err)
+		return err
+	}
+	return fmt.Errorf("канал с ID %d не найден", id)
+}
+
+/* Применяет шаблон канала к данным сообщения */
+func renderNotification(tmpl string, msg NotificationMessage) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultNotificationTemplate
+	}
+	t, err := template.New("message").Funcs(notificationTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("некорректный шаблон: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, msg); err != nil {
+		return "", fmt.Errorf("ошибка применения шаблона: %v", err)
+	}
+	return buf.String(), nil
+}
+
+/* Сохраняет результат доставки уведомления в журнал */
+func saveNotificationDelivery(alertID, channelID int64, event string, attempts int, deliveryErr error) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	status := "sent"
+	errText := ""
+	if deliveryErr != nil {
+		status = "failed"
+		errText = deliveryErr.Error()
+	}
+
+	var alertRef interface{}
+	if alertID != 0 {
+		alertRef = alertID
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO notification_deliveries (created_at, alert_id, channel_id, event, attempts, status, error) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		alertRef,
+		channelID,
+		event,
+		attempts,
+		status,
+		errText,
+	)
+	if err != nil {
+		log.Printf("Ошибка сохранения журнала доставки: %v", err)
+	}
+}
+
+/* Получает журнал доставки уведомлений с фильтрацией по алерту и каналу */
+func GetNotificationDeliveries(alertID, channelID int64, limit int) ([]NotificationDelivery, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	query := "SELECT id, created_at, alert_id, channel_id, event, attempts, status, error FROM notification_deliveries WHERE 1=1"
+	var args []interface{}
+	if alertID > 0 {
+		query += " AND alert_id = ?"
+		args = append(args, alertID)
+	}
+	if channelID > 0 {
+		query += " AND channel_id = ?"
+		args = append(args, channelID)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []NotificationDelivery{}
+	for rows.Next() {
+		var d NotificationDelivery
+		var createdAtStr string
+		var alertRef sql.NullInt64
+		var errText sql.NullString
+
+		if err := rows.Scan(&d.ID, &createdAtStr, &alertRef, &d.ChannelID, &d.Event, &d.Attempts, &d.Status, &errText); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			d.CreatedAt = parsed
+		}
+		d.AlertID = alertRef.Int64
+		d.Error = errText.String
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,148 @@
+/* Отправители уведомлений для разных типов каналов */
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Функция отправки одного уведомления в канал */
+type notificationSender func(cfg NotificationConfig, msg NotificationMessage) error
+
+var notificationSenders = map[string]notificationSender{
+	"webhook":  sendWebhookNotification,
+	"slack":    sendSlackNotification,
+	"telegram": sendTelegramNotification,
+	"email":    sendEmailNotification,
+	"script":   runScriptNotification,
+}
+
+var notificationHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+/* Отправляет JSON с событием, текстом и полными данными алерта на произвольный адрес */
+func sendWebhookNotification(cfg NotificationConfig, msg NotificationMessage) error {
+	return postNotificationJSON(cfg.URL, cfg.Headers, msg)
+}
+
+/* Отправляет текст во входящий webhook Slack или Mattermost */
+func sendSlackNotification(cfg NotificationConfig, msg NotificationMessage) error {
+	return postNotificationJSON(cfg.URL, nil, map[string]string{"text": msg.Text})
+}
+
+/* Отправляет текст через метод sendMessage HTTP API бота */
+func sendTelegramNotification(cfg NotificationConfig, msg NotificationMessage) error {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	url := strings.TrimRight(apiURL, "/") + "/bot" + cfg.BotToken + "/sendMessage"
+	return postNotificationJSON(url, nil, map[string]string{
+		"chat_id": cfg.ChatID,
+		"text":    msg.Text,
+	})
+}
+
+/* Выполняет POST запрос с JSON телом и проверяет код ответа */
+func postNotificationJSON(url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := notificationHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("сервер ответил %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+/* Отправляет письмо через SMTP сервер, первая строка текста используется как тема */
+func sendEmailNotification(cfg NotificationConfig, msg NotificationMessage) error {
+	subject := msg.Text
+	if i := strings.IndexByte(subject, '\n'); i >= 0 {
+		subject = subject[:i]
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	body.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)
+	}
+
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	return smtp.SendMail(addr, auth, cfg.From, cfg.To, []byte(body.String()))
+}
+
+/*
+Запускает локальный исполняемый файл для обработки уведомления
+
+	Данные алерта передаются через переменные окружения ALERT_* и в виде JSON на stdin
+*/
+func runScriptNotification(cfg NotificationConfig, msg NotificationMessage) error {
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, cfg.Path, cfg.Args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"ALERT_EVENT="+msg.Event,
+		"ALERT_ID="+strconv.FormatInt(msg.Alert.ID, 10),
+		"ALERT_TYPE="+msg.Alert.Type,
+		"ALERT_SEVERITY="+msg.Alert.Severity,
+		"ALERT_STATUS="+msg.Alert.Status,
+		"ALERT_MESSAGE="+msg.Alert.Message,
+		"ALERT_TEXT="+msg.Text,
+		"ALERT_HOST="+msg.Host,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("скрипт завершился с ошибкой: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
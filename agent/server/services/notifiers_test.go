@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/* Запрос, полученный тестовым HTTP сервером */
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+/* Запускает тестовый HTTP сервер, который сохраняет запросы и отвечает кодом status */
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, func() []capturedRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []capturedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		mu.Lock()
+		requests = append(requests, capturedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		mu.Unlock()
+
+		w.WriteHeader(status)
+		w.Write([]byte("ответ сервера"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []capturedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capturedRequest(nil), requests...)
+	}
+}
+
+/* Тестовое сообщение для отправителей */
+func testNotificationMessage() NotificationMessage {
+	return NotificationMessage{
+		Event: "fired",
+		Host:  "test-host",
+		Alert: Alert{ID: 42, Type: "cpu", Severity: "critical", Status: "firing", Message: "CPU 95%"},
+		Text:  "[CRITICAL] test-host: CPU 95%\nподробности",
+	}
+}
+
+func TestSendWebhookNotification(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+
+	cfg := NotificationConfig{URL: server.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if err := sendWebhookNotification(cfg, testNotificationMessage()); err != nil {
+		t.Fatalf("sendWebhookNotification: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("ожидался 1 запрос, получено %d", len(got))
+	}
+	req := got[0]
+	if req.Method != http.MethodPost || req.Path != "/hook" {
+		t.Errorf("запрос %s %s, ожидался POST /hook", req.Method, req.Path)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("заголовок Authorization = %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", req.Header.Get("Content-Type"))
+	}
+	if req.Body["event"] != "fired" || req.Body["host"] != "test-host" {
+		t.Errorf("неожиданное тело запроса: %v", req.Body)
+	}
+	alert, _ := req.Body["alert"].(map[string]interface{})
+	if alert["id"] != float64(42) || alert["severity"] != "critical" {
+		t.Errorf("неожиданные данные алерта: %v", alert)
+	}
+}
+
+func TestSendSlackNotification(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+
+	msg := testNotificationMessage()
+	if err := sendSlackNotification(NotificationConfig{URL: server.URL}, msg); err != nil {
+		t.Fatalf("sendSlackNotification: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("ожидался 1 запрос, получено %d", len(got))
+	}
+	if got[0].Body["text"] != msg.Text || len(got[0].Body) != 1 {
+		t.Errorf("ожидалось тело {text}, получено %v", got[0].Body)
+	}
+}
+
+func TestSendTelegramNotification(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+
+	msg := testNotificationMessage()
+	cfg := NotificationConfig{APIURL: server.URL + "/", BotToken: "123:abc", ChatID: "-100500"}
+	if err := sendTelegramNotification(cfg, msg); err != nil {
+		t.Fatalf("sendTelegramNotification: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("ожидался 1 запрос, получено %d", len(got))
+	}
+	if got[0].Path != "/bot123:abc/sendMessage" {
+		t.Errorf("путь %q, ожидался /bot123:abc/sendMessage", got[0].Path)
+	}
+	if got[0].Body["chat_id"] != "-100500" || got[0].Body["text"] != msg.Text {
+		t.Errorf("неожиданное тело запроса: %v", got[0].Body)
+	}
+}
+
+func TestPostNotificationJSONErrorStatus(t *testing.T) {
+	server, _ := newCaptureServer(t, http.StatusBadGateway)
+
+	err := sendSlackNotification(NotificationConfig{URL: server.URL}, testNotificationMessage())
+	if err == nil {
+		t.Fatal("ожидалась ошибка для ответа 502")
+	}
+	if !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), "ответ сервера") {
+		t.Errorf("ошибка должна содержать код и тело ответа: %v", err)
+	}
+}
+
+/* Письмо, принятое тестовым SMTP сервером */
+type capturedMail struct {
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+/*
+Запускает тестовый SMTP сервер на 127.0.0.1, который принимает одно письмо
+
+	Сервер поддерживает EHLO, AUTH PLAIN, MAIL, RCPT, DATA и QUIT, принятое письмо отправляется в канал
+*/
+func newFakeSMTPServer(t *testing.T) (string, int, <-chan capturedMail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("не удалось открыть порт: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan capturedMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var mail capturedMail
+
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(command, "AUTH PLAIN"):
+				decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+				mail.Auth = string(decoded)
+				reply("235 2.7.0 Authentication successful")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				mail.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				mail.To = append(mail.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				mail.Data = data.String()
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				mails <- mail
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, mails
+}
+
+func TestSendEmailNotification(t *testing.T) {
+	host, port, mails := newFakeSMTPServer(t)
+
+	cfg := NotificationConfig{
+		SMTPHost: host,
+		SMTPPort: port,
+		Username: "agent",
+		Password: "p@ss",
+		From:     "agent@example.com",
+		To:       []string{"ops@example.com", "dev@example.com"},
+	}
+	if err := sendEmailNotification(cfg, testNotificationMessage()); err != nil {
+		t.Fatalf("sendEmailNotification: %v", err)
+	}
+
+	mail := <-mails
+	if mail.Auth != "\x00agent\x00p@ss" {
+		t.Errorf("AUTH PLAIN = %q", mail.Auth)
+	}
+	if mail.From != "agent@example.com" {
+		t.Errorf("MAIL FROM = %q", mail.From)
+	}
+	if strings.Join(mail.To, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("RCPT TO = %v", mail.To)
+	}
+	for _, want := range []string{
+		"From: agent@example.com\r\n",
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [CRITICAL] test-host: CPU 95%\r\n",
+		"\r\n\r\n[CRITICAL] test-host: CPU 95%\r\nподробности\r\n",
+	} {
+		if !strings.Contains(mail.Data, want) {
+			t.Errorf("письмо не содержит %q:\n%s", want, mail.Data)
+		}
+	}
+}
+
+func TestSendEmailNotificationWithoutAuth(t *testing.T) {
+	host, port, mails := newFakeSMTPServer(t)
+
+	cfg := NotificationConfig{SMTPHost: host, SMTPPort: port, From: "agent@example.com", To: []string{"ops@example.com"}}
+	if err := sendEmailNotification(cfg, testNotificationMessage()); err != nil {
+		t.Fatalf("sendEmailNotification: %v", err)
+	}
+	if mail := <-mails; mail.Auth != "" {
+		t.Errorf("без username AUTH не должен отправляться, получено %q", mail.Auth)
+	}
+}
+
+func TestSendEmailNotificationConnectionError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := NotificationConfig{SMTPHost: "127.0.0.1", SMTPPort: port, From: "a@example.com", To: []string{"b@example.com"}}
+	err = sendEmailNotification(cfg, testNotificationMessage())
+	if err == nil || !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Errorf("ожидалась ошибка подключения к порту %d, получено %v", port, err)
+	}
+}
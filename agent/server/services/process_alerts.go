@@ -0,0 +1,157 @@
+/* Сервисы для проверки правил алертов по отдельным процессам и группам процессов */
+package services
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/*
+Проверяет правила с областью "process" по очередному снимку процессов
+
+	Вызывается при каждом обновлении кэша процессов, алерты сохраняются с PID и именем процесса
+*/
+func CheckProcessAlerts(procs []models.ProcessInfo) {
+	rules := cachedAlertRules()
+	now := time.Now()
+
+	alertEngineMutex.Lock()
+	defer alertEngineMutex.Unlock()
+
+	restoreFiringAlerts()
+	seen := make(map[string]bool)
+
+	for _, rule := range rules {
+		if !rule.Enabled || rule.Scope != "process" {
+			continue
+		}
+
+		matched := matchAlertProcesses(rule, procs)
+		for _, obs := range processAlertObservations(rule, matched) {
+			evaluateAlertRule(rule, obs, now)
+			seen[obs.fingerprint] = true
+		}
+	}
+
+	resolveUnseenAlerts("process:", seen, now)
+}
+
+/* Отбирает процессы, подходящие под шаблон имени и пользователя правила */
+func matchAlertProcesses(rule AlertRule, procs []models.ProcessInfo) []models.ProcessInfo {
+	matched := make([]models.ProcessInfo, 0)
+	for _, p := range procs {
+		if rule.MatchName != "" {
+			if ok, _ := path.Match(rule.MatchName, p.Name); !ok {
+				continue
+			}
+		}
+		if rule.MatchUser != "" && rule.MatchUser != p.Username {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched
+}
+
+/*
+Строит значения для проверки правила по совпавшим процессам
+
+	При агрегации "each" каждый процесс проверяется отдельно, иначе правило даёт одно значение на всю группу
+*/
+func processAlertObservations(rule AlertRule, matched []models.ProcessInfo) []alertObservation {
+	base := fmt.Sprintf("process:rule:%d", rule.ID)
+	target := describeProcessMatch(rule)
+
+	if rule.Metric == "process_count" {
+		value := float64(len(matched))
+		return []alertObservation{{
+			fingerprint: base,
+			value:       value,
+			message:     fmt.Sprintf("%s: процессов %s - %.0f %s %.0f", rule.Name, target, value, rule.Comparator, rule.Threshold),
+			processName: rule.MatchName,
+		}}
+	}
+
+	switch rule.Aggregate {
+	case "sum", "max":
+		if len(matched) == 0 {
+			return nil
+		}
+		var value float64
+		var top models.ProcessInfo
+		for i, p := range matched {
+			v := processMetricValue(rule.Metric, p)
+			if rule.Aggregate == "sum" {
+				value += v
+			}
+			if i == 0 || v > processMetricValue(rule.Metric, top) {
+				top = p
+			}
+		}
+		if rule.Aggregate == "max" {
+			value = processMetricValue(rule.Metric, top)
+		}
+		obs := alertObservation{
+			fingerprint: base,
+			value:       value,
+			message: fmt.Sprintf("%s: %s (%s, %d процессов) %s %s %s", rule.Name, alertProcessMetrics[rule.Metric], rule.Aggregate, len(matched),
+				formatProcessMetric(rule.Metric, value), rule.Comparator, formatProcessMetric(rule.Metric, rule.Threshold)),
+			processName: rule.MatchName,
+		}
+		if rule.Aggregate == "max" {
+			obs.pid = top.PID
+			obs.processName = top.Name
+		}
+		return []alertObservation{obs}
+	}
+
+	observations := make([]alertObservation, 0, len(matched))
+	for _, p := range matched {
+		value := processMetricValue(rule.Metric, p)
+		observations = append(observations, alertObservation{
+			fingerprint: fmt.Sprintf("%s:pid:%d:%d", base, p.PID, p.CreateTime),
+			value:       value,
+			message: fmt.Sprintf("%s: %s %s (PID %d) %s %s %s", rule.Name, alertProcessMetrics[rule.Metric], p.Name, p.PID,
+				formatProcessMetric(rule.Metric, value), rule.Comparator, formatProcessMetric(rule.Metric, rule.Threshold)),
+			pid:         p.PID,
+			processName: p.Name,
+		})
+	}
+	return observations
+}
+
+/* Возвращает значение метрики правила для процесса */
+func processMetricValue(metric string, p models.ProcessInfo) float64 {
+	switch metric {
+	case "process_cpu":
+		return p.CPUPercent
+	case "process_memory":
+		return p.MemoryPercent
+	case "process_rss":
+		return float64(p.MemoryRSS)
+	}
+	return 0
+}
+
+/* Форматирует значение метрики процесса для сообщения алерта */
+func formatProcessMetric(metric string, value float64) string {
+	if metric == "process_rss" {
+		return fmt.Sprintf("%.1f МБ", value/(1024*1024))
+	}
+	return fmt.Sprintf("%.2f%%", value)
+}
+
+/* Описывает отбор процессов правила для сообщения алерта */
+func describeProcessMatch(rule AlertRule) string {
+	target := "с любым именем"
+	if rule.MatchName != "" {
+		target = fmt.Sprintf("'%s'", rule.MatchName)
+	}
+	if rule.MatchUser != "" {
+		target += fmt.Sprintf(" пользователя %s", rule.MatchUser)
+	}
+	return target
+}
@@ -0,0 +1,78 @@
+//go:build !windows
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+/*
+Настраивает запуск процесса от имени непривилегированного пользователя и группы, возвращает переменные окружения пользователя
+
+	Доступно только агенту, запущенному от root, пользователь и группа root не допускаются
+*/
+func configureProcessCredential(cmd *exec.Cmd, userName, groupName string) (map[string]string, error) {
+	if userName == "" {
+		return nil, nil
+	}
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("запуск от имени другого пользователя возможен, только если агент запущен от root")
+	}
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		if u, err = user.LookupId(userName); err != nil {
+			return nil, fmt.Errorf("пользователь '%s' не найден", userName)
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный UID пользователя '%s': %s", userName, u.Uid)
+	}
+	if uid == 0 {
+		return nil, fmt.Errorf("запуск от имени root не допускается, укажите непривилегированного пользователя")
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный GID пользователя '%s': %s", userName, u.Gid)
+	}
+	var groups []uint32
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return nil, fmt.Errorf("группа '%s' не найдена", groupName)
+			}
+		}
+		if gid, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+			return nil, fmt.Errorf("некорректный GID группы '%s': %s", groupName, g.Gid)
+		}
+		groups = []uint32{uint32(gid)}
+	} else if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if value, err := strconv.ParseUint(id, 10, 32); err == nil && value != 0 {
+				groups = append(groups, uint32(value))
+			}
+		}
+	}
+	if gid == 0 {
+		return nil, fmt.Errorf("запуск с группой root не допускается, укажите непривилегированную группу")
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+
+	return map[string]string{
+		"HOME":    u.HomeDir,
+		"USER":    u.Username,
+		"LOGNAME": u.Username,
+	}, nil
+}
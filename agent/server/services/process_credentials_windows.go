@@ -0,0 +1,16 @@
+//go:build windows
+
+package services
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+/* Запуск процесса от имени другого пользователя в Windows не поддерживается */
+func configureProcessCredential(cmd *exec.Cmd, userName, groupName string) (map[string]string, error) {
+	if userName == "" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("запуск от имени другого пользователя не поддерживается в Windows")
+}
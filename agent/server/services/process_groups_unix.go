@@ -0,0 +1,34 @@
+//go:build !windows
+
+package services
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+/* Возвращает PGID и SID процесса, 0 если их не удалось получить */
+func processGroupIDs(pid int32) (int32, int32) {
+	pgid, err := unix.Getpgid(int(pid))
+	if err != nil {
+		pgid = 0
+	}
+	sid, err := unix.Getsid(int(pid))
+	if err != nil {
+		sid = 0
+	}
+	return int32(pgid), int32(sid)
+}
+
+/* Проверяет, является ли процесс потоком ядра: в Linux это kthreadd (PID 2) и его потомки */
+func isKernelThread(pid, parentPID int32) bool {
+	return runtime.GOOS == "linux" && (pid == 2 || parentPID == 2)
+}
+
+/* Запускает управляемый процесс в собственной группе, чтобы он не получал сигналы терминала агента и переживал его перезапуск */
+func detachManagedCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
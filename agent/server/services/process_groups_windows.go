@@ -0,0 +1,23 @@
+//go:build windows
+
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+/* В Windows нет групп процессов и сессий в смысле POSIX */
+func processGroupIDs(pid int32) (int32, int32) {
+	return 0, 0
+}
+
+/* В Windows потоки ядра не видны как процессы */
+func isKernelThread(pid, parentPID int32) bool {
+	return false
+}
+
+/* Запускает управляемый процесс в собственной группе, чтобы Ctrl+C в консоли агента не завершал его */
+func detachManagedCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
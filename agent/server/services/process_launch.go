@@ -0,0 +1,175 @@
+/* Сервисы для применения параметров запуска процессов: окружение, пользователь, rlimit, время работы и stdin */
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Максимальный размер данных для stdin процесса */
+const maxProcessStdinSize = 1024 * 1024
+
+/* Проверяет параметры запуска процесса */
+func validateLaunchOptions(options models.ProcessLaunchOptions) error {
+	for key := range options.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("некорректное имя переменной окружения '%s'", key)
+		}
+	}
+	if options.Group != "" && options.User == "" {
+		return fmt.Errorf("для group нужно указать user")
+	}
+	if len(options.Rlimits) > 0 && len(processRlimitResources) == 0 {
+		return fmt.Errorf("rlimits поддерживаются только в Linux")
+	}
+	for name := range options.Rlimits {
+		if _, ok := processRlimitResources[name]; !ok {
+			return fmt.Errorf("неизвестный rlimit '%s', доступны: %s", name, strings.Join(processRlimitNames(), ", "))
+		}
+	}
+	if options.MaxRuntimeSec < 0 {
+		return fmt.Errorf("maxRuntimeSec не может быть отрицательным")
+	}
+	if len(options.Stdin) > maxProcessStdinSize {
+		return fmt.Errorf("stdin не может быть больше %d байт", maxProcessStdinSize)
+	}
+	return nil
+}
+
+/* Возвращает копию параметров запуска, в которой значения env и stdin заменены маской */
+func maskLaunchOptions(options models.ProcessLaunchOptions) models.ProcessLaunchOptions {
+	if len(options.Env) > 0 {
+		env := make(map[string]string, len(options.Env))
+		for key := range options.Env {
+			env[key] = secretMask
+		}
+		options.Env = env
+	}
+	if options.Stdin != "" {
+		options.Stdin = secretMask
+	}
+	return options
+}
+
+/* Возвращает отсортированный список поддерживаемых rlimit */
+func processRlimitNames() []string {
+	names := make([]string, 0, len(processRlimitResources))
+	for name := range processRlimitResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+Настраивает команду перед запуском: окружение, пользователь и группа, stdin и rlimit
+
+	Окружение агента дополняется переменными HOME, USER и LOGNAME пользователя, если он указан, и затем переменными из env.
+	Если заданы rlimits, команда запускается через вспомогательный процесс, и после Start нужно вызвать confirm
+*/
+func applyLaunchOptions(cmd *exec.Cmd, options models.ProcessLaunchOptions) (*rlimitHelper, error) {
+	userEnv, err := configureProcessCredential(cmd, options.User, options.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userEnv) > 0 || len(options.Env) > 0 {
+		env := make(map[string]string)
+		var order []string
+		set := func(key, value string) {
+			if _, ok := env[key]; !ok {
+				order = append(order, key)
+			}
+			env[key] = value
+		}
+		for _, entry := range os.Environ() {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				set(key, value)
+			}
+		}
+		for key, value := range userEnv {
+			set(key, value)
+		}
+		for key, value := range options.Env {
+			set(key, value)
+		}
+
+		cmd.Env = make([]string, 0, len(order))
+		for _, key := range order {
+			cmd.Env = append(cmd.Env, key+"="+env[key])
+		}
+	}
+
+	if options.Stdin != "" {
+		cmd.Stdin = strings.NewReader(options.Stdin)
+	}
+	if len(options.Rlimits) > 0 {
+		return wrapRlimitHelper(cmd, options.Rlimits)
+	}
+	return nil, nil
+}
+
+/* Канал статуса вспомогательного процесса, который задает rlimit перед exec команды */
+type rlimitHelper struct {
+	status *os.File
+	writer *os.File
+}
+
+/*
+Ждет, пока вспомогательный процесс задаст rlimit и выполнит exec команды, при ошибке дожидается его завершения
+
+	Канал статуса закрывается при успешном exec, а при ошибке в него пишется ее описание. Для nil ничего не делает
+*/
+func (h *rlimitHelper) confirm(cmd *exec.Cmd) error {
+	if h == nil {
+		return nil
+	}
+	h.writer.Close()
+	defer h.status.Close()
+
+	message, _ := io.ReadAll(h.status)
+	if len(message) > 0 {
+		cmd.Wait()
+		return fmt.Errorf("%s", message)
+	}
+	return nil
+}
+
+/* Закрывает канал статуса, если команду не удалось запустить. Для nil ничего не делает */
+func (h *rlimitHelper) abort() {
+	if h == nil {
+		return
+	}
+	h.writer.Close()
+	h.status.Close()
+}
+
+/*
+Останавливает процесс, если он работает дольше maxRuntimeSec секунд после startedAt, возвращает функцию отмены
+
+	Процесс и его группа, если он в ней лидер, получают SIGTERM и через managedStopGraceSec секунд SIGKILL,
+	в лог процесса записывается причина
+*/
+func startRuntimeLimit(pid int32, startedAt time.Time, maxRuntimeSec int, output *processLog) func() {
+	if maxRuntimeSec <= 0 {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(time.Until(startedAt.Add(time.Duration(maxRuntimeSec)*time.Second)), func() {
+		if output != nil {
+			output.writeAgentLine(fmt.Sprintf("превышено максимальное время работы (%d с), процесс останавливается", maxRuntimeSec))
+		}
+		if _, err := stopProcessGroup(pid, managedStopGraceSec); err != nil {
+			log.Printf("Не удалось остановить процесс %d по истечении времени работы: %v", pid, err)
+		}
+	})
+	return func() { timer.Stop() }
+}
@@ -0,0 +1,538 @@
+/* Сервисы для записи вывода запущенных процессов в файлы с ротацией и чтения его в реальном времени */
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Последние строки вывода процесса */
+type ProcessLogTail struct {
+	PID     int32    `json:"pid"`
+	Running bool     `json:"running"` // Процесс еще пишет в лог
+	Path    string   `json:"path"`
+	Lines   []string `json:"lines"`
+}
+
+/*
+Параметры хранения логов: файл ротируется до того, как превысит processLogMaxSize, хранится processLogMaxFiles старых файлов,
+логи завершившихся процессов удаляются через processLogRetention после последней записи, проверка - раз в processLogCleanupInterval
+*/
+const (
+	processLogDir             = "./process-logs"
+	processLogMaxSize         = 10 * 1024 * 1024
+	processLogMaxFiles        = 3
+	processLogRetention       = 7 * 24 * time.Hour
+	processLogCleanupInterval = time.Hour
+	processLogRelayWait       = 2 * time.Second
+	processLogPollInterval    = 250 * time.Millisecond
+	defaultProcessLogLines    = 100
+	maxProcessLogLines        = 10000
+)
+
+/* Аргумент, с которым агент запускается как переносчик вывода процесса в файл лога */
+const processLogRelayArg = "__nexora-log-relay"
+
+/*
+Лог вывода одного запущенного процесса
+
+	stdout и stderr процесса пишутся в канал, который читает переносчик - отдельный процесс агента, запущенный
+	с processLogRelayArg. Переносчик пишет вывод в файл и ротирует его, поэтому процесс продолжает писать в лог
+	и после перезапуска агента. Строки самого агента дописываются в файл напрямую
+*/
+type processLog struct {
+	mutex  sync.Mutex
+	pid    int32
+	path   string
+	reader *os.File      // Канал вывода процесса до запуска переносчика
+	writer *os.File      // Передается процессу как stdout и stderr
+	relay  chan struct{} // Закрывается после завершения переносчика, nil для лога, восстановленного после перезапуска агента
+	closed bool
+	done   chan struct{}
+}
+
+var (
+	activeProcessLogs      = make(map[int32]*processLog)
+	activeProcessLogsMutex sync.Mutex
+	processLogCleanupOnce  sync.Once
+)
+
+/*
+Выполняет роль переносчика вывода, если агент запущен с processLogRelayArg
+
+	Вызывается до main: переносчик только копирует stdin в файл лога и завершается, когда процесс закрывает вывод
+*/
+func init() {
+	if len(os.Args) > 2 && os.Args[1] == processLogRelayArg {
+		relayProcessLog(os.Stdin, os.Args[2])
+		os.Exit(0)
+	}
+}
+
+/* Создает канал для вывода процесса, который еще не запущен, файл лога создается после запуска, когда известен PID */
+func newProcessLog() (*processLog, error) {
+	// Вывод процессов, в том числе запущенных от других пользователей, доступен только пользователю агента.
+	// Chmod закрывает и каталог, созданный прежними версиями с правами 0755
+	if err := os.MkdirAll(processLogDir, 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог логов процессов: %w", err)
+	}
+	if err := os.Chmod(processLogDir, 0700); err != nil {
+		return nil, fmt.Errorf("не удалось изменить права каталога логов процессов: %w", err)
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать канал вывода процесса: %w", err)
+	}
+	return &processLog{reader: reader, writer: writer, done: make(chan struct{})}, nil
+}
+
+/* Закрывает канал вывода процесса, который не удалось запустить */
+func (l *processLog) discard() {
+	l.reader.Close()
+	l.writer.Close()
+}
+
+/* Записывает в лог однострочное сообщение агента с временем, с новой строки, даже если вывод процесса не закончен переводом строки */
+func (l *processLog) writeAgentLine(message string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.path == "" || l.closed {
+		return
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("Ошибка записи в лог процесса %d: %v", l.pid, err)
+		return
+	}
+	defer file.Close()
+
+	prefix := ""
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			prefix = "\n"
+		}
+	}
+	message = strings.ReplaceAll(message, "\n", " ")
+	fmt.Fprintf(file, "%s[agent %s] %s\n", prefix, time.Now().Format("2006-01-02 15:04:05"), message)
+}
+
+/*
+Создает файл лога после запуска процесса и запускает переносчик его вывода
+
+	Имя файла содержит PID и время запуска, поэтому логи процессов с повторно использованным PID не смешиваются.
+	Если переносчик не удалось запустить, вывод переносится в файл самим агентом и после его перезапуска теряется
+*/
+func (l *processLog) open(pid int32, header string) {
+	l.mutex.Lock()
+	l.pid = pid
+	l.path = filepath.Join(processLogDir, fmt.Sprintf("%d-%s.log", pid, time.Now().Format("20060102-150405.000")))
+	l.mutex.Unlock()
+
+	l.writer.Close()
+	l.writeAgentLine(header)
+	l.relay = make(chan struct{})
+
+	if err := startProcessLogRelay(l.reader, l.path, l.relay); err != nil {
+		log.Printf("Не удалось запустить переносчик вывода процесса %d, вывод пишет агент: %v", pid, err)
+		go func() {
+			relayProcessLog(l.reader, l.path)
+			l.reader.Close()
+			close(l.relay)
+		}()
+	} else {
+		l.reader.Close()
+	}
+
+	activeProcessLogsMutex.Lock()
+	activeProcessLogs[pid] = l
+	activeProcessLogsMutex.Unlock()
+}
+
+/* Запускает агент в роли переносчика, который читает вывод процесса из reader и пишет его в path, relay закрывается после его завершения */
+func startProcessLogRelay(reader *os.File, path string, relay chan struct{}) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, processLogRelayArg, path)
+	cmd.Stdin = reader
+	// Собственная группа, чтобы переносчик, как и управляемый процесс, не получал сигналы терминала агента
+	detachManagedCommand(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		cmd.Wait()
+		close(relay)
+	}()
+	return nil
+}
+
+/*
+Копирует вывод процесса из reader в файл лога с ротацией, пока процесс и его потомки не закроют вывод
+
+	Если писать в файл не удается, вывод читается дальше и отбрасывается, чтобы процесс не получил SIGPIPE
+*/
+func relayProcessLog(reader io.Reader, path string) {
+	writer := &processLogWriter{path: path}
+	defer writer.close()
+	if _, err := io.Copy(writer, reader); err != nil {
+		io.Copy(io.Discard, reader)
+	}
+}
+
+/* Дописывает данные в файл лога и ротирует его до записи, которая превысила бы processLogMaxSize */
+type processLogWriter struct {
+	path string
+	file *os.File
+}
+
+/* Записывает данные в текущий файл лога, при необходимости сначала ротирует его */
+func (w *processLogWriter) Write(p []byte) (int, error) {
+	if w.file == nil {
+		file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return 0, err
+		}
+		w.file = file
+	}
+	if info, err := w.file.Stat(); err == nil && info.Size() > 0 && info.Size()+int64(len(p)) > processLogMaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	return w.file.Write(p)
+}
+
+/*
+Сдвигает старые файлы, переименовывает текущий в .1 и открывает новый
+
+	Вывод не теряется: все записанное остается в .1, новые данные пишутся в новый файл. Если текущий файл
+	переименовать не удалось (в Windows его может держать открытым агент), запись продолжается в него, а ротация
+	повторяется при следующей записи
+*/
+func (w *processLogWriter) rotate() error {
+	w.file.Close()
+	w.file = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", w.path, processLogMaxFiles))
+	for i := processLogMaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		log.Printf("Ошибка ротации лога %s: %v", w.path, err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	return nil
+}
+
+/* Закрывает текущий файл лога */
+func (w *processLogWriter) close() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
+
+/*
+Продолжает вести лог процесса, запущенного предыдущим экземпляром агента
+
+	Вывод процесса по-прежнему пишет в файл его переносчик, агент снова дописывает в лог свои строки
+*/
+func resumeProcessLog(pid int32) *processLog {
+	path := findProcessLogPath(pid)
+	if path == "" {
+		return nil
+	}
+	l := &processLog{pid: pid, path: path, done: make(chan struct{})}
+	l.writeAgentLine("агент перезапущен, наблюдение за процессом восстановлено")
+
+	activeProcessLogsMutex.Lock()
+	activeProcessLogs[pid] = l
+	activeProcessLogsMutex.Unlock()
+	return l
+}
+
+/*
+Записывает сообщение о завершении процесса и закрывает лог, файл остается для разбора после завершения
+
+	Сначала до processLogRelayWait ждет, пока переносчик допишет остаток вывода, чтобы сообщение оказалось в конце лога
+*/
+func (l *processLog) close(footer string) {
+	if l.relay != nil {
+		select {
+		case <-l.relay:
+		case <-time.After(processLogRelayWait):
+		}
+	}
+	l.writeAgentLine(footer)
+
+	l.mutex.Lock()
+	l.closed = true
+	l.mutex.Unlock()
+
+	activeProcessLogsMutex.Lock()
+	if activeProcessLogs[l.pid] == l {
+		delete(activeProcessLogs, l.pid)
+	}
+	activeProcessLogsMutex.Unlock()
+	close(l.done)
+}
+
+/* Разбивает содержимое лога на строки без завершающей пустой строки */
+func splitProcessLogLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+/*
+Возвращает последние n строк лога, при необходимости дочитывая ротированные файлы
+
+	Также возвращает позицию в текущем файле, до которой он прочитан, и сведения о файле, по которым видна его ротация
+*/
+func tailProcessLogFiles(path string, n int) ([]string, processLogPosition, error) {
+	data, position, err := readProcessLogFrom(path, processLogPosition{})
+	if err != nil {
+		return nil, position, err
+	}
+	result := splitProcessLogLines(data)
+	for i := 1; i <= processLogMaxFiles && len(result) < n; i++ {
+		data, err := os.ReadFile(fmt.Sprintf("%s.%d", path, i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, position, err
+		}
+		result = append(splitProcessLogLines(data), result...)
+	}
+	if len(result) > n {
+		result = result[len(result)-n:]
+	}
+	return result, position, nil
+}
+
+/* Находит последний по времени файл лога процесса с указанным PID */
+func findProcessLogPath(pid int32) string {
+	paths, _ := filepath.Glob(filepath.Join(processLogDir, strconv.Itoa(int(pid))+"-*.log"))
+	if len(paths) == 0 {
+		return ""
+	}
+	sort.Strings(paths)
+	return paths[len(paths)-1]
+}
+
+/* Возвращает активный лог процесса или nil */
+func activeProcessLog(pid int32) *processLog {
+	activeProcessLogsMutex.Lock()
+	defer activeProcessLogsMutex.Unlock()
+	return activeProcessLogs[pid]
+}
+
+/* Возвращает число строк, ограниченное допустимым диапазоном */
+func normalizeProcessLogLines(n int) int {
+	if n <= 0 {
+		return defaultProcessLogLines
+	}
+	if n > maxProcessLogLines {
+		return maxProcessLogLines
+	}
+	return n
+}
+
+/* Возвращает последние n строк вывода процесса, запущенного агентом, или nil если лог не найден */
+func GetProcessLogTail(pid int32, n int) (*ProcessLogTail, error) {
+	tail, _, err := readProcessLogTail(pid, n)
+	return tail, err
+}
+
+/* Читает последние строки лога процесса и возвращает позицию, с которой продолжается чтение новых строк */
+func readProcessLogTail(pid int32, n int) (*ProcessLogTail, processLogPosition, error) {
+	n = normalizeProcessLogLines(n)
+
+	tail := &ProcessLogTail{PID: pid}
+	if active := activeProcessLog(pid); active != nil {
+		active.mutex.Lock()
+		tail.Path = active.path
+		active.mutex.Unlock()
+		tail.Running = true
+	} else {
+		tail.Path = findProcessLogPath(pid)
+	}
+	if tail.Path == "" {
+		return nil, processLogPosition{}, nil
+	}
+
+	lines, position, err := tailProcessLogFiles(tail.Path, n)
+	if err != nil {
+		return nil, position, err
+	}
+	tail.Lines = lines
+	return tail, position, nil
+}
+
+/*
+Возвращает последние n строк вывода процесса и канал, в который передаются новые строки по мере появления
+
+	Канал закрывается после завершения процесса или закрытия stop. Для завершившегося процесса канал закрывается сразу
+*/
+func FollowProcessLog(pid int32, n int, stop <-chan struct{}) (*ProcessLogTail, <-chan []string, error) {
+	tail, position, err := readProcessLogTail(pid, n)
+	if err != nil || tail == nil {
+		return nil, nil, err
+	}
+
+	lines := make(chan []string, 16)
+	active := activeProcessLog(pid)
+	if !tail.Running || active == nil {
+		close(lines)
+		return tail, lines, nil
+	}
+
+	go func() {
+		defer close(lines)
+		ticker := time.NewTicker(processLogPollInterval)
+		defer ticker.Stop()
+
+		var partial []byte
+		for {
+			finished := false
+			select {
+			case <-stop:
+				return
+			case <-active.done:
+				finished = true
+			case <-ticker.C:
+			}
+
+			data, next, _ := readProcessLogFrom(tail.Path, position)
+			position = next
+			data = append(partial, data...)
+			partial = nil
+			if i := bytes.LastIndexByte(data, '\n'); i < len(data)-1 && !finished {
+				partial = append(partial, data[i+1:]...)
+				data = data[:i+1]
+			}
+			if len(data) > 0 {
+				select {
+				case lines <- splitProcessLogLines(data):
+				case <-stop:
+					return
+				}
+			}
+			if finished {
+				return
+			}
+		}
+	}()
+	return tail, lines, nil
+}
+
+/* Позиция чтения лога: до какого места прочитан файл и какой это файл */
+type processLogPosition struct {
+	offset int64
+	file   os.FileInfo
+}
+
+/*
+Читает содержимое лога с позиции position и возвращает новую позицию
+
+	Если файл с прошлого чтения ротирован, сначала дочитывается конец прежнего файла, который стал .1,
+	поэтому строки, записанные перед ротацией, не пропускаются
+*/
+func readProcessLogFrom(path string, position processLogPosition) ([]byte, processLogPosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, position, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, position, err
+	}
+
+	var data []byte
+	offset := position.offset
+	if position.file != nil && !os.SameFile(position.file, info) {
+		if rotated, err := os.Open(path + ".1"); err == nil {
+			if _, err := rotated.Seek(offset, io.SeekStart); err == nil {
+				data, _ = io.ReadAll(rotated)
+			}
+			rotated.Close()
+		}
+		offset = 0
+	} else if info.Size() < offset {
+		offset = 0
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, position, err
+	}
+	rest, _ := io.ReadAll(file)
+	return append(data, rest...), processLogPosition{offset: offset + int64(len(rest)), file: info}, nil
+}
+
+/* Запускает удаление старых логов процессов при старте агента и затем раз в processLogCleanupInterval */
+func StartProcessLogCleanup() {
+	processLogCleanupOnce.Do(func() {
+		go func() {
+			cleanupProcessLogs()
+			ticker := time.NewTicker(processLogCleanupInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				cleanupProcessLogs()
+			}
+		}()
+	})
+}
+
+/* Удаляет логи, в которые не писали дольше processLogRetention, логи работающих процессов не удаляются */
+func cleanupProcessLogs() {
+	entries, err := os.ReadDir(processLogDir)
+	if err != nil {
+		return
+	}
+
+	activeProcessLogsMutex.Lock()
+	activePaths := make(map[string]bool, len(activeProcessLogs))
+	for _, l := range activeProcessLogs {
+		activePaths[filepath.Base(l.path)] = true
+	}
+	activeProcessLogsMutex.Unlock()
+
+	cutoff := time.Now().Add(-processLogRetention)
+	for _, entry := range entries {
+		name := entry.Name()
+		base := name
+		if i := strings.Index(name, ".log"); i >= 0 {
+			base = name[:i+len(".log")]
+		}
+		if activePaths[base] {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(processLogDir, name))
+		}
+	}
+}
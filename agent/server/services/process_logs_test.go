@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+/* Читает ротированные файлы от старых к новым и текущий файл лога */
+func readAllProcessLogFiles(t *testing.T, path string) []byte {
+	t.Helper()
+	var all []byte
+	for i := processLogMaxFiles; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		data, err := os.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("не удалось прочитать %s: %v", name, err)
+		}
+		if len(data) > processLogMaxSize {
+			t.Errorf("%s: %d байт, больше предела %d", name, len(data), processLogMaxSize)
+		}
+		all = append(all, data...)
+	}
+	return all
+}
+
+func TestProcessLogWriterRotation(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunk     int
+		total     int
+		wantFiles int // Сколько ротированных файлов должно остаться
+	}{
+		{"без ротации", 1024, processLogMaxSize / 2, 0},
+		{"ровно предел", 1024, processLogMaxSize, 0},
+		{"одна ротация", 32 * 1024, processLogMaxSize + 1, 1},
+		{"старые файлы сдвигаются", 32 * 1024, 2*processLogMaxSize + 100, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "1-test.log")
+			writer := &processLogWriter{path: path}
+			defer writer.close()
+
+			var written []byte
+			for len(written) < tt.total {
+				n := tt.chunk
+				if rest := tt.total - len(written); rest < n {
+					n = rest
+				}
+				chunk := bytes.Repeat([]byte{byte('a' + len(written)/tt.chunk%26)}, n)
+				if _, err := writer.Write(chunk); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				written = append(written, chunk...)
+			}
+
+			if got := readAllProcessLogFiles(t, path); !bytes.Equal(got, written) {
+				t.Fatalf("после ротации в файлах %d байт, записано %d, содержимое не совпадает", len(got), len(written))
+			}
+			for i := 1; i <= processLogMaxFiles; i++ {
+				_, err := os.Stat(fmt.Sprintf("%s.%d", path, i))
+				if exists := err == nil; exists != (i <= tt.wantFiles) {
+					t.Errorf("файл .%d существует: %v, ожидалось %v", i, exists, i <= tt.wantFiles)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessLogWriterKeepsMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1-test.log")
+	writer := &processLogWriter{path: path}
+	defer writer.close()
+
+	chunk := bytes.Repeat([]byte("x"), processLogMaxSize)
+	for i := 0; i < processLogMaxFiles+3; i++ {
+		if _, err := writer.Write(chunk); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, processLogMaxFiles)); err != nil {
+		t.Errorf("нет последнего хранимого файла: %v", err)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, processLogMaxFiles+1)); !os.IsNotExist(err) {
+		t.Errorf("хранится больше %d старых файлов", processLogMaxFiles)
+	}
+}
+
+func TestReadProcessLogFromAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1-test.log")
+	writer := &processLogWriter{path: path}
+	defer writer.close()
+
+	writer.Write([]byte("первая\n"))
+	data, position, err := readProcessLogFrom(path, processLogPosition{})
+	if err != nil || string(data) != "первая\n" {
+		t.Fatalf("readProcessLogFrom() = %q, %v", data, err)
+	}
+
+	writer.Write([]byte("до ротации\n"))
+	if err := writer.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	writer.Write([]byte("после ротации, строка длиннее прочитанного\n"))
+
+	data, position, err = readProcessLogFrom(path, position)
+	if err != nil {
+		t.Fatalf("readProcessLogFrom: %v", err)
+	}
+	if want := "до ротации\nпосле ротации, строка длиннее прочитанного\n"; string(data) != want {
+		t.Fatalf("после ротации прочитано %q, ожидалось %q", data, want)
+	}
+
+	writer.Write([]byte("еще\n"))
+	if data, _, _ = readProcessLogFrom(path, position); string(data) != "еще\n" {
+		t.Fatalf("прочитано %q, ожидалось %q", data, "еще\n")
+	}
+}
+
+func TestProcessLogFilesPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("права доступа unix не поддерживаются")
+	}
+	path := filepath.Join(t.TempDir(), "1-test.log")
+	writer := &processLogWriter{path: path}
+	defer writer.close()
+
+	writer.Write([]byte("до ротации\n"))
+	if err := writer.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	writer.Write([]byte("после ротации\n"))
+
+	for _, name := range []string{path, path + ".1"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("%s: права %o, ожидалось 600", filepath.Base(name), mode)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+/* Поддерживаемые rlimit: cpu в секундах, nofile и nproc - количество, остальные в байтах */
+var processRlimitResources = map[string]int{
+	"as":      unix.RLIMIT_AS,
+	"core":    unix.RLIMIT_CORE,
+	"cpu":     unix.RLIMIT_CPU,
+	"data":    unix.RLIMIT_DATA,
+	"fsize":   unix.RLIMIT_FSIZE,
+	"memlock": unix.RLIMIT_MEMLOCK,
+	"nofile":  unix.RLIMIT_NOFILE,
+	"nproc":   unix.RLIMIT_NPROC,
+	"stack":   unix.RLIMIT_STACK,
+}
+
+/* Аргумент, с которым агент запускается как вспомогательный процесс для установки rlimit */
+const rlimitHelperArg = "__nexora-rlimit-exec"
+
+/*
+Выполняет роль вспомогательного процесса, если агент запущен с rlimitHelperArg
+
+	Вызывается до main, чтобы вспомогательный процесс не запускал ничего, кроме установки rlimit и exec команды
+*/
+func init() {
+	if len(os.Args) > 1 && os.Args[1] == rlimitHelperArg {
+		runRlimitHelper(os.Args[2:])
+	}
+}
+
+/*
+Переделывает команду так, чтобы она запускалась через агент во вспомогательном режиме
+
+	Вспомогательный процесс задает rlimit, переключается на пользователя из SysProcAttr.Credential и выполняет exec
+	исходной команды, поэтому ограничения действуют с первой инструкции процесса и наследуются всеми его потомками.
+	Об ошибке вспомогательный процесс сообщает через канал статуса, который закрывается при успешном exec
+*/
+func wrapRlimitHelper(cmd *exec.Cmd, limits map[string]uint64) (*rlimitHelper, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти исполняемый файл агента для установки rlimit: %w", err)
+	}
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	specs := make([]string, 0, len(names))
+	for _, name := range names {
+		specs = append(specs, name+"="+strconv.FormatUint(limits[name], 10))
+	}
+
+	credential := "-"
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
+		cred := cmd.SysProcAttr.Credential
+		groups := make([]string, 0, len(cred.Groups))
+		for _, group := range cred.Groups {
+			groups = append(groups, strconv.FormatUint(uint64(group), 10))
+		}
+		credential = fmt.Sprintf("%d:%d:%s", cred.Uid, cred.Gid, strings.Join(groups, ","))
+		cmd.SysProcAttr.Credential = nil
+	}
+
+	status, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	statusFD := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, writer)
+
+	args := []string{executable, rlimitHelperArg, strconv.Itoa(statusFD), strings.Join(specs, ","), credential, cmd.Path}
+	cmd.Args = append(args, cmd.Args...)
+	cmd.Path = executable
+	return &rlimitHelper{status: status, writer: writer}, nil
+}
+
+/*
+Задает rlimit и пользователя текущего процесса и заменяет его командой, при ошибке пишет ее в канал статуса
+
+	Аргументы: номер дескриптора статуса, rlimit в виде name=value через запятую, uid:gid:группы или "-", путь и argv команды
+*/
+func runRlimitHelper(args []string) {
+	if len(args) < 5 {
+		os.Exit(127)
+	}
+	fd, err := strconv.Atoi(args[0])
+	if err != nil {
+		os.Exit(127)
+	}
+	syscall.CloseOnExec(fd)
+	status := os.NewFile(uintptr(fd), "rlimit-status")
+	fail := func(format string, a ...interface{}) {
+		status.WriteString(fmt.Sprintf(format, a...))
+		os.Exit(127)
+	}
+
+	for _, spec := range strings.Split(args[1], ",") {
+		name, value, _ := strings.Cut(spec, "=")
+		resource, ok := processRlimitResources[name]
+		limit, err := strconv.ParseUint(value, 10, 64)
+		if !ok || err != nil {
+			fail("некорректный rlimit '%s'", spec)
+		}
+		// syscall.Setrlimit, а не unix.Setrlimit: иначе syscall.Exec вернет исходный nofile, сохраненный рантаймом Go
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			fail("не удалось задать rlimit %s: %v", name, err)
+		}
+	}
+
+	if args[2] != "-" {
+		parts := strings.Split(args[2], ":")
+		if len(parts) != 3 {
+			fail("некорректный пользователь '%s'", args[2])
+		}
+		uid, uidErr := strconv.Atoi(parts[0])
+		gid, gidErr := strconv.Atoi(parts[1])
+		if uidErr != nil || gidErr != nil {
+			fail("некорректный пользователь '%s'", args[2])
+		}
+		groups := []int{}
+		for _, value := range strings.Split(parts[2], ",") {
+			if group, err := strconv.Atoi(value); err == nil {
+				groups = append(groups, group)
+			}
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			fail("не удалось задать группы: %v", err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			fail("не удалось сменить группу: %v", err)
+		}
+		if err := syscall.Setuid(uid); err != nil {
+			fail("не удалось сменить пользователя: %v", err)
+		}
+	}
+
+	err = syscall.Exec(args[3], args[4:], os.Environ())
+	fail("не удалось запустить %s: %v", args[3], err)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+/* Вне Linux rlimit запущенного процесса не задаются */
+var processRlimitResources = map[string]int{}
+
+/* Переделывает команду для запуска с rlimit */
+func wrapRlimitHelper(cmd *exec.Cmd, limits map[string]uint64) (*rlimitHelper, error) {
+	return nil, fmt.Errorf("rlimits поддерживаются только в Linux")
+}
@@ -0,0 +1,172 @@
+/* Сервисы для отправки сигналов процессам и мягкого завершения */
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+/* Итог мягкого завершения процесса */
+type GracefulStopResult struct {
+	PID        int32   `json:"pid"`
+	Outcome    string  `json:"outcome"` // terminated - завершился по SIGTERM, killed - после SIGKILL, running - не завершился
+	GraceSec   int     `json:"graceSec"`
+	ElapsedSec float64 `json:"elapsedSec"`
+	Message    string  `json:"message"`
+}
+
+const (
+	defaultStopGraceSec = 10
+	maxStopGraceSec     = 300
+)
+
+/* Как часто проверяется, завершился ли процесс после сигнала */
+const stopPollInterval = 100 * time.Millisecond
+
+/* Сколько ждать завершения процесса после SIGKILL */
+const killWaitTimeout = 2 * time.Second
+
+/* Возвращает названия сигналов, которые можно отправить процессу на этой платформе */
+func ProcessSignalNames() []string {
+	names := make([]string, 0, len(processSignals))
+	for name := range processSignals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/* Находит сигнал по названию, регистр и префикс SIG не важны: term, SIGTERM и sigterm - один сигнал */
+func parseProcessSignal(name string) (string, syscall.Signal, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(normalized, "SIG") {
+		normalized = "SIG" + normalized
+	}
+	sig, ok := processSignals[normalized]
+	if !ok {
+		return "", 0, fmt.Errorf("неподдерживаемый сигнал '%s', доступны: %s", name, strings.Join(ProcessSignalNames(), ", "))
+	}
+	return normalized, sig, nil
+}
+
+/*
+Загружает процесс по PID и проверяет, что он существует и ему можно отправить сигнал
+
+	PID 1, потоки ядра и сам агент защищены так же, как при завершении дерева процессов. Агенту разрешен только SIGCONT,
+	чтобы его можно было продолжить после остановки извне
+*/
+func loadSignalTarget(pid int32, sig syscall.Signal) (*process.Process, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("PID должен быть положительным числом")
+	}
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, fmt.Errorf("процесс с PID %d не найден", pid)
+	}
+
+	entry := &processTableEntry{proc: proc, target: ProcessKillTarget{PID: pid}}
+	if ppid, err := proc.Ppid(); err == nil {
+		entry.target.ParentPID = ppid
+	}
+	resumeSelf := pid == int32(os.Getpid()) && sig != 0 && sig == processSignals["SIGCONT"]
+	if reason := protectedProcessReason(entry); reason != "" && !resumeSelf {
+		return nil, fmt.Errorf("процесс %d защищен от сигналов: %s", pid, reason)
+	}
+	return proc, nil
+}
+
+/* Отправляет процессу сигнал по названию и возвращает его каноническое название */
+func SendProcessSignal(pid int32, signalName string) (string, error) {
+	name, sig, err := parseProcessSignal(signalName)
+	if err != nil {
+		return "", err
+	}
+	proc, err := loadSignalTarget(pid, sig)
+	if err != nil {
+		return "", err
+	}
+	if err := sendProcessSignal(proc, sig); err != nil {
+		return "", fmt.Errorf("не удалось отправить %s процессу %d: %w", name, pid, err)
+	}
+	return name, nil
+}
+
+/* Проверяет, завершился ли процесс. Зомби тоже считается завершенным: он уже не выполняется и ждет родителя */
+func processStopped(proc *process.Process) bool {
+	running, err := proc.IsRunning()
+	if err != nil || !running {
+		return true
+	}
+	status, err := proc.Status()
+	if err != nil {
+		return true
+	}
+	for _, s := range status {
+		if s == process.Zombie {
+			return true
+		}
+	}
+	return false
+}
+
+/* Ждет завершения процесса не дольше timeout */
+func waitProcessStopped(proc *process.Process, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if processStopped(proc) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+/*
+Мягко завершает процесс: отправляет SIGTERM, ждет graceSec секунд и, если процесс не завершился, отправляет SIGKILL
+
+	graceSec равный 0 заменяется значением по умолчанию. Остановленный процесс после SIGTERM продолжается сигналом SIGCONT,
+	чтобы он мог обработать завершение
+*/
+func GracefulStopProcess(pid int32, graceSec int) (*GracefulStopResult, error) {
+	if graceSec == 0 {
+		graceSec = defaultStopGraceSec
+	}
+	if graceSec < 0 || graceSec > maxStopGraceSec {
+		return nil, fmt.Errorf("graceSec должен быть от 1 до %d секунд", maxStopGraceSec)
+	}
+	proc, err := loadSignalTarget(pid, syscall.SIGTERM)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	result := &GracefulStopResult{PID: pid, GraceSec: graceSec}
+	if err := sendProcessSignal(proc, syscall.SIGTERM); err != nil {
+		return nil, fmt.Errorf("не удалось отправить SIGTERM процессу %d: %w", pid, err)
+	}
+	resumeStoppedProcess(proc)
+
+	if waitProcessStopped(proc, time.Duration(graceSec)*time.Second) {
+		result.Outcome = "terminated"
+		result.Message = "Процесс завершился после SIGTERM"
+	} else if err := proc.Kill(); err != nil && !processStopped(proc) {
+		result.Outcome = "running"
+		result.Message = "Процесс не завершился за отведенное время, SIGKILL не отправлен: " + err.Error()
+	} else if waitProcessStopped(proc, killWaitTimeout) {
+		result.Outcome = "killed"
+		result.Message = fmt.Sprintf("Процесс не завершился за отведенное время (%d с) и был завершен SIGKILL", graceSec)
+	} else {
+		result.Outcome = "running"
+		result.Message = "Процесс продолжает работать после SIGKILL"
+	}
+	result.ElapsedSec = time.Since(started).Seconds()
+	return result, nil
+}
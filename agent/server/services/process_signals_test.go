@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSendProcessSignalProtected(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("в Windows нет SIGSTOP и SIGCONT")
+	}
+	self := int32(os.Getpid())
+
+	tests := []struct {
+		name    string
+		pid     int32
+		signal  string
+		wantErr string
+	}{
+		{"остановка агента", self, "STOP", "процесс агента"},
+		{"завершение агента", self, "SIGKILL", "процесс агента"},
+		{"продолжение агента", self, "CONT", ""},
+		{"сигнал init", 1, "TERM", "PID 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SendProcessSignal(tt.pid, tt.signal)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ожидалась успешная отправка, получено %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ожидалась ошибка с %q, получено %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGracefulStopProcessProtected(t *testing.T) {
+	if _, err := GracefulStopProcess(int32(os.Getpid()), 1); err == nil || !strings.Contains(err.Error(), "процесс агента") {
+		t.Fatalf("агент не должен завершать сам себя, получено %v", err)
+	}
+}
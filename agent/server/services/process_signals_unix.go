@@ -0,0 +1,105 @@
+//go:build !windows
+
+package services
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"golang.org/x/sys/unix"
+)
+
+/* Сигналы, которые можно отправить процессу */
+var processSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGCONT": syscall.SIGCONT,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+/* Отправляет сигнал процессу */
+func sendProcessSignal(proc *process.Process, sig syscall.Signal) error {
+	return proc.SendSignal(sig)
+}
+
+/* Продолжает процесс, если он был остановлен, чтобы он мог обработать SIGTERM */
+func resumeStoppedProcess(proc *process.Process) {
+	proc.SendSignal(syscall.SIGCONT)
+}
+
+/*
+Мягко завершает группу процессов, лидером которой является pid: SIGTERM всей группе, через graceSec секунд SIGKILL
+
+	Используется для процессов, запущенных агентом в собственной группе, чтобы вместе с ними завершались их потомки.
+	Если процесс не лидер своей группы или это группа агента, завершается только сам процесс
+*/
+func stopProcessGroup(pid int32, graceSec int) (*GracefulStopResult, error) {
+	if graceSec == 0 {
+		graceSec = defaultStopGraceSec
+	}
+	pgid, err := unix.Getpgid(int(pid))
+	if err != nil || pgid != int(pid) || pgid == unix.Getpgrp() {
+		return GracefulStopProcess(pid, graceSec)
+	}
+
+	started := time.Now()
+	result := &GracefulStopResult{PID: pid, GraceSec: graceSec}
+	if err := unix.Kill(-pgid, unix.SIGTERM); err != nil && err != unix.ESRCH {
+		return nil, fmt.Errorf("не удалось отправить SIGTERM группе процессов %d: %w", pgid, err)
+	}
+	unix.Kill(-pgid, unix.SIGCONT)
+
+	if waitProcessGroupStopped(int32(pgid), time.Duration(graceSec)*time.Second) {
+		result.Outcome = "terminated"
+		result.Message = "Группа процессов завершилась после SIGTERM"
+	} else if err := unix.Kill(-pgid, unix.SIGKILL); err != nil && err != unix.ESRCH {
+		result.Outcome = "running"
+		result.Message = "Группа процессов не завершилась за отведенное время, SIGKILL не отправлен: " + err.Error()
+	} else if waitProcessGroupStopped(int32(pgid), killWaitTimeout) {
+		result.Outcome = "killed"
+		result.Message = fmt.Sprintf("Группа процессов не завершилась за отведенное время (%d с) и была завершена SIGKILL", graceSec)
+	} else {
+		result.Outcome = "running"
+		result.Message = "Группа процессов продолжает работать после SIGKILL"
+	}
+	result.ElapsedSec = time.Since(started).Seconds()
+	return result, nil
+}
+
+/* Ждет, пока в группе процессов не останется работающих процессов, не дольше timeout */
+func waitProcessGroupStopped(pgid int32, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !processGroupAlive(pgid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+/* Проверяет, есть ли в группе процессов работающий процесс, зомби не учитываются */
+func processGroupAlive(pgid int32) bool {
+	pids, err := process.Pids()
+	if err != nil {
+		return false
+	}
+	for _, pid := range pids {
+		if group, err := unix.Getpgid(int(pid)); err != nil || group != int(pgid) {
+			continue
+		}
+		if proc, err := process.NewProcess(pid); err == nil && !processStopped(proc) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package services
+
+import (
+	"syscall"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+/* В Windows нет POSIX-сигналов, процесс можно только завершить */
+var processSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+/* Завершает процесс: оба поддерживаемых сигнала в Windows означают TerminateProcess */
+func sendProcessSignal(proc *process.Process, sig syscall.Signal) error {
+	return proc.Kill()
+}
+
+/* В Windows процессы не останавливаются сигналами, продолжать нечего */
+func resumeStoppedProcess(proc *process.Process) {}
+
+/* В Windows нет групп процессов POSIX, процесс завершается отдельно */
+func stopProcessGroup(pid int32, graceSec int) (*GracefulStopResult, error) {
+	return GracefulStopProcess(pid, graceSec)
+}
@@ -0,0 +1,96 @@
+/* Сервисы для построения дерева процессов по ParentPID */
+package services
+
+import (
+	"sort"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Узел дерева процессов с показателями всего поддерева */
+type ProcessTreeNode struct {
+	PID             int32              `json:"pid"`
+	ParentPID       int32              `json:"parentPid"`
+	Name            string             `json:"name"`
+	Username        string             `json:"username"`
+	Status          string             `json:"status"`
+	CPUPercent      float64            `json:"cpuPercent"`
+	MemoryRSS       uint64             `json:"memoryRss"`
+	SubtreeCPU      float64            `json:"subtreeCpu"` // CPU процесса и всех потомков
+	SubtreeRSS      uint64             `json:"subtreeRss"` // RSS процесса и всех потомков
+	ChildCount      int                `json:"childCount"` // Количество прямых потомков
+	DescendantCount int                `json:"descendantCount"`
+	Children        []*ProcessTreeNode `json:"children"`
+}
+
+/*
+Строит дерево процессов по ParentPID и считает показатели поддеревьев
+
+	Корнями становятся процессы, родителя которых нет в списке. Потомки отсортированы по PID
+*/
+func BuildProcessTree(procs []models.ProcessInfo) []*ProcessTreeNode {
+	nodes := make(map[int32]*ProcessTreeNode, len(procs))
+	for _, p := range procs {
+		nodes[p.PID] = &ProcessTreeNode{
+			PID:        p.PID,
+			ParentPID:  p.ParentPID,
+			Name:       p.Name,
+			Username:   p.Username,
+			Status:     p.Status,
+			CPUPercent: p.CPUPercent,
+			MemoryRSS:  p.MemoryRSS,
+			Children:   []*ProcessTreeNode{},
+		}
+	}
+
+	roots := []*ProcessTreeNode{}
+	for _, node := range nodes {
+		parent, ok := nodes[node.ParentPID]
+		if !ok || node.ParentPID == node.PID {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortProcessTree(roots)
+	for _, root := range roots {
+		aggregateProcessTree(root)
+	}
+	return roots
+}
+
+/* Находит узел процесса pid в дереве, nil если его нет */
+func FindProcessTreeNode(roots []*ProcessTreeNode, pid int32) *ProcessTreeNode {
+	for _, node := range roots {
+		if node.PID == pid {
+			return node
+		}
+		if found := FindProcessTreeNode(node.Children, pid); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+/* Сортирует узлы и их потомков по PID */
+func sortProcessTree(nodes []*ProcessTreeNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].PID < nodes[j].PID })
+	for _, node := range nodes {
+		sortProcessTree(node.Children)
+	}
+}
+
+/* Считает CPU, RSS и количество потомков поддерева */
+func aggregateProcessTree(node *ProcessTreeNode) {
+	node.SubtreeCPU = node.CPUPercent
+	node.SubtreeRSS = node.MemoryRSS
+	node.ChildCount = len(node.Children)
+	node.DescendantCount = len(node.Children)
+	for _, child := range node.Children {
+		aggregateProcessTree(child)
+		node.SubtreeCPU += child.SubtreeCPU
+		node.SubtreeRSS += child.SubtreeRSS
+		node.DescendantCount += child.DescendantCount
+	}
+}
@@ -0,0 +1,225 @@
+/* Сервисы для завершения дерева, группы или сессии процессов */
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+/* Процесс, которому будет или был отправлен сигнал */
+type ProcessKillTarget struct {
+	PID        int32  `json:"pid"`
+	ParentPID  int32  `json:"parentPid"`
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	CreateTime int64  `json:"createTime"`
+	Error      string `json:"error,omitempty"` // Ошибка отправки сигнала, пусто при успехе или в режиме dryRun
+}
+
+/* Процесс, который попал в дерево, группу или сессию, но защищен от завершения */
+type SkippedProcess struct {
+	PID    int32  `json:"pid"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+/* План или результат завершения группы процессов */
+type ProcessKillPlan struct {
+	Scope   string              `json:"scope"` // tree - процесс и потомки, pgroup - группа процессов, session - сессия
+	RootPID int32               `json:"rootPid"`
+	GroupID int32               `json:"groupId,omitempty"` // PGID или SID для scope pgroup и session
+	Signal  string              `json:"signal"`
+	DryRun  bool                `json:"dryRun"`
+	Targets []ProcessKillTarget `json:"targets"`
+	Skipped []SkippedProcess    `json:"skipped"`
+	Failed  int                 `json:"failed"`
+}
+
+/* Запись таблицы процессов с полями, нужными для поиска дерева и группы */
+type processTableEntry struct {
+	target    ProcessKillTarget
+	proc      *process.Process
+	pgid, sid int32
+}
+
+/* Собирает таблицу всех процессов системы */
+func loadProcessTable() (map[int32]*processTableEntry, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(map[int32]*processTableEntry, len(procs))
+	for _, proc := range procs {
+		entry := &processTableEntry{proc: proc, target: ProcessKillTarget{PID: proc.Pid}}
+		if ppid, err := proc.Ppid(); err == nil {
+			entry.target.ParentPID = ppid
+		}
+		if name, err := proc.Name(); err == nil {
+			entry.target.Name = name
+		}
+		if username, err := proc.Username(); err == nil {
+			entry.target.Username = username
+		}
+		if createTime, err := proc.CreateTime(); err == nil {
+			entry.target.CreateTime = createTime
+		}
+		entry.pgid, entry.sid = processGroupIDs(proc.Pid)
+		table[proc.Pid] = entry
+	}
+	return table, nil
+}
+
+/* Возвращает причину, по которой процесс нельзя завершать, или пустую строку */
+func protectedProcessReason(entry *processTableEntry) string {
+	switch {
+	case entry.target.PID == 1:
+		return "PID 1 (init)"
+	case entry.target.PID == int32(os.Getpid()):
+		return "процесс агента"
+	case isKernelThread(entry.target.PID, entry.target.ParentPID):
+		return "поток ядра"
+	}
+	return ""
+}
+
+/* Собирает процесс rootPID и всех его потомков по ParentPID, родители идут раньше потомков */
+func collectProcessTree(table map[int32]*processTableEntry, rootPID int32) []*processTableEntry {
+	children := make(map[int32][]int32)
+	for pid, entry := range table {
+		if pid != entry.target.ParentPID {
+			children[entry.target.ParentPID] = append(children[entry.target.ParentPID], pid)
+		}
+	}
+
+	result := []*processTableEntry{table[rootPID]}
+	for i := 0; i < len(result); i++ {
+		kids := children[result[i].target.PID]
+		sort.Slice(kids, func(a, b int) bool { return kids[a] < kids[b] })
+		for _, pid := range kids {
+			result = append(result, table[pid])
+		}
+	}
+	return result
+}
+
+/* Собирает процессы с тем же PGID или SID, что и rootPID, корневой процесс идет первым */
+func collectProcessGroup(table map[int32]*processTableEntry, rootPID int32, scope string) ([]*processTableEntry, int32, error) {
+	root := table[rootPID]
+	groupID := root.pgid
+	if scope == "session" {
+		groupID = root.sid
+	}
+	if groupID <= 0 {
+		return nil, 0, fmt.Errorf("не удалось определить группу процесса %d", rootPID)
+	}
+
+	result := []*processTableEntry{root}
+	var members []*processTableEntry
+	for pid, entry := range table {
+		id := entry.pgid
+		if scope == "session" {
+			id = entry.sid
+		}
+		if pid != rootPID && id == groupID {
+			members = append(members, entry)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].target.PID < members[j].target.PID })
+	return append(result, members...), groupID, nil
+}
+
+/*
+Строит план завершения дерева (scope tree), группы (pgroup) или сессии (session) процесса pid
+
+	Защищенные процессы (PID 1, сам агент и потоки ядра) в план не попадают и перечисляются в skipped.
+	Если защищен сам процесс pid, возвращается ошибка
+*/
+func PlanProcessKill(pid int32, scope string, signalName string) (*ProcessKillPlan, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("PID должен быть положительным числом")
+	}
+	if scope == "" {
+		scope = "tree"
+	}
+	if scope != "tree" && scope != "pgroup" && scope != "session" {
+		return nil, fmt.Errorf("scope должен быть tree, pgroup или session")
+	}
+	if signalName == "" {
+		signalName = "SIGKILL"
+	}
+	signal, _, err := parseProcessSignal(signalName)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := loadProcessTable()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список процессов: %w", err)
+	}
+	root, ok := table[pid]
+	if !ok {
+		return nil, fmt.Errorf("процесс с PID %d не найден", pid)
+	}
+	if reason := protectedProcessReason(root); reason != "" {
+		return nil, fmt.Errorf("процесс %d защищен от завершения: %s", pid, reason)
+	}
+
+	plan := &ProcessKillPlan{Scope: scope, RootPID: pid, Signal: signal, Targets: []ProcessKillTarget{}, Skipped: []SkippedProcess{}}
+	var entries []*processTableEntry
+	if scope == "tree" {
+		entries = collectProcessTree(table, pid)
+	} else if entries, plan.GroupID, err = collectProcessGroup(table, pid, scope); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if reason := protectedProcessReason(entry); reason != "" {
+			plan.Skipped = append(plan.Skipped, SkippedProcess{PID: entry.target.PID, Name: entry.target.Name, Reason: reason})
+			continue
+		}
+		plan.Targets = append(plan.Targets, entry.target)
+	}
+	return plan, nil
+}
+
+/*
+Завершает дерево, группу или сессию процесса по плану PlanProcessKill
+
+	При dryRun сигналы не отправляются, возвращается только план. Сигнал отправляется каждому процессу отдельно,
+	начиная с корневого, чтобы родитель не успел перезапустить завершенных потомков. Процесс, PID которого
+	с момента построения плана занял другой процесс, пропускается
+*/
+func KillProcessGroup(pid int32, scope string, signalName string, dryRun bool) (*ProcessKillPlan, error) {
+	plan, err := PlanProcessKill(pid, scope, signalName)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		plan.DryRun = true
+		return plan, nil
+	}
+	_, sig, _ := parseProcessSignal(plan.Signal)
+
+	for i := range plan.Targets {
+		target := &plan.Targets[i]
+		proc, err := process.NewProcess(target.PID)
+		if err != nil {
+			target.Error = "процесс уже завершен"
+			continue
+		}
+		if createTime, err := proc.CreateTime(); err == nil && createTime != target.CreateTime {
+			target.Error = "PID занят другим процессом"
+			plan.Failed++
+			continue
+		}
+		if err := sendProcessSignal(proc, sig); err != nil {
+			target.Error = err.Error()
+			plan.Failed++
+		}
+	}
+	return plan, nil
+}
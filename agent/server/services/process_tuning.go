@@ -0,0 +1,594 @@
+/* Сервисы для изменения приоритета, привязки к CPU и класса ввода-вывода процессов */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+/* Настройки планирования процесса, незаданные поля не меняются */
+type ProcessTuning struct {
+	Nice     *int   `json:"nice,omitempty"`     // От -20 (высший приоритет) до 19
+	Affinity []int  `json:"affinity,omitempty"` // Номера CPU, на которых может выполняться процесс
+	IOClass  string `json:"ioClass,omitempty"`  // "none", "realtime", "best-effort" или "idle"
+	IOLevel  *int   `json:"ioLevel,omitempty"`  // От 0 (высший) до 7 для realtime и best-effort
+}
+
+/* Изменение одной настройки процесса */
+type ProcessTuningChange struct {
+	Setting  string `json:"setting"` // "nice", "affinity" или "ionice"
+	Previous string `json:"previous"`
+	New      string `json:"new"`
+	Changed  bool   `json:"changed"`
+	Error    string `json:"error,omitempty"`
+}
+
+/* Результат изменения настроек одного процесса */
+type ProcessTuningResult struct {
+	PID     int32                 `json:"pid"`
+	Name    string                `json:"name"`
+	Changes []ProcessTuningChange `json:"changes"`
+}
+
+/* Правило, по которому настройки применяются к новым процессам с подходящим именем */
+type ProcessTuningRule struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	MatchName string    `json:"matchName"` // Шаблон имени процесса, поддерживаются * и ?
+	ProcessTuning
+	Enabled bool `json:"enabled"`
+}
+
+/* Запись журнала изменений настроек процессов */
+type ProcessTuningAudit struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	PID         int32     `json:"pid"`
+	ProcessName string    `json:"processName"`
+	Setting     string    `json:"setting"`
+	Previous    string    `json:"previous"`
+	New         string    `json:"new"`
+	Actor       string    `json:"actor"`
+	RuleID      *int64    `json:"ruleId"`
+	Error       string    `json:"error,omitempty"`
+}
+
+/* Классы ввода-вывода и их номера в ioprio */
+var ioClasses = map[string]int{
+	"none":        0,
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+/* Процесс, к которому правило уже применено: PID и время создания, чтобы не спутать с новым процессом на том же PID */
+type tunedProcessKey struct {
+	pid        int32
+	createTime int64
+}
+
+var (
+	tuningRulesCache      []ProcessTuningRule
+	tuningRulesLoaded     bool
+	tuningRulesGeneration uint64 // Растет при каждом сбросе кэша, чтобы не сохранить правила, прочитанные до изменения
+	tuningRulesMutex      sync.RWMutex
+
+	tunedProcesses      = make(map[tunedProcessKey]bool)
+	tunedProcessesMutex sync.Mutex
+)
+
+/* Проверяет корректность настроек: хотя бы одна задана, значения в допустимых пределах */
+func (t *ProcessTuning) Validate() error {
+	if t.Nice == nil && len(t.Affinity) == 0 && t.IOClass == "" {
+		return fmt.Errorf("укажите nice, affinity или ioClass")
+	}
+	if t.Nice != nil && (*t.Nice < -20 || *t.Nice > 19) {
+		return fmt.Errorf("nice должен быть от -20 до 19")
+	}
+	if len(t.Affinity) > 0 {
+		count, err := cpu.Counts(true)
+		if err != nil {
+			return fmt.Errorf("не удалось получить количество CPU: %w", err)
+		}
+		for _, c := range t.Affinity {
+			if c < 0 || c >= count {
+				return fmt.Errorf("номер CPU %d вне диапазона 0-%d", c, count-1)
+			}
+		}
+		t.Affinity = normalizeCPUList(t.Affinity)
+	}
+	if t.IOClass == "" {
+		if t.IOLevel != nil {
+			return fmt.Errorf("ioLevel задается вместе с ioClass")
+		}
+		return nil
+	}
+	if _, ok := ioClasses[t.IOClass]; !ok {
+		return fmt.Errorf("ioClass должен быть none, realtime, best-effort или idle")
+	}
+	if t.IOClass == "none" || t.IOClass == "idle" {
+		t.IOLevel = nil
+	} else if t.IOLevel == nil {
+		level := 4
+		t.IOLevel = &level
+	} else if *t.IOLevel < 0 || *t.IOLevel > 7 {
+		return fmt.Errorf("ioLevel должен быть от 0 до 7")
+	}
+	return nil
+}
+
+/* Сортирует номера CPU и убирает повторы */
+func normalizeCPUList(cpus []int) []int {
+	seen := make(map[int]bool)
+	result := []int{}
+	for _, c := range cpus {
+		if !seen[c] {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+/* Форматирует список CPU диапазонами, как taskset: "0-3,6" */
+func formatCPUList(cpus []int) string {
+	cpus = normalizeCPUList(cpus)
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+/* Форматирует класс и уровень ввода-вывода: "best-effort:4" или "idle" */
+func formatIOPriority(class string, level int) string {
+	if class == "none" || class == "idle" {
+		return class
+	}
+	return fmt.Sprintf("%s:%d", class, level)
+}
+
+/* Находит название класса ввода-вывода по номеру */
+func ioClassName(class int) string {
+	for name, value := range ioClasses {
+		if value == class {
+			return name
+		}
+	}
+	return "none"
+}
+
+/*
+Проверяет, может ли агент изменить настройку процесса
+
+	Без прав root можно менять только свои процессы, только понижать приоритет (увеличивать nice)
+	и нельзя назначать класс ввода-вывода realtime
+*/
+func checkTuningPermission(proc *process.Process, setting string, tuning ProcessTuning, previousNice int) error {
+	euid := os.Geteuid()
+	if euid == 0 {
+		return nil
+	}
+	if uids, err := proc.Uids(); err == nil && len(uids) > 1 && int(uids[0]) != euid && int(uids[1]) != euid {
+		return fmt.Errorf("недостаточно прав: процесс принадлежит другому пользователю")
+	}
+	if setting == "nice" && *tuning.Nice < previousNice {
+		return fmt.Errorf("недостаточно прав: повышать приоритет (уменьшать nice) может только root")
+	}
+	if setting == "ionice" && tuning.IOClass == "realtime" {
+		return fmt.Errorf("недостаточно прав: класс realtime может назначать только root")
+	}
+	return nil
+}
+
+/* Применяет настройки к процессу и записывает каждое изменение в журнал */
+func applyProcessTuning(proc *process.Process, name string, tuning ProcessTuning, actor string, ruleID *int64) ProcessTuningResult {
+	result := ProcessTuningResult{PID: proc.Pid, Name: name, Changes: []ProcessTuningChange{}}
+
+	if tuning.Nice != nil {
+		change := ProcessTuningChange{Setting: "nice", New: strconv.Itoa(*tuning.Nice)}
+		previous, err := getProcessNice(proc.Pid)
+		if err == nil {
+			change.Previous = strconv.Itoa(previous)
+			err = checkTuningPermission(proc, "nice", tuning, previous)
+		}
+		if err == nil && previous != *tuning.Nice {
+			err = setProcessNice(proc.Pid, *tuning.Nice)
+			change.Changed = err == nil
+		}
+		result.Changes = append(result.Changes, finishTuningChange(proc.Pid, name, change, err, actor, ruleID))
+	}
+
+	if len(tuning.Affinity) > 0 {
+		change := ProcessTuningChange{Setting: "affinity", New: formatCPUList(tuning.Affinity)}
+		previous, err := getProcessAffinity(proc.Pid)
+		if err == nil {
+			change.Previous = formatCPUList(previous)
+			err = checkTuningPermission(proc, "affinity", tuning, 0)
+		}
+		if err == nil && change.Previous != change.New {
+			err = setProcessAffinity(proc.Pid, tuning.Affinity)
+			change.Changed = err == nil
+		}
+		result.Changes = append(result.Changes, finishTuningChange(proc.Pid, name, change, err, actor, ruleID))
+	}
+
+	if tuning.IOClass != "" {
+		level := 0
+		if tuning.IOLevel != nil {
+			level = *tuning.IOLevel
+		}
+		change := ProcessTuningChange{Setting: "ionice", New: formatIOPriority(tuning.IOClass, level)}
+		previousClass, previousLevel, err := getProcessIOPriority(proc.Pid)
+		if err == nil {
+			change.Previous = formatIOPriority(ioClassName(previousClass), previousLevel)
+			err = checkTuningPermission(proc, "ionice", tuning, 0)
+		}
+		if err == nil && change.Previous != change.New {
+			err = setProcessIOPriority(proc.Pid, ioClasses[tuning.IOClass], level)
+			change.Changed = err == nil
+		}
+		result.Changes = append(result.Changes, finishTuningChange(proc.Pid, name, change, err, actor, ruleID))
+	}
+
+	return result
+}
+
+/* Заполняет ошибку изменения и записывает его в журнал, если настройка изменилась или не удалось ее изменить */
+func finishTuningChange(pid int32, name string, change ProcessTuningChange, err error, actor string, ruleID *int64) ProcessTuningChange {
+	if err != nil {
+		change.Error = err.Error()
+	}
+	if change.Changed || err != nil {
+		saveProcessTuningAudit(pid, name, change, actor, ruleID)
+	}
+	return change
+}
+
+/*
+Изменяет настройки процесса pid или всех процессов, имя которых подходит под шаблон name
+
+	Возвращает результат по каждому процессу с предыдущими и новыми значениями
+*/
+func TuneProcesses(pid int32, name string, tuning ProcessTuning, actor string) ([]ProcessTuningResult, error) {
+	if err := tuning.Validate(); err != nil {
+		return nil, err
+	}
+	if actor == "" {
+		actor = "api"
+	}
+
+	if pid > 0 {
+		proc, err := process.NewProcess(pid)
+		if err != nil {
+			return nil, fmt.Errorf("процесс с PID %d не найден", pid)
+		}
+		procName, _ := proc.Name()
+		return []ProcessTuningResult{applyProcessTuning(proc, procName, tuning, actor, nil)}, nil
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("укажите pid или name")
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		return nil, fmt.Errorf("некорректный шаблон имени '%s'", name)
+	}
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список процессов: %w", err)
+	}
+
+	results := []ProcessTuningResult{}
+	for _, proc := range procs {
+		procName, err := proc.Name()
+		if err != nil {
+			continue
+		}
+		if matched, _ := path.Match(name, procName); matched {
+			results = append(results, applyProcessTuning(proc, procName, tuning, actor, nil))
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("процессы с именем '%s' не найдены", name)
+	}
+	return results, nil
+}
+
+/* Создает правило, которое применяет настройки к новым процессам с подходящим именем */
+func CreateProcessTuningRule(rule ProcessTuningRule) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if rule.MatchName == "" {
+		return 0, fmt.Errorf("поле 'matchName' обязательно")
+	}
+	if _, err := path.Match(rule.MatchName, ""); err != nil {
+		return 0, fmt.Errorf("некорректный шаблон имени '%s'", rule.MatchName)
+	}
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	var affinity interface{}
+	if len(rule.Affinity) > 0 {
+		data, _ := json.Marshal(rule.Affinity)
+		affinity = string(data)
+	}
+	var ioClass interface{}
+	if rule.IOClass != "" {
+		ioClass = rule.IOClass
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO process_tuning_rules (created_at, match_name, nice, affinity, io_class, io_level, enabled) VALUES (?, ?, ?, ?, ?, ?, 1)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		rule.MatchName,
+		rule.Nice,
+		affinity,
+		ioClass,
+		rule.IOLevel,
+	)
+	if err != nil {
+		return 0, err
+	}
+	invalidateProcessTuningRules()
+	return result.LastInsertId()
+}
+
+/* Удаляет правило настроек процессов, уже измененные процессы остаются с новыми настройками */
+func DeleteProcessTuningRule(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+	result, err := db.Exec("DELETE FROM process_tuning_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("правило с ID %d не найдено", id)
+	}
+	invalidateProcessTuningRules()
+	return nil
+}
+
+/* Получает список правил настроек процессов в порядке создания */
+func GetProcessTuningRules() ([]ProcessTuningRule, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT id, created_at, match_name, nice, affinity, io_class, io_level, enabled FROM process_tuning_rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []ProcessTuningRule{}
+	for rows.Next() {
+		var r ProcessTuningRule
+		var createdAtStr string
+		var nice, ioLevel sql.NullInt64
+		var affinity, ioClass sql.NullString
+		if err := rows.Scan(&r.ID, &createdAtStr, &r.MatchName, &nice, &affinity, &ioClass, &ioLevel, &r.Enabled); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			r.CreatedAt = parsed
+		}
+		if nice.Valid {
+			value := int(nice.Int64)
+			r.Nice = &value
+		}
+		if affinity.String != "" {
+			json.Unmarshal([]byte(affinity.String), &r.Affinity)
+		}
+		r.IOClass = ioClass.String
+		if ioLevel.Valid {
+			value := int(ioLevel.Int64)
+			r.IOLevel = &value
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+/* Возвращает закэшированные правила настроек процессов и поколение кэша, к которому они относятся */
+func cachedProcessTuningRules() ([]ProcessTuningRule, uint64) {
+	tuningRulesMutex.RLock()
+	generation := tuningRulesGeneration
+	if tuningRulesLoaded {
+		rules := tuningRulesCache
+		tuningRulesMutex.RUnlock()
+		return rules, generation
+	}
+	tuningRulesMutex.RUnlock()
+
+	rules, err := GetProcessTuningRules()
+	if err != nil || rules == nil {
+		return nil, generation
+	}
+
+	// Если кэш сбросили во время чтения, правила могли устареть: их не кэшируем, следующий вызов прочитает заново
+	tuningRulesMutex.Lock()
+	if tuningRulesGeneration == generation {
+		tuningRulesCache = rules
+		tuningRulesLoaded = true
+	}
+	tuningRulesMutex.Unlock()
+	return rules, generation
+}
+
+/* Возвращает текущее поколение кэша правил настроек процессов */
+func processTuningRulesGeneration() uint64 {
+	tuningRulesMutex.RLock()
+	defer tuningRulesMutex.RUnlock()
+	return tuningRulesGeneration
+}
+
+/* Сбрасывает кэш правил после их изменения и разрешает применить их ко всем процессам заново */
+func invalidateProcessTuningRules() {
+	tuningRulesMutex.Lock()
+	tuningRulesLoaded = false
+	tuningRulesGeneration++
+	tuningRulesMutex.Unlock()
+
+	tunedProcessesMutex.Lock()
+	tunedProcesses = make(map[tunedProcessKey]bool)
+	tunedProcessesMutex.Unlock()
+}
+
+/*
+Применяет правила настроек к процессам из очередного обновления кэша
+
+	Каждое правило применяется к процессу один раз, поэтому ручные изменения после этого не перезаписываются.
+	Если под процесс подходит несколько правил, применяются все по порядку создания
+*/
+func ApplyProcessTuningRules(procs []models.ProcessInfo) {
+	rules, generation := cachedProcessTuningRules()
+	if len(rules) == 0 {
+		return
+	}
+
+	tunedProcessesMutex.Lock()
+	defer tunedProcessesMutex.Unlock()
+
+	// Правила изменились после чтения: устаревшие не применяются и не отмечают процессы, это сделает следующее обновление
+	if processTuningRulesGeneration() != generation {
+		return
+	}
+
+	seen := make(map[tunedProcessKey]bool, len(procs))
+	for i := range procs {
+		p := &procs[i]
+		key := tunedProcessKey{pid: p.PID, createTime: p.CreateTime}
+		seen[key] = true
+		if tunedProcesses[key] {
+			continue
+		}
+		tunedProcesses[key] = true
+
+		for _, rule := range rules {
+			if !rule.Enabled {
+				continue
+			}
+			if matched, _ := path.Match(rule.MatchName, p.Name); !matched {
+				continue
+			}
+			proc, err := process.NewProcess(p.PID)
+			if err != nil {
+				break
+			}
+			ruleID := rule.ID
+			applyProcessTuning(proc, p.Name, rule.ProcessTuning, "rule", &ruleID)
+		}
+	}
+
+	for key := range tunedProcesses {
+		if !seen[key] {
+			delete(tunedProcesses, key)
+		}
+	}
+}
+
+/* Получает журнал изменений настроек процессов, pid равный нулю возвращает записи по всем процессам */
+func GetProcessTuningAudit(pid int32, limit int) ([]ProcessTuningAudit, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	query := "SELECT id, created_at, pid, process_name, setting, previous_value, new_value, actor, rule_id, error FROM process_tuning_audit"
+	args := []interface{}{}
+	if pid > 0 {
+		query += " WHERE pid = ?"
+		args = append(args, pid)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []ProcessTuningAudit{}
+	for rows.Next() {
+		var e ProcessTuningAudit
+		var createdAtStr string
+		var name, previous, newValue, errText sql.NullString
+		var ruleID sql.NullInt64
+		if err := rows.Scan(&e.ID, &createdAtStr, &e.PID, &name, &e.Setting, &previous, &newValue, &e.Actor, &ruleID, &errText); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			e.CreatedAt = parsed
+		}
+		e.ProcessName = name.String
+		e.Previous = previous.String
+		e.New = newValue.String
+		e.Error = errText.String
+		if ruleID.Valid {
+			e.RuleID = &ruleID.Int64
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+/* Записывает изменение настройки процесса в журнал */
+func saveProcessTuningAudit(pid int32, name string, change ProcessTuningChange, actor string, ruleID *int64) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	var errText interface{}
+	if change.Error != "" {
+		errText = change.Error
+	}
+	_, err := db.Exec(
+		"INSERT INTO process_tuning_audit (created_at, pid, process_name, setting, previous_value, new_value, actor, rule_id, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		pid,
+		name,
+		change.Setting,
+		change.Previous,
+		change.New,
+		actor,
+		ruleID,
+		errText,
+	)
+	if err != nil {
+		log.Printf("Ошибка записи журнала настроек процесса %d: %v", pid, err)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+/* Параметры ioprio_get и ioprio_set из linux/ioprio.h */
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioLevelMask  = (1 << ioprioClassShift) - 1
+)
+
+/* Возвращает ID всех потоков процесса: в Linux nice и привязка к CPU задаются для каждого потока отдельно */
+func processThreadIDs(pid int32) []int {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return []int{int(pid)}
+	}
+	tids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids
+}
+
+/* Переводит ошибку системного вызова в понятное сообщение */
+func tuningSyscallError(err error) error {
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) {
+		return fmt.Errorf("недостаточно прав: %w", err)
+	}
+	if errors.Is(err, unix.ESRCH) {
+		return fmt.Errorf("процесс уже завершен")
+	}
+	return err
+}
+
+/* Возвращает nice процесса: getpriority в Linux возвращает 20 - nice, чтобы результат не был отрицательным */
+func getProcessNice(pid int32) (int, error) {
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, int(pid))
+	if err != nil {
+		return 0, tuningSyscallError(err)
+	}
+	return 20 - prio, nil
+}
+
+/* Задает nice всем потокам процесса */
+func setProcessNice(pid int32, nice int) error {
+	for _, tid := range processThreadIDs(pid) {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, nice); err != nil && !errors.Is(err, unix.ESRCH) {
+			return tuningSyscallError(err)
+		}
+	}
+	return nil
+}
+
+/* Возвращает номера CPU, на которых может выполняться процесс */
+func getProcessAffinity(pid int32) ([]int, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(int(pid), &set); err != nil {
+		return nil, tuningSyscallError(err)
+	}
+	cpus := []int{}
+	for i := 0; i < len(set)*64; i++ {
+		if set.IsSet(i) {
+			cpus = append(cpus, i)
+		}
+	}
+	return cpus, nil
+}
+
+/* Привязывает все потоки процесса к указанным CPU */
+func setProcessAffinity(pid int32, cpus []int) error {
+	var set unix.CPUSet
+	for _, c := range cpus {
+		set.Set(c)
+	}
+	for _, tid := range processThreadIDs(pid) {
+		if err := unix.SchedSetaffinity(tid, &set); err != nil && !errors.Is(err, unix.ESRCH) {
+			return tuningSyscallError(err)
+		}
+	}
+	return nil
+}
+
+/* Возвращает класс и уровень ввода-вывода процесса */
+func getProcessIOPriority(pid int32) (int, int, error) {
+	prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return 0, 0, tuningSyscallError(errno)
+	}
+	return int(prio >> ioprioClassShift), int(prio & ioprioLevelMask), nil
+}
+
+/* Задает класс и уровень ввода-вывода процесса */
+func setProcessIOPriority(pid int32, class, level int) error {
+	prio := class<<ioprioClassShift | level
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+		return tuningSyscallError(errno)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+/* Ошибка для настроек, которые агент умеет менять только в Linux */
+var errTuningUnsupported = fmt.Errorf("изменение настроек процессов поддерживается только в Linux")
+
+/* Возвращает nice процесса */
+func getProcessNice(pid int32) (int, error) {
+	return 0, errTuningUnsupported
+}
+
+/* Задает nice процесса */
+func setProcessNice(pid int32, nice int) error {
+	return errTuningUnsupported
+}
+
+/* Возвращает номера CPU, на которых может выполняться процесс */
+func getProcessAffinity(pid int32) ([]int, error) {
+	return nil, errTuningUnsupported
+}
+
+/* Привязывает процесс к указанным CPU */
+func setProcessAffinity(pid int32, cpus []int) error {
+	return errTuningUnsupported
+}
+
+/* Возвращает класс и уровень ввода-вывода процесса */
+func getProcessIOPriority(pid int32) (int, int, error) {
+	return 0, 0, errTuningUnsupported
+}
+
+/* Задает класс и уровень ввода-вывода процесса */
+func setProcessIOPriority(pid int32, class, level int) error {
+	return errTuningUnsupported
+}
@@ -4,11 +4,13 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/RZhurakovskiy/agent/server/models"
 	"github.com/shirou/gopsutil/v4/process"
 )
 
@@ -22,24 +24,58 @@ var (
 /* Структура для хранения информации о сессии записи */
 type RecordingSession struct {
 	ID           int64
+	Mode         string // processes - процессы по условиям, snapshot - полные снимки системы
 	CPUThreshold float64
 	RAMThreshold float64
+	Criteria     RecordingCriteria
+	IntervalSec  int
 	Duration     int
 	StartedAt    time.Time
 	EndTime      time.Time
 }
 
+/* Цикл записи, который выполняется до отмены контекста или окончания времени сессии */
+type recordingLoop func(ctx context.Context, db *sql.DB, sessionID int64, endTime time.Time)
+
 /*
-Запускает новую сессию записи процессов с указанными порогами и длительностью
+Запускает новую сессию записи процессов с указанными условиями отбора и длительностью
 
-	Возвращает ID сессии или ошибку
+	Условия сохраняются вместе с сессией, возвращает ID сессии или ошибку
 */
-func StartRecording(cpuThreshold, ramThreshold float64, durationSec int) (int64, error) {
+func StartRecording(criteria RecordingCriteria, durationSec int) (int64, error) {
+	if err := criteria.Validate(); err != nil {
+		return 0, err
+	}
+
+	session := &RecordingSession{Mode: "processes", Criteria: criteria, Duration: durationSec}
+	if criteria.CPUPercent != nil {
+		session.CPUThreshold = *criteria.CPUPercent
+	}
+	if criteria.MemoryPercent != nil {
+		session.RAMThreshold = *criteria.MemoryPercent
+	}
+
+	return beginRecording(session, func(ctx context.Context, db *sql.DB, sessionID int64, endTime time.Time) {
+		recordProcessesLoop(ctx, db, sessionID, criteria, endTime)
+	})
+}
+
+/* Сохраняет сессию в базу данных, делает ее активной и запускает цикл записи */
+func beginRecording(session *RecordingSession, loop recordingLoop) (int64, error) {
 	db := GetDB()
 	if db == nil {
 		return 0, fmt.Errorf("база данных не инициализирована")
 	}
 
+	var criteriaJSON interface{}
+	if session.Mode == "processes" {
+		data, err := json.Marshal(session.Criteria)
+		if err != nil {
+			return 0, err
+		}
+		criteriaJSON = string(data)
+	}
+
 	recordingMutex.Lock()
 	defer recordingMutex.Unlock()
 
@@ -47,53 +83,61 @@ func StartRecording(cpuThreshold, ramThreshold float64, durationSec int) (int64,
 		return 0, fmt.Errorf("запись уже активна")
 	}
 
-	startedAt := time.Now()
-	endTime := startedAt.Add(time.Duration(durationSec) * time.Second)
+	session.StartedAt = time.Now()
+	session.EndTime = session.StartedAt.Add(time.Duration(session.Duration) * time.Second)
 
 	result, err := db.Exec(
-		"INSERT INTO recording_sessions (started_at, ended_at, cpu_threshold, ram_threshold, duration_sec, status) VALUES (?, ?, ?, ?, ?, 'active')",
-		startedAt.Format("2006-01-02 15:04:05"),
-		endTime.Format("2006-01-02 15:04:05"),
-		cpuThreshold,
-		ramThreshold,
-		durationSec,
+		"INSERT INTO recording_sessions (started_at, ended_at, cpu_threshold, ram_threshold, duration_sec, status, criteria, mode, interval_sec) VALUES (?, ?, ?, ?, ?, 'active', ?, ?, ?)",
+		session.StartedAt.Format("2006-01-02 15:04:05"),
+		session.EndTime.Format("2006-01-02 15:04:05"),
+		session.CPUThreshold,
+		session.RAMThreshold,
+		session.Duration,
+		criteriaJSON,
+		session.Mode,
+		session.IntervalSec,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	sessionID, err := result.LastInsertId()
+	session.ID, err = result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	recordingSession = &RecordingSession{
-		ID:           sessionID,
-		CPUThreshold: cpuThreshold,
-		RAMThreshold: ramThreshold,
-		Duration:     durationSec,
-		StartedAt:    startedAt,
-		EndTime:      endTime,
-	}
-
+	recordingSession = session
 	recordingActive = true
 
 	ctx, cancel := context.WithCancel(context.Background())
 	recordingCancel = cancel
 
-	go recordProcessesLoop(ctx, db, sessionID, cpuThreshold, ramThreshold, endTime)
+	go loop(ctx, db, session.ID, session.EndTime)
 
-	return sessionID, nil
+	return session.ID, nil
 }
 
 /* Останавливает активную сессию записи и обновляет статус в базе данных */
 func StopRecording() error {
+	return stopRecordingSession(0)
+}
+
+/*
+Останавливает сессию записи sessionID, если она все еще активна, 0 - любую активную сессию
+
+	Цикл записи останавливает только свою сессию: после замены сессии расписанием старый цикл
+	может успеть проверить время окончания раньше, чем заметит отмену, и не должен остановить новую
+*/
+func stopRecordingSession(sessionID int64) error {
 	recordingMutex.Lock()
 	defer recordingMutex.Unlock()
 
 	if !recordingActive {
 		return fmt.Errorf("запись не активна")
 	}
+	if sessionID != 0 && (recordingSession == nil || recordingSession.ID != sessionID) {
+		return fmt.Errorf("сессия записи %d уже не активна", sessionID)
+	}
 
 	if recordingCancel != nil {
 		recordingCancel()
@@ -123,11 +167,11 @@ func GetRecordingStatus() (bool, *RecordingSession) {
 }
 
 /*
-Основной цикл записи процессов которые превышают пороги cpu и ram
+Основной цикл записи процессов которые подходят под условия сессии
 
 	Записывает процессы в базу данных каждые 2 секунды до окончания времени сессии
 */
-func recordProcessesLoop(ctx context.Context, db *sql.DB, sessionID int64, cpuThreshold, ramThreshold float64, endTime time.Time) {
+func recordProcessesLoop(ctx context.Context, db *sql.DB, sessionID int64, criteria RecordingCriteria, endTime time.Time) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -136,8 +180,11 @@ func recordProcessesLoop(ctx context.Context, db *sql.DB, sessionID int64, cpuTh
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if ctx.Err() != nil {
+				return
+			}
 			if time.Now().After(endTime) {
-				StopRecording()
+				stopRecordingSession(sessionID)
 				return
 			}
 
@@ -148,35 +195,56 @@ func recordProcessesLoop(ctx context.Context, db *sql.DB, sessionID int64, cpuTh
 			}
 
 			for _, p := range procs {
-				cpuPercent, err := p.CPUPercent()
-				if err != nil {
-					continue
+				info, ok := recordingSample(p, criteria)
+				if ok && criteria.Matches(&info) {
+					saveRecordedProcess(db, sessionID, p, &info)
 				}
+			}
+		}
+	}
+}
 
-				memInfo, err := p.MemoryInfo()
-				if err != nil {
-					continue
-				}
+/* Снимает метрики процесса для проверки условий, имя и пользователь читаются только если по ним есть фильтры */
+func recordingSample(p *process.Process, criteria RecordingCriteria) (models.ProcessInfo, bool) {
+	info := models.ProcessInfo{PID: p.Pid}
 
-				memPercent, err := p.MemoryPercent()
-				if err != nil {
-					continue
-				}
+	cpuPercent, err := p.CPUPercent()
+	if err != nil {
+		return info, false
+	}
 
-				if cpuPercent > cpuThreshold && float64(memPercent) > ramThreshold {
-					saveRecordedProcess(db, sessionID, p, cpuPercent, float64(memPercent), memInfo.RSS)
-				}
-			}
-		}
+	memInfo, err := p.MemoryInfo()
+	if err != nil {
+		return info, false
+	}
+
+	memPercent, err := p.MemoryPercent()
+	if err != nil {
+		return info, false
 	}
+
+	info.CPUPercent = cpuPercent
+	info.MemoryPercent = float64(memPercent)
+	info.MemoryRSS = memInfo.RSS
+
+	if criteria.hasFilters() {
+		info.Name, _ = p.Name()
+		info.Username, _ = p.Username()
+	}
+	return info, true
 }
 
-/* Сохраняет информацию о процессе в базу данных если он превышает пороги */
-func saveRecordedProcess(db *sql.DB, sessionID int64, p *process.Process, cpuPercent, memPercent float64, memRSS uint64) {
-	name, _ := p.Name()
+/* Сохраняет информацию о процессе, подошедшем под условия записи, в базу данных */
+func saveRecordedProcess(db *sql.DB, sessionID int64, p *process.Process, info *models.ProcessInfo) {
+	name, username := info.Name, info.Username
+	if name == "" {
+		name, _ = p.Name()
+	}
+	if username == "" {
+		username, _ = p.Username()
+	}
 	exe, _ := p.Exe()
 	cmdline, _ := p.Cmdline()
-	username, _ := p.Username()
 
 	_, err := db.Exec(
 		"INSERT INTO recorded_processes (session_id, recorded_at, pid, name, cpu_percent, memory_percent, memory_rss, exe, cmdline, username) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
@@ -184,9 +252,9 @@ func saveRecordedProcess(db *sql.DB, sessionID int64, p *process.Process, cpuPer
 		time.Now().Format("2006-01-02 15:04:05"),
 		p.Pid,
 		name,
-		cpuPercent,
-		memPercent,
-		memRSS,
+		info.CPUPercent,
+		info.MemoryPercent,
+		info.MemoryRSS,
 		exe,
 		cmdline,
 		username,
@@ -0,0 +1,237 @@
+/* Сервисы для сравнения двух сессий записи или двух интервалов времени */
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+/* Окно сравнения - сессия записи или интервал времени */
+type ComparisonWindow struct {
+	SessionID int64     `json:"sessionId,omitempty"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+/* Показатели процесса с одним именем в окне сравнения */
+type ProcessWindowStats struct {
+	Occurrences int64   `json:"occurrences"` // Сколько раз процесс попал в запись
+	UniquePIDs  int64   `json:"uniquePids"`
+	AvgCPU      float64 `json:"avgCpu"`
+	MaxCPU      float64 `json:"maxCpu"`
+	AvgRSS      float64 `json:"avgRss"`
+	MaxRSS      int64   `json:"maxRss"`
+}
+
+/* Сравнение процесса по имени, before или after равны nil, если процесса не было в окне */
+type ProcessComparison struct {
+	Name             string              `json:"name"`
+	Before           *ProcessWindowStats `json:"before"`
+	After            *ProcessWindowStats `json:"after"`
+	DeltaOccurrences int64               `json:"deltaOccurrences"`
+	DeltaAvgCPU      float64             `json:"deltaAvgCpu"`
+	DeltaMaxCPU      float64             `json:"deltaMaxCpu"`
+	DeltaAvgRSS      float64             `json:"deltaAvgRss"`
+	DeltaMaxRSS      int64               `json:"deltaMaxRss"`
+}
+
+/* Показатели хоста из истории метрик в окне сравнения */
+type HostWindowStats struct {
+	Samples   int64   `json:"samples"`
+	AvgCPU    float64 `json:"avgCpu"`
+	MaxCPU    float64 `json:"maxCpu"`
+	AvgMemory float64 `json:"avgMemory"`
+	MaxMemory float64 `json:"maxMemory"`
+	AvgLoad1  float64 `json:"avgLoad1"`
+	AvgNetRx  float64 `json:"avgNetRxBps"`
+	AvgNetTx  float64 `json:"avgNetTxBps"`
+}
+
+/* Сравнение показателей хоста, delta - разница after минус before */
+type HostComparison struct {
+	Before HostWindowStats `json:"before"`
+	After  HostWindowStats `json:"after"`
+	Delta  HostWindowStats `json:"delta"`
+}
+
+/* Результат сравнения двух окон */
+type RecordingComparison struct {
+	Before    ComparisonWindow    `json:"before"`
+	After     ComparisonWindow    `json:"after"`
+	Processes []ProcessComparison `json:"processes"`
+	Host      HostComparison      `json:"host"`
+}
+
+/*
+Сравнивает два окна: по процессам с одинаковым именем и по метрикам хоста
+
+	Окно с sessionId берет записанные процессы этой сессии, а метрики хоста за время сессии.
+	Окно с интервалом берет записанные процессы всех сессий и метрики хоста за интервал.
+	Процессы отсортированы по модулю изменения среднего CPU
+*/
+func CompareRecordings(before, after ComparisonWindow) (*RecordingComparison, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	if err := resolveComparisonWindow(&before); err != nil {
+		return nil, err
+	}
+	if err := resolveComparisonWindow(&after); err != nil {
+		return nil, err
+	}
+
+	beforeProcs, err := processWindowStats(db, before)
+	if err != nil {
+		return nil, err
+	}
+	afterProcs, err := processWindowStats(db, after)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &RecordingComparison{Before: before, After: after, Processes: []ProcessComparison{}}
+	for name, b := range beforeProcs {
+		comparison.Processes = append(comparison.Processes, compareProcess(name, b, afterProcs[name]))
+	}
+	for name, a := range afterProcs {
+		if _, ok := beforeProcs[name]; !ok {
+			comparison.Processes = append(comparison.Processes, compareProcess(name, nil, a))
+		}
+	}
+	sort.Slice(comparison.Processes, func(i, j int) bool {
+		di := math.Abs(comparison.Processes[i].DeltaAvgCPU)
+		dj := math.Abs(comparison.Processes[j].DeltaAvgCPU)
+		if di != dj {
+			return di > dj
+		}
+		return comparison.Processes[i].Name < comparison.Processes[j].Name
+	})
+
+	if comparison.Host.Before, err = hostWindowStats(db, before); err != nil {
+		return nil, err
+	}
+	if comparison.Host.After, err = hostWindowStats(db, after); err != nil {
+		return nil, err
+	}
+	b, a := comparison.Host.Before, comparison.Host.After
+	comparison.Host.Delta = HostWindowStats{
+		Samples:   a.Samples - b.Samples,
+		AvgCPU:    a.AvgCPU - b.AvgCPU,
+		MaxCPU:    a.MaxCPU - b.MaxCPU,
+		AvgMemory: a.AvgMemory - b.AvgMemory,
+		MaxMemory: a.MaxMemory - b.MaxMemory,
+		AvgLoad1:  a.AvgLoad1 - b.AvgLoad1,
+		AvgNetRx:  a.AvgNetRx - b.AvgNetRx,
+		AvgNetTx:  a.AvgNetTx - b.AvgNetTx,
+	}
+	return comparison, nil
+}
+
+/* Для окна с сессией подставляет время начала и окончания сессии, для интервала проверяет его границы */
+func resolveComparisonWindow(window *ComparisonWindow) error {
+	if window.SessionID == 0 {
+		if window.From.IsZero() || window.To.IsZero() || !window.From.Before(window.To) {
+			return fmt.Errorf("начало интервала должно быть раньше его окончания")
+		}
+		return nil
+	}
+
+	session, err := GetRecordingSession(window.SessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return fmt.Errorf("сессия записи с ID %d не найдена", window.SessionID)
+	}
+	window.From = session.StartedAt
+	window.To = time.Now()
+	if session.EndedAt != nil {
+		window.To = *session.EndedAt
+	}
+	return nil
+}
+
+/* Собирает показатели записанных процессов окна, сгруппированные по имени */
+func processWindowStats(db *sql.DB, window ComparisonWindow) (map[string]*ProcessWindowStats, error) {
+	query := "SELECT name, COUNT(*), COUNT(DISTINCT pid), AVG(cpu_percent), MAX(cpu_percent), AVG(memory_rss), MAX(memory_rss) FROM recorded_processes"
+	var args []interface{}
+	if window.SessionID != 0 {
+		query += " WHERE session_id = ?"
+		args = append(args, window.SessionID)
+	} else {
+		query += " WHERE recorded_at >= ? AND recorded_at <= ?"
+		args = append(args, window.From.Format("2006-01-02 15:04:05"), window.To.Format("2006-01-02 15:04:05"))
+	}
+	query += " GROUP BY name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]*ProcessWindowStats)
+	for rows.Next() {
+		var name string
+		var s ProcessWindowStats
+		var avgCPU, maxCPU, avgRSS sql.NullFloat64
+		var maxRSS sql.NullInt64
+		if err := rows.Scan(&name, &s.Occurrences, &s.UniquePIDs, &avgCPU, &maxCPU, &avgRSS, &maxRSS); err != nil {
+			return nil, err
+		}
+		s.AvgCPU = avgCPU.Float64
+		s.MaxCPU = maxCPU.Float64
+		s.AvgRSS = avgRSS.Float64
+		s.MaxRSS = maxRSS.Int64
+		stats[name] = &s
+	}
+	return stats, rows.Err()
+}
+
+/* Собирает показатели хоста из истории метрик за время окна */
+func hostWindowStats(db *sql.DB, window ComparisonWindow) (HostWindowStats, error) {
+	var s HostWindowStats
+	var avgCPU, maxCPU, avgMem, maxMem, avgLoad, avgRx, avgTx sql.NullFloat64
+	err := db.QueryRow(
+		"SELECT COUNT(*), AVG(cpu_percent), MAX(cpu_percent), AVG(memory_percent), MAX(memory_percent), AVG(load1), AVG(net_rx_bps), AVG(net_tx_bps) FROM metrics_history WHERE timestamp >= ? AND timestamp <= ?",
+		window.From.Format("2006-01-02 15:04:05"),
+		window.To.Format("2006-01-02 15:04:05"),
+	).Scan(&s.Samples, &avgCPU, &maxCPU, &avgMem, &maxMem, &avgLoad, &avgRx, &avgTx)
+	if err != nil {
+		return s, err
+	}
+	s.AvgCPU = avgCPU.Float64
+	s.MaxCPU = maxCPU.Float64
+	s.AvgMemory = avgMem.Float64
+	s.MaxMemory = maxMem.Float64
+	s.AvgLoad1 = avgLoad.Float64
+	s.AvgNetRx = avgRx.Float64
+	s.AvgNetTx = avgTx.Float64
+	return s, nil
+}
+
+/* Считает изменения показателей процесса, отсутствующий в окне процесс считается с нулевыми показателями */
+func compareProcess(name string, before, after *ProcessWindowStats) ProcessComparison {
+	var b, a ProcessWindowStats
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+	return ProcessComparison{
+		Name:             name,
+		Before:           before,
+		After:            after,
+		DeltaOccurrences: a.Occurrences - b.Occurrences,
+		DeltaAvgCPU:      a.AvgCPU - b.AvgCPU,
+		DeltaMaxCPU:      a.MaxCPU - b.MaxCPU,
+		DeltaAvgRSS:      a.AvgRSS - b.AvgRSS,
+		DeltaMaxRSS:      a.MaxRSS - b.MaxRSS,
+	}
+}
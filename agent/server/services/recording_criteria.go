@@ -0,0 +1,177 @@
+/* Условия отбора процессов для сессии записи */
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/*
+Условия, по которым процесс попадает в запись
+
+	Пороги cpuPercent, memoryPercent и rssMB объединяются по mode: any - достаточно одного, all - нужны все.
+	Фильтры names (шаблоны имени с * и ?) и users ограничивают отбор всегда: процесс должен подойти хотя бы под один шаблон
+	и принадлежать одному из пользователей. Если порогов нет, записываются все процессы, подходящие под фильтры
+*/
+type RecordingCriteria struct {
+	Mode          string   `json:"mode"`
+	CPUPercent    *float64 `json:"cpuPercent,omitempty"`
+	MemoryPercent *float64 `json:"memoryPercent,omitempty"`
+	RSSMB         *uint64  `json:"rssMB,omitempty"`
+	Names         []string `json:"names,omitempty"`
+	Users         []string `json:"users,omitempty"`
+}
+
+/* Проверяет корректность условий записи и приводит режим к значению по умолчанию */
+func (c *RecordingCriteria) Validate() error {
+	if c.Mode == "" {
+		c.Mode = "any"
+	}
+	if c.Mode != "any" && c.Mode != "all" {
+		return fmt.Errorf("режим условий должен быть any или all")
+	}
+	if c.CPUPercent != nil && *c.CPUPercent < 0 {
+		return fmt.Errorf("порог CPU не может быть отрицательным")
+	}
+	if c.MemoryPercent != nil && (*c.MemoryPercent < 0 || *c.MemoryPercent > 100) {
+		return fmt.Errorf("порог памяти должен быть от 0 до 100%%")
+	}
+	for _, pattern := range c.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("некорректный шаблон имени: %s", pattern)
+		}
+	}
+	if c.CPUPercent == nil && c.MemoryPercent == nil && c.RSSMB == nil && len(c.Names) == 0 && len(c.Users) == 0 {
+		return fmt.Errorf("не задано ни одного условия записи")
+	}
+	return nil
+}
+
+/* Проверяет, подходит ли процесс под условия записи */
+func (c RecordingCriteria) Matches(p *models.ProcessInfo) bool {
+	if !c.matchesFilters(p.Name, p.Username) {
+		return false
+	}
+
+	var results []bool
+	if c.CPUPercent != nil {
+		results = append(results, p.CPUPercent > *c.CPUPercent)
+	}
+	if c.MemoryPercent != nil {
+		results = append(results, p.MemoryPercent > *c.MemoryPercent)
+	}
+	if c.RSSMB != nil {
+		results = append(results, p.MemoryRSS > *c.RSSMB*1024*1024)
+	}
+	return c.combine(results)
+}
+
+/* Проверяет пороги CPU и памяти для загрузки хоста в целом, по ним решается, сохранять ли историю метрик во время записи */
+func (c RecordingCriteria) MatchesHost(cpuPercent, memoryPercent float64) bool {
+	var results []bool
+	if c.CPUPercent != nil {
+		results = append(results, cpuPercent > *c.CPUPercent)
+	}
+	if c.MemoryPercent != nil {
+		results = append(results, memoryPercent > *c.MemoryPercent)
+	}
+	return c.combine(results)
+}
+
+/* Нужны ли для проверки имя и пользователь процесса */
+func (c RecordingCriteria) hasFilters() bool {
+	return len(c.Names) > 0 || len(c.Users) > 0
+}
+
+/* Проверяет фильтры по шаблонам имени и пользователям */
+func (c RecordingCriteria) matchesFilters(name, username string) bool {
+	if len(c.Names) > 0 {
+		matched := false
+		for _, pattern := range c.Names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(c.Users) > 0 {
+		matched := false
+		for _, user := range c.Users {
+			if user == username {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+/* Объединяет результаты проверки порогов по режиму, без порогов условие считается выполненным */
+func (c RecordingCriteria) combine(results []bool) bool {
+	if len(results) == 0 {
+		return true
+	}
+	for _, ok := range results {
+		if ok && c.Mode == "any" {
+			return true
+		}
+		if !ok && c.Mode == "all" {
+			return false
+		}
+	}
+	return c.Mode == "all"
+}
+
+/* Возвращает текстовое описание условий записи для вывода пользователю */
+func (c RecordingCriteria) String() string {
+	var conditions []string
+	if c.CPUPercent != nil {
+		conditions = append(conditions, fmt.Sprintf("CPU > %.2f%%", *c.CPUPercent))
+	}
+	if c.MemoryPercent != nil {
+		conditions = append(conditions, fmt.Sprintf("RAM > %.2f%%", *c.MemoryPercent))
+	}
+	if c.RSSMB != nil {
+		conditions = append(conditions, fmt.Sprintf("RSS > %d МБ", *c.RSSMB))
+	}
+
+	joiner := " ИЛИ "
+	if c.Mode == "all" {
+		joiner = " И "
+	}
+	text := strings.Join(conditions, joiner)
+	if text == "" {
+		text = "все процессы"
+	}
+	if len(c.Names) > 0 {
+		text += ", имя: " + strings.Join(c.Names, ", ")
+	}
+	if len(c.Users) > 0 {
+		text += ", пользователь: " + strings.Join(c.Users, ", ")
+	}
+	return text
+}
+
+/* Условия для сессий, созданных до появления гибких условий: оба порога CPU и RAM одновременно */
+func legacyRecordingCriteria(cpuThreshold, ramThreshold float64) RecordingCriteria {
+	return RecordingCriteria{Mode: "all", CPUPercent: &cpuThreshold, MemoryPercent: &ramThreshold}
+}
+
+/* Читает условия записи из JSON колонки сессии, для старых сессий восстанавливает их по порогам */
+func parseRecordingCriteria(value string, cpuThreshold, ramThreshold float64) RecordingCriteria {
+	var criteria RecordingCriteria
+	if value == "" || json.Unmarshal([]byte(value), &criteria) != nil {
+		return legacyRecordingCriteria(cpuThreshold, ramThreshold)
+	}
+	return criteria
+}
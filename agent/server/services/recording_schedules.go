@@ -0,0 +1,370 @@
+/* Сервисы для запуска записи по расписанию */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+Расписание записи
+
+	Запись запускается по выражению cron в поле schedule или один раз в момент startAt.
+	concurrency определяет, что делать, если в момент запуска уже идет другая запись:
+	skip - пропустить запуск, queue - дождаться окончания текущей записи, replace - остановить текущую запись
+*/
+type RecordingSchedule struct {
+	ID            int64              `json:"id"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	Name          string             `json:"name"`
+	Schedule      string             `json:"schedule,omitempty"`
+	StartAt       *time.Time         `json:"startAt,omitempty"`
+	Mode          string             `json:"mode"` // processes или snapshot, как у сессий записи
+	Criteria      *RecordingCriteria `json:"criteria,omitempty"`
+	IntervalSec   int                `json:"intervalSec,omitempty"`
+	DurationSec   int                `json:"durationSec"`
+	Concurrency   string             `json:"concurrency"`
+	Enabled       bool               `json:"enabled"` // Разовое расписание выключается после запуска
+	NextRunAt     *time.Time         `json:"nextRunAt"`
+	LastRunAt     *time.Time         `json:"lastRunAt"`
+	LastSessionID *int64             `json:"lastSessionId"`
+	LastResult    string             `json:"lastResult"` // started, skipped, queued, missed или текст ошибки запуска
+}
+
+/* Как часто планировщик проверяет расписания */
+const recordingSchedulerInterval = 5 * time.Second
+
+var (
+	recordingConcurrencyPolicies = map[string]bool{"skip": true, "queue": true, "replace": true}
+	recordingSchedulerOnce       sync.Once
+	recordingSchedulerMutex      sync.Mutex
+)
+
+/* Проверяет расписание записи и заполняет значения по умолчанию */
+func ValidateRecordingSchedule(schedule *RecordingSchedule) error {
+	if (schedule.Schedule == "") == (schedule.StartAt == nil) {
+		return fmt.Errorf("укажите либо расписание schedule, либо время разового запуска startAt")
+	}
+	if schedule.Schedule != "" {
+		if _, err := ParseCronSchedule(schedule.Schedule); err != nil {
+			return fmt.Errorf("некорректное расписание: %w", err)
+		}
+	}
+	if schedule.StartAt != nil && !schedule.StartAt.After(time.Now()) {
+		return fmt.Errorf("время разового запуска должно быть в будущем")
+	}
+	if schedule.DurationSec <= 0 {
+		return fmt.Errorf("durationSec должен быть больше 0")
+	}
+
+	if schedule.Concurrency == "" {
+		schedule.Concurrency = "skip"
+	}
+	if !recordingConcurrencyPolicies[schedule.Concurrency] {
+		return fmt.Errorf("concurrency должен быть skip, queue или replace")
+	}
+
+	switch schedule.Mode {
+	case "", "processes":
+		schedule.Mode = "processes"
+		schedule.IntervalSec = 0
+		if schedule.Criteria == nil {
+			return fmt.Errorf("для записи процессов нужны условия criteria")
+		}
+		if err := schedule.Criteria.Validate(); err != nil {
+			return fmt.Errorf("некорректные условия записи: %w", err)
+		}
+	case "snapshot":
+		schedule.Criteria = nil
+		if schedule.IntervalSec == 0 {
+			schedule.IntervalSec = 10
+		}
+		if err := validateSnapshotInterval(schedule.IntervalSec, schedule.DurationSec); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("режим записи должен быть processes или snapshot")
+	}
+	return nil
+}
+
+/* Возвращает время первого запуска расписания после указанного момента */
+func (s *RecordingSchedule) nextRun(after time.Time) *time.Time {
+	if s.StartAt != nil {
+		if s.StartAt.After(after) {
+			next := *s.StartAt
+			return &next
+		}
+		return nil
+	}
+
+	cron, err := ParseCronSchedule(s.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := cron.Next(after)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+/* Создаёт расписание записи и рассчитывает время первого запуска, возвращает ID */
+func CreateRecordingSchedule(schedule RecordingSchedule) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if err := ValidateRecordingSchedule(&schedule); err != nil {
+		return 0, err
+	}
+
+	var criteriaJSON, startAt interface{}
+	if schedule.Criteria != nil {
+		data, err := json.Marshal(schedule.Criteria)
+		if err != nil {
+			return 0, err
+		}
+		criteriaJSON = string(data)
+	}
+	if schedule.StartAt != nil {
+		startAt = schedule.StartAt.Local().Format("2006-01-02 15:04:05")
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO recording_schedules (created_at, name, schedule, start_at, mode, criteria, interval_sec, duration_sec, concurrency, enabled, next_run_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		schedule.Name,
+		schedule.Schedule,
+		startAt,
+		schedule.Mode,
+		criteriaJSON,
+		schedule.IntervalSec,
+		schedule.DurationSec,
+		schedule.Concurrency,
+		formatScheduleTime(schedule.nextRun(time.Now())),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+/* Удаляет расписание записи, уже запущенная по нему запись продолжается */
+func DeleteRecordingSchedule(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	recordingSchedulerMutex.Lock()
+	defer recordingSchedulerMutex.Unlock()
+
+	result, err := db.Exec("DELETE FROM recording_schedules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("расписание записи с ID %d не найдено", id)
+	}
+	return nil
+}
+
+/* Получает список расписаний записи в порядке создания */
+func GetRecordingSchedules() ([]RecordingSchedule, error) {
+	return queryRecordingSchedules("")
+}
+
+/* Получает расписание записи по ID, возвращает nil если расписание не найдено */
+func GetRecordingSchedule(id int64) (*RecordingSchedule, error) {
+	schedules, err := queryRecordingSchedules(" WHERE id = ?", id)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return &schedules[0], nil
+}
+
+/* Читает расписания записи с дополнительным условием запроса */
+func queryRecordingSchedules(where string, args ...interface{}) ([]RecordingSchedule, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	rows, err := db.Query(
+		"SELECT id, created_at, name, schedule, start_at, mode, criteria, interval_sec, duration_sec, concurrency, enabled, next_run_at, last_run_at, last_session_id, last_result FROM recording_schedules"+where+" ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []RecordingSchedule{}
+	for rows.Next() {
+		var s RecordingSchedule
+		var createdAt string
+		var name, schedule, startAt, criteria, nextRunAt, lastRunAt, lastResult sql.NullString
+		var intervalSec, lastSessionID sql.NullInt64
+		var enabled int
+
+		if err := rows.Scan(&s.ID, &createdAt, &name, &schedule, &startAt, &s.Mode, &criteria, &intervalSec, &s.DurationSec, &s.Concurrency, &enabled, &nextRunAt, &lastRunAt, &lastSessionID, &lastResult); err != nil {
+			return nil, err
+		}
+
+		if parsed, ok := parseDBTime(createdAt); ok {
+			s.CreatedAt = parsed
+		}
+		if parsed, ok := parseDBTime(startAt.String); ok {
+			s.StartAt = &parsed
+		}
+		if parsed, ok := parseDBTime(nextRunAt.String); ok {
+			s.NextRunAt = &parsed
+		}
+		if parsed, ok := parseDBTime(lastRunAt.String); ok {
+			s.LastRunAt = &parsed
+		}
+		if criteria.Valid {
+			var c RecordingCriteria
+			if json.Unmarshal([]byte(criteria.String), &c) == nil {
+				s.Criteria = &c
+			}
+		}
+		if lastSessionID.Valid {
+			s.LastSessionID = &lastSessionID.Int64
+		}
+		s.Name = name.String
+		s.Schedule = schedule.String
+		s.IntervalSec = int(intervalSec.Int64)
+		s.Enabled = enabled == 1
+		s.LastResult = lastResult.String
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+/*
+Запускает планировщик записи, повторные вызовы ничего не делают
+
+	Время следующего запуска хранится в базе данных, поэтому расписания продолжают работать после перезапуска агента.
+	Запуск, пропущенный дольше чем на длительность записи (например, пока агент был выключен), считается пропущенным
+*/
+func StartRecordingScheduler() {
+	recordingSchedulerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(recordingSchedulerInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				runDueRecordingSchedules(time.Now())
+			}
+		}()
+	})
+}
+
+/* Запускает записи по всем расписаниям, время запуска которых наступило */
+func runDueRecordingSchedules(now time.Time) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	recordingSchedulerMutex.Lock()
+	defer recordingSchedulerMutex.Unlock()
+
+	schedules, err := queryRecordingSchedules(" WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ?", now.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		log.Printf("Ошибка получения расписаний записи: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		runRecordingSchedule(db, &schedules[i], now)
+	}
+}
+
+/*
+Выполняет один запуск расписания с учетом правила concurrency и рассчитывает следующий запуск
+
+	Запуск, поставленный в очередь, не считается пропущенным, сколько бы ни длилась запись, которую он ждет
+*/
+func runRecordingSchedule(db *sql.DB, schedule *RecordingSchedule, now time.Time) {
+	queued := schedule.Concurrency == "queue" && schedule.LastResult == "queued"
+	if !queued && now.Sub(*schedule.NextRunAt) > time.Duration(schedule.DurationSec)*time.Second {
+		finishRecordingScheduleRun(db, schedule, now, nil, "missed")
+		return
+	}
+
+	if active, _ := GetRecordingStatus(); active {
+		switch schedule.Concurrency {
+		case "skip":
+			finishRecordingScheduleRun(db, schedule, now, nil, "skipped")
+			return
+		case "queue":
+			if schedule.LastResult != "queued" {
+				db.Exec("UPDATE recording_schedules SET last_result = 'queued' WHERE id = ?", schedule.ID)
+			}
+			return
+		case "replace":
+			if err := StopRecording(); err != nil {
+				log.Printf("Ошибка остановки записи для расписания %d: %v", schedule.ID, err)
+			}
+		}
+	}
+
+	var sessionID int64
+	var err error
+	if schedule.Mode == "snapshot" {
+		sessionID, err = StartSnapshotRecording(schedule.IntervalSec, schedule.DurationSec)
+	} else if schedule.Criteria != nil {
+		sessionID, err = StartRecording(*schedule.Criteria, schedule.DurationSec)
+	} else {
+		err = fmt.Errorf("у расписания нет условий записи")
+	}
+	if err != nil {
+		log.Printf("Ошибка запуска записи по расписанию %d: %v", schedule.ID, err)
+		finishRecordingScheduleRun(db, schedule, now, nil, err.Error())
+		return
+	}
+
+	db.Exec("UPDATE recording_sessions SET schedule_id = ? WHERE id = ?", schedule.ID, sessionID)
+	log.Printf("Запись %d запущена по расписанию %d", sessionID, schedule.ID)
+	finishRecordingScheduleRun(db, schedule, now, &sessionID, "started")
+}
+
+/* Сохраняет результат запуска и время следующего запуска, разовое расписание после этого выключается */
+func finishRecordingScheduleRun(db *sql.DB, schedule *RecordingSchedule, now time.Time, sessionID *int64, result string) {
+	next := schedule.nextRun(now)
+	enabled := next != nil
+
+	var lastRunAt, lastSession interface{}
+	if sessionID != nil {
+		lastRunAt = now.Format("2006-01-02 15:04:05")
+		lastSession = *sessionID
+	}
+
+	_, err := db.Exec(
+		"UPDATE recording_schedules SET next_run_at = ?, enabled = ?, last_result = ?, last_run_at = COALESCE(?, last_run_at), last_session_id = COALESCE(?, last_session_id) WHERE id = ?",
+		formatScheduleTime(next),
+		enabled,
+		result,
+		lastRunAt,
+		lastSession,
+		schedule.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка обновления расписания записи %d: %v", schedule.ID, err)
+	}
+}
+
+/* Форматирует время для колонки расписания, nil сохраняется как NULL */
+func formatScheduleTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+/* Отмечает запись как идущую, не запуская цикл записи, на время теста или до вызова возвращенной функции */
+func withActiveRecording(t *testing.T) func() {
+	t.Helper()
+	recordingMutex.Lock()
+	recordingActive, recordingSession = true, &RecordingSession{ID: -1}
+	recordingMutex.Unlock()
+
+	release := func() {
+		recordingMutex.Lock()
+		if recordingSession != nil && recordingSession.ID == -1 {
+			recordingActive, recordingSession = false, nil
+		}
+		recordingMutex.Unlock()
+	}
+	t.Cleanup(release)
+	return release
+}
+
+func TestQueuedRecordingScheduleOutlivesDuration(t *testing.T) {
+	db := setupTestDB(t)
+
+	startAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	id, err := CreateRecordingSchedule(RecordingSchedule{
+		StartAt:     &startAt,
+		Criteria:    &RecordingCriteria{Names: []string{"nexora-test-process"}},
+		DurationSec: 300,
+		Concurrency: "queue",
+	})
+	if err != nil {
+		t.Fatalf("CreateRecordingSchedule: %v", err)
+	}
+	release := withActiveRecording(t)
+
+	run := func(at time.Time) string {
+		t.Helper()
+		schedule, err := GetRecordingSchedule(id)
+		if err != nil || schedule == nil {
+			t.Fatalf("GetRecordingSchedule: %v", err)
+		}
+		runRecordingSchedule(db, schedule, at)
+		if schedule, err = GetRecordingSchedule(id); err != nil {
+			t.Fatalf("GetRecordingSchedule: %v", err)
+		}
+		return schedule.LastResult
+	}
+
+	if got := run(startAt.Add(time.Second)); got != "queued" {
+		t.Fatalf("во время другой записи результат %q, ожидался queued", got)
+	}
+	// Мешающая запись длится час, это намного дольше 5 минут записи по расписанию
+	if got := run(startAt.Add(time.Hour)); got != "queued" {
+		t.Fatalf("через час результат %q, ожидался queued", got)
+	}
+
+	release()
+	t.Cleanup(func() { StopRecording() })
+	if got := run(startAt.Add(time.Hour + 5*time.Second)); got != "started" {
+		t.Fatalf("после окончания другой записи результат %q, ожидался started", got)
+	}
+}
+
+func TestRecordingScheduleMissed(t *testing.T) {
+	db := setupTestDB(t)
+
+	startAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	id, err := CreateRecordingSchedule(RecordingSchedule{
+		StartAt:     &startAt,
+		Criteria:    &RecordingCriteria{Names: []string{"nexora-test-process"}},
+		DurationSec: 300,
+		Concurrency: "queue",
+	})
+	if err != nil {
+		t.Fatalf("CreateRecordingSchedule: %v", err)
+	}
+
+	schedule, err := GetRecordingSchedule(id)
+	if err != nil || schedule == nil {
+		t.Fatalf("GetRecordingSchedule: %v", err)
+	}
+	// Запуск, который не стоял в очереди и опоздал дольше длительности записи, пропускается
+	runRecordingSchedule(db, schedule, startAt.Add(time.Hour))
+	if schedule, _ = GetRecordingSchedule(id); schedule.LastResult != "missed" {
+		t.Fatalf("результат %q, ожидался missed", schedule.LastResult)
+	}
+}
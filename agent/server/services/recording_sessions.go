@@ -0,0 +1,270 @@
+/* Сервисы для просмотра и удаления сохраненных сессий записи процессов */
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/* Сведения о сессии записи из базы данных со счетчиками записанных процессов */
+type RecordingSessionInfo struct {
+	ID              int64              `json:"id"`
+	StartedAt       time.Time          `json:"startedAt"`
+	EndedAt         *time.Time         `json:"endedAt"`
+	Mode            string             `json:"mode"` // processes - процессы по условиям, snapshot - полные снимки системы
+	CPUThreshold    float64            `json:"cpuThreshold"`
+	RAMThreshold    float64            `json:"ramThreshold"`
+	Criteria        *RecordingCriteria `json:"criteria,omitempty"`
+	IntervalSec     int                `json:"intervalSec,omitempty"`
+	AlertID         *int64             `json:"alertId,omitempty"`    // Алерт, по которому сохранен бортовой самописец
+	ScheduleID      *int64             `json:"scheduleId,omitempty"` // Расписание, по которому запущена запись
+	DurationSec     int                `json:"durationSec"`
+	Status          string             `json:"status"` // active, stopped или interrupted, если агент завершился во время записи
+	RecordCount     int64              `json:"recordCount"`
+	UniqueProcesses int64              `json:"uniqueProcesses"`
+	SnapshotCount   int64              `json:"snapshotCount"`
+}
+
+/* Сводная статистика по процессу внутри сессии записи */
+type RecordingProcessSummary struct {
+	Name         string  `json:"name"`
+	Samples      int64   `json:"samples"`
+	AvgCPU       float64 `json:"avgCpu"`
+	MaxCPU       float64 `json:"maxCpu"`
+	AvgMemory    float64 `json:"avgMemory"`
+	MaxMemoryRSS int64   `json:"maxMemoryRss"`
+}
+
+/* Сводная статистика по всей сессии записи */
+type RecordingSessionStats struct {
+	FirstRecordedAt *time.Time                `json:"firstRecordedAt"`
+	LastRecordedAt  *time.Time                `json:"lastRecordedAt"`
+	UniqueNames     int64                     `json:"uniqueNames"`
+	AvgCPU          float64                   `json:"avgCpu"`
+	MaxCPU          float64                   `json:"maxCpu"`
+	AvgMemory       float64                   `json:"avgMemory"`
+	MaxMemory       float64                   `json:"maxMemory"`
+	MaxMemoryRSS    int64                     `json:"maxMemoryRss"`
+	TopProcesses    []RecordingProcessSummary `json:"topProcesses"`
+}
+
+/* Сессия записи вместе со сводной статистикой */
+type RecordingSessionDetail struct {
+	RecordingSessionInfo
+	Stats RecordingSessionStats `json:"stats"`
+}
+
+/* Фильтр для получения списка сессий записи */
+type RecordingSessionFilter struct {
+	Status string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+/* Сколько процессов попадает в топ сводной статистики сессии */
+const recordingTopProcesses = 10
+
+const recordingSessionColumns = `s.id, s.started_at, s.ended_at, s.cpu_threshold, s.ram_threshold, s.duration_sec, s.status, s.criteria,
+	COALESCE(s.mode, 'processes'), COALESCE(s.interval_sec, 0), s.alert_id, s.schedule_id,
+	(SELECT COUNT(*) FROM recorded_processes r WHERE r.session_id = s.id),
+	(SELECT COUNT(DISTINCT r.pid) FROM recorded_processes r WHERE r.session_id = s.id),
+	(SELECT COUNT(*) FROM recording_snapshots n WHERE n.session_id = s.id)`
+
+/* Читает сессию записи из строки результата запроса с колонками recordingSessionColumns */
+func scanRecordingSession(rows *sql.Rows, activeID int64) (RecordingSessionInfo, error) {
+	var s RecordingSessionInfo
+	var startedAt string
+	var endedAt, status, criteria sql.NullString
+	var alertID, scheduleID sql.NullInt64
+
+	if err := rows.Scan(&s.ID, &startedAt, &endedAt, &s.CPUThreshold, &s.RAMThreshold, &s.DurationSec, &status, &criteria,
+		&s.Mode, &s.IntervalSec, &alertID, &scheduleID, &s.RecordCount, &s.UniqueProcesses, &s.SnapshotCount); err != nil {
+		return s, err
+	}
+	if s.Mode == "processes" {
+		parsed := parseRecordingCriteria(criteria.String, s.CPUThreshold, s.RAMThreshold)
+		s.Criteria = &parsed
+	}
+	if alertID.Valid {
+		s.AlertID = &alertID.Int64
+	}
+	if scheduleID.Valid {
+		s.ScheduleID = &scheduleID.Int64
+	}
+
+	if parsed, ok := parseDBTime(startedAt); ok {
+		s.StartedAt = parsed
+	}
+	if parsed, ok := parseDBTime(endedAt.String); ok {
+		s.EndedAt = &parsed
+	}
+	s.Status = status.String
+	if s.Status == "active" && s.ID != activeID {
+		s.Status = "interrupted"
+	}
+	return s, nil
+}
+
+/* Возвращает ID активной сессии записи или 0, если запись не идет */
+func activeRecordingID() int64 {
+	active, session := GetRecordingStatus()
+	if !active || session == nil {
+		return 0
+	}
+	return session.ID
+}
+
+/* Получает список сессий записи от новых к старым с количеством записей и уникальных процессов */
+func ListRecordingSessions(filter RecordingSessionFilter) ([]RecordingSessionInfo, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	activeID := activeRecordingID()
+	query := "SELECT " + recordingSessionColumns + " FROM recording_sessions s WHERE 1=1"
+	var args []interface{}
+
+	switch filter.Status {
+	case "":
+	case "active":
+		query += " AND s.id = ?"
+		args = append(args, activeID)
+	case "interrupted":
+		query += " AND s.status = 'active' AND s.id != ?"
+		args = append(args, activeID)
+	default:
+		query += " AND s.status = ?"
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query += " AND s.started_at >= ?"
+		args = append(args, filter.From.Format("2006-01-02 15:04:05"))
+	}
+	if !filter.To.IsZero() {
+		query += " AND s.started_at <= ?"
+		args = append(args, filter.To.Format("2006-01-02 15:04:05"))
+	}
+	query += " ORDER BY s.id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []RecordingSessionInfo{}
+	for rows.Next() {
+		s, err := scanRecordingSession(rows, activeID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+/* Получает сессию записи со сводной статистикой, возвращает nil если сессия не найдена */
+func GetRecordingSession(id int64) (*RecordingSessionDetail, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	rows, err := db.Query("SELECT "+recordingSessionColumns+" FROM recording_sessions s WHERE s.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		rows.Close()
+		return nil, rows.Err()
+	}
+	info, err := scanRecordingSession(rows, activeRecordingID())
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &RecordingSessionDetail{RecordingSessionInfo: info}
+	stats := &detail.Stats
+	stats.TopProcesses = []RecordingProcessSummary{}
+
+	var first, last sql.NullString
+	var avgCPU, maxCPU, avgMem, maxMem sql.NullFloat64
+	var maxRSS sql.NullInt64
+	err = db.QueryRow(
+		"SELECT MIN(recorded_at), MAX(recorded_at), COUNT(DISTINCT name), AVG(cpu_percent), MAX(cpu_percent), AVG(memory_percent), MAX(memory_percent), MAX(memory_rss) FROM recorded_processes WHERE session_id = ?",
+		id,
+	).Scan(&first, &last, &stats.UniqueNames, &avgCPU, &maxCPU, &avgMem, &maxMem, &maxRSS)
+	if err != nil {
+		return nil, err
+	}
+	if parsed, ok := parseDBTime(first.String); ok {
+		stats.FirstRecordedAt = &parsed
+	}
+	if parsed, ok := parseDBTime(last.String); ok {
+		stats.LastRecordedAt = &parsed
+	}
+	stats.AvgCPU = avgCPU.Float64
+	stats.MaxCPU = maxCPU.Float64
+	stats.AvgMemory = avgMem.Float64
+	stats.MaxMemory = maxMem.Float64
+	stats.MaxMemoryRSS = maxRSS.Int64
+
+	topRows, err := db.Query(
+		"SELECT name, COUNT(*), AVG(cpu_percent), MAX(cpu_percent), AVG(memory_percent), MAX(memory_rss) FROM recorded_processes WHERE session_id = ? GROUP BY name ORDER BY AVG(cpu_percent) DESC LIMIT ?",
+		id, recordingTopProcesses,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer topRows.Close()
+
+	for topRows.Next() {
+		var p RecordingProcessSummary
+		if err := topRows.Scan(&p.Name, &p.Samples, &p.AvgCPU, &p.MaxCPU, &p.AvgMemory, &p.MaxMemoryRSS); err != nil {
+			return nil, err
+		}
+		stats.TopProcesses = append(stats.TopProcesses, p)
+	}
+	return detail, topRows.Err()
+}
+
+/* Удаляет сессию записи вместе с ее записанными процессами и снимками, активную сессию нужно сначала остановить */
+func DeleteRecordingSession(id int64) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	if id == activeRecordingID() {
+		return fmt.Errorf("сессия %d еще записывается, сначала остановите запись", id)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM recorded_processes WHERE session_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recording_snapshots WHERE session_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM recording_sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("сессия записи с ID %d не найдена", id)
+	}
+	return tx.Commit()
+}
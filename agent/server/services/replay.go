@@ -0,0 +1,160 @@
+/* Сервисы для воспроизведения сохраненных сессий записи */
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Загрузка памяти хоста в кадре воспроизведения */
+type ReplayMemory struct {
+	Percent float64
+	UsedMB  uint64
+	TotalMB uint64
+}
+
+/*
+Кадр воспроизведения - данные сессии на один момент времени
+
+	Кадр содержит либо список процессов, либо загрузку CPU и памяти хоста, либо все сразу для снимков системы
+*/
+type ReplayFrame struct {
+	At         time.Time
+	Processes  []models.ProcessInfo
+	CPU        *float64
+	Memory     *ReplayMemory
+	snapshotID int64
+}
+
+/* Кадры сессии записи в порядке времени */
+type ReplayTimeline struct {
+	SessionID int64
+	Mode      string
+	Frames    []ReplayFrame
+}
+
+/*
+Загружает кадры сессии записи для воспроизведения, возвращает nil если сессия не найдена
+
+	Для сессий процессов кадры строятся из записанных процессов, сгруппированных по времени записи,
+	и истории метрик хоста за время сессии. Для сессий снимков каждый снимок становится кадром,
+	содержимое снимка читается только при его воспроизведении
+*/
+func LoadReplayTimeline(sessionID int64) (*ReplayTimeline, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	session, err := GetRecordingSession(sessionID)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	timeline := &ReplayTimeline{SessionID: sessionID, Mode: session.Mode}
+	if session.Mode == "snapshot" {
+		snapshots, err := GetRecordingSnapshots(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range snapshots {
+			timeline.Frames = append(timeline.Frames, ReplayFrame{At: s.CapturedAt, snapshotID: s.ID})
+		}
+		return timeline, nil
+	}
+
+	rows, err := db.Query(
+		"SELECT recorded_at, pid, name, COALESCE(cpu_percent, 0), COALESCE(memory_percent, 0), COALESCE(memory_rss, 0), COALESCE(exe, ''), COALESCE(cmdline, ''), COALESCE(username, '') FROM recorded_processes WHERE session_id = ? ORDER BY recorded_at, id",
+		sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordedAt string
+		var p models.ProcessInfo
+		if err := rows.Scan(&recordedAt, &p.PID, &p.Name, &p.CPUPercent, &p.MemoryPercent, &p.MemoryRSS, &p.Exe, &p.Cmdline, &p.Username); err != nil {
+			return nil, err
+		}
+		p.Ports = []uint32{}
+		at, ok := parseDBTime(recordedAt)
+		if !ok {
+			continue
+		}
+
+		if n := len(timeline.Frames); n > 0 && timeline.Frames[n-1].At.Equal(at) {
+			timeline.Frames[n-1].Processes = append(timeline.Frames[n-1].Processes, p)
+			continue
+		}
+		timeline.Frames = append(timeline.Frames, ReplayFrame{At: at, Processes: []models.ProcessInfo{p}})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	if session.EndedAt != nil {
+		to = *session.EndedAt
+	}
+	metrics, err := db.Query(
+		"SELECT timestamp, cpu_percent, memory_percent, memory_used_mb, memory_total_mb FROM metrics_history WHERE timestamp >= ? AND timestamp <= ? ORDER BY timestamp, id",
+		session.StartedAt.Format("2006-01-02 15:04:05"),
+		to.Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer metrics.Close()
+
+	for metrics.Next() {
+		var timestamp string
+		var cpu float64
+		var memory ReplayMemory
+		if err := metrics.Scan(&timestamp, &cpu, &memory.Percent, &memory.UsedMB, &memory.TotalMB); err != nil {
+			return nil, err
+		}
+		if at, ok := parseDBTime(timestamp); ok {
+			timeline.Frames = append(timeline.Frames, ReplayFrame{At: at, CPU: &cpu, Memory: &memory})
+		}
+	}
+	if err := metrics.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(timeline.Frames, func(i, j int) bool {
+		return timeline.Frames[i].At.Before(timeline.Frames[j].At)
+	})
+	return timeline, nil
+}
+
+/* Возвращает кадр с указанным номером, для снимков системы читает и распаковывает снимок */
+func (t *ReplayTimeline) Frame(i int) (ReplayFrame, error) {
+	frame := t.Frames[i]
+	if frame.snapshotID == 0 {
+		return frame, nil
+	}
+
+	snapshot, err := GetRecordingSnapshot(t.SessionID, frame.snapshotID)
+	if err != nil {
+		return frame, err
+	}
+	if snapshot == nil {
+		return frame, fmt.Errorf("снимок %d не найден", frame.snapshotID)
+	}
+	frame.Processes = snapshot.Processes
+	frame.CPU = &snapshot.CPUPercent
+	frame.Memory = &ReplayMemory{Percent: snapshot.MemoryPercent, UsedMB: snapshot.MemoryUsedMB, TotalMB: snapshot.MemoryTotalMB}
+	return frame, nil
+}
+
+/* Возвращает номер первого кадра не раньше указанного момента, len(Frames) если таких кадров нет */
+func (t *ReplayTimeline) Seek(position time.Time) int {
+	return sort.Search(len(t.Frames), func(i int) bool {
+		return !t.Frames[i].At.Before(position)
+	})
+}
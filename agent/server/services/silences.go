@@ -0,0 +1,493 @@
+/* Сервисы для тишины алертов, окон обслуживания и отложенных алертов */
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Структура для хранения тишины: на время её действия подходящие алерты подавляются или отмечаются */
+type AlertSilence struct {
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	CreatedBy    string     `json:"createdBy"`
+	Comment      string     `json:"comment"`
+	MatcherType  string     `json:"matcherType"`  // "all", "type" - по типу алерта или "process" - по имени процесса
+	MatcherValue string     `json:"matcherValue"` // Тип алерта или шаблон имени процесса
+	StartsAt     time.Time  `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`      // Конец тишины, для окна по расписанию может отсутствовать
+	Schedule     string     `json:"schedule"`    // Расписание повторяющегося окна обслуживания в формате cron
+	DurationSec  int        `json:"durationSec"` // Длительность каждого окна по расписанию
+	Mode         string     `json:"mode"`        // "suppress" - алерт не создаётся, "mark" - создаётся с отметкой silenced
+	Enabled      bool       `json:"enabled"`
+	Active       bool       `json:"active"` // Действует ли тишина в момент запроса
+
+	schedule *CronSchedule
+}
+
+/* Структура для хранения записи журнала изменений тишины */
+type AlertSilenceAudit struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	SilenceID int64     `json:"silenceId"`
+	Action    string    `json:"action"` // "create", "update", "delete" или "snooze"
+	Actor     string    `json:"actor"`
+	Details   string    `json:"details"`
+}
+
+/* Максимальная длительность одного окна обслуживания по расписанию */
+const maxSilenceWindowSec = 7 * 24 * 60 * 60
+
+var (
+	silencesCache      []AlertSilence
+	silencesLoaded     bool
+	silencesGeneration uint64 // Растет при каждом сбросе кэша, чтобы не сохранить тишины, прочитанные до изменения
+	silencesMutex      sync.RWMutex
+)
+
+/* Проверяет корректность тишины и подставляет значения по умолчанию */
+func ValidateAlertSilence(silence *AlertSilence) error {
+	if silence.CreatedBy == "" {
+		return fmt.Errorf("поле 'createdBy' обязательно")
+	}
+
+	if silence.MatcherType == "" {
+		silence.MatcherType = "all"
+	}
+	switch silence.MatcherType {
+	case "all":
+		silence.MatcherValue = ""
+	case "type":
+		if silence.MatcherValue == "" {
+			return fmt.Errorf("для отбора по типу нужно указать 'matcherValue'")
+		}
+	case "process":
+		if silence.MatcherValue == "" {
+			return fmt.Errorf("для отбора по процессу нужно указать 'matcherValue'")
+		}
+		if _, err := path.Match(silence.MatcherValue, ""); err != nil {
+			return fmt.Errorf("некорректный шаблон имени '%s'", silence.MatcherValue)
+		}
+	default:
+		return fmt.Errorf("неизвестный тип отбора '%s'", silence.MatcherType)
+	}
+
+	if silence.Mode == "" {
+		silence.Mode = "suppress"
+	}
+	if silence.Mode != "suppress" && silence.Mode != "mark" {
+		return fmt.Errorf("неизвестный режим '%s', допустимы suppress и mark", silence.Mode)
+	}
+
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if silence.EndsAt != nil && !silence.EndsAt.After(silence.StartsAt) {
+		return fmt.Errorf("время окончания должно быть позже времени начала")
+	}
+
+	silence.Schedule = strings.TrimSpace(silence.Schedule)
+	if silence.Schedule == "" {
+		if silence.EndsAt == nil {
+			return fmt.Errorf("нужно указать 'endsAt' или расписание 'schedule'")
+		}
+		silence.DurationSec = 0
+		return nil
+	}
+
+	schedule, err := ParseCronSchedule(silence.Schedule)
+	if err != nil {
+		return fmt.Errorf("некорректное расписание: %w", err)
+	}
+	if silence.DurationSec <= 0 || silence.DurationSec > maxSilenceWindowSec {
+		return fmt.Errorf("длительность окна 'durationSec' должна быть от 1 до %d секунд", maxSilenceWindowSec)
+	}
+	silence.schedule = schedule
+	return nil
+}
+
+/* Проверяет, действует ли тишина в момент времени t */
+func (s *AlertSilence) ActiveAt(t time.Time) bool {
+	if !s.Enabled || t.Before(s.StartsAt) {
+		return false
+	}
+	if s.EndsAt != nil && !t.Before(*s.EndsAt) {
+		return false
+	}
+	if s.Schedule == "" {
+		return true
+	}
+	if s.schedule == nil {
+		schedule, err := ParseCronSchedule(s.Schedule)
+		if err != nil {
+			return false
+		}
+		s.schedule = schedule
+	}
+	return s.schedule.ActiveAt(t, time.Duration(s.DurationSec)*time.Second)
+}
+
+/* Проверяет, подходит ли алерт с указанным типом и именем процесса под отбор тишины */
+func (s *AlertSilence) Matches(alertType, processName string) bool {
+	switch s.MatcherType {
+	case "all":
+		return true
+	case "type":
+		return s.MatcherValue == alertType
+	case "process":
+		if processName == "" {
+			return false
+		}
+		ok, _ := path.Match(s.MatcherValue, processName)
+		return ok
+	}
+	return false
+}
+
+/* Создаёт новую тишину и записывает её в журнал, возвращает ID */
+func CreateAlertSilence(silence AlertSilence) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if err := ValidateAlertSilence(&silence); err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO alert_silences (created_at, created_by, comment, matcher_type, matcher_value, starts_at, ends_at, schedule, duration_sec, mode, enabled) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		silence.CreatedBy,
+		silence.Comment,
+		silence.MatcherType,
+		silence.MatcherValue,
+		silence.StartsAt.Local().Format("2006-01-02 15:04:05"),
+		formatSilenceEnd(silence.EndsAt),
+		silence.Schedule,
+		silence.DurationSec,
+		silence.Mode,
+		silence.Enabled,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	silence.ID = id
+	saveSilenceAudit(id, "create", silence.CreatedBy, silence)
+	invalidateSilences()
+	return id, nil
+}
+
+/* Обновляет существующую тишину по её ID, actor записывается в журнал изменений */
+func UpdateAlertSilence(silence AlertSilence, actor string) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	var createdBy string
+	if err := db.QueryRow("SELECT created_by FROM alert_silences WHERE id = ?", silence.ID).Scan(&createdBy); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("тишина с ID %d не найдена", silence.ID)
+		}
+		return err
+	}
+	silence.CreatedBy = createdBy
+	if err := ValidateAlertSilence(&silence); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(
+		"UPDATE alert_silences SET comment = ?, matcher_type = ?, matcher_value = ?, starts_at = ?, ends_at = ?, schedule = ?, duration_sec = ?, mode = ?, enabled = ? WHERE id = ?",
+		silence.Comment,
+		silence.MatcherType,
+		silence.MatcherValue,
+		silence.StartsAt.Local().Format("2006-01-02 15:04:05"),
+		formatSilenceEnd(silence.EndsAt),
+		silence.Schedule,
+		silence.DurationSec,
+		silence.Mode,
+		silence.Enabled,
+		silence.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	saveSilenceAudit(silence.ID, "update", actor, silence)
+	invalidateSilences()
+	return nil
+}
+
+/* Удаляет тишину по её ID, запись об удалении остаётся в журнале */
+func DeleteAlertSilence(id int64, actor string) error {
+	db := GetDB()
+	if db == nil {
+		return fmt.Errorf("база данных не инициализирована")
+	}
+
+	result, err := db.Exec("DELETE FROM alert_silences WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("тишина с ID %d не найдена", id)
+	}
+
+	saveSilenceAudit(id, "delete", actor, nil)
+	invalidateSilences()
+	return nil
+}
+
+/* Получает список тишин из базы данных, activeOnly оставляет только действующие сейчас */
+func GetAlertSilences(activeOnly bool) ([]AlertSilence, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT id, created_at, created_by, comment, matcher_type, matcher_value, starts_at, ends_at, schedule, duration_sec, mode, enabled FROM alert_silences ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	silences := []AlertSilence{}
+	for rows.Next() {
+		var s AlertSilence
+		var createdAtStr, startsAtStr string
+		var comment, matcherValue, endsAtStr, schedule sql.NullString
+		var enabled int
+
+		if err := rows.Scan(&s.ID, &createdAtStr, &s.CreatedBy, &comment, &s.MatcherType, &matcherValue, &startsAtStr, &endsAtStr, &schedule, &s.DurationSec, &s.Mode, &enabled); err != nil {
+			return nil, err
+		}
+
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			s.CreatedAt = parsed
+		}
+		if parsed, ok := parseDBTime(startsAtStr); ok {
+			s.StartsAt = parsed
+		}
+		if parsed, ok := parseDBTime(endsAtStr.String); ok {
+			s.EndsAt = &parsed
+		}
+		s.Comment = comment.String
+		s.MatcherValue = matcherValue.String
+		s.Schedule = schedule.String
+		s.Enabled = enabled == 1
+		s.Active = s.ActiveAt(now)
+
+		if activeOnly && !s.Active {
+			continue
+		}
+		silences = append(silences, s)
+	}
+
+	return silences, rows.Err()
+}
+
+/*
+Откладывает алерт на durationSec секунд: создаёт тишину по процессу или типу алерта
+
+	Сам алерт отмечается как заглушенный, поэтому уведомление о его снятии не отправляется
+*/
+func SnoozeAlert(alertID int64, durationSec int, actor, comment string) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+	if durationSec <= 0 {
+		return 0, fmt.Errorf("длительность 'durationSec' должна быть больше нуля")
+	}
+
+	alert, err := GetAlertByID(alertID)
+	if err != nil {
+		return 0, err
+	}
+	if alert == nil {
+		return 0, fmt.Errorf("алерт с ID %d не найден", alertID)
+	}
+
+	now := time.Now()
+	endsAt := now.Add(time.Duration(durationSec) * time.Second)
+	silence := AlertSilence{
+		CreatedBy:    actor,
+		Comment:      comment,
+		MatcherType:  "type",
+		MatcherValue: alert.Type,
+		StartsAt:     now,
+		EndsAt:       &endsAt,
+		Mode:         "suppress",
+		Enabled:      true,
+	}
+	if alert.ProcessName != "" {
+		silence.MatcherType = "process"
+		silence.MatcherValue = escapeSilencePattern(alert.ProcessName)
+	}
+	if silence.Comment == "" {
+		silence.Comment = fmt.Sprintf("Отложен алерт %d", alertID)
+	}
+
+	id, err := CreateAlertSilence(silence)
+	if err != nil {
+		return 0, err
+	}
+	saveSilenceAudit(id, "snooze", actor, map[string]interface{}{"alertId": alertID, "durationSec": durationSec})
+
+	if _, err := db.Exec("UPDATE alerts SET silenced = 1, silence_id = ? WHERE id = ?", id, alertID); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+/* Получает журнал изменений тишин, silenceID равный нулю возвращает записи по всем тишинам */
+func GetAlertSilenceAudit(silenceID int64, limit int) ([]AlertSilenceAudit, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	query := "SELECT id, created_at, silence_id, action, actor, details FROM alert_silence_audit"
+	args := []interface{}{}
+	if silenceID > 0 {
+		query += " WHERE silence_id = ?"
+		args = append(args, silenceID)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AlertSilenceAudit{}
+	for rows.Next() {
+		var e AlertSilenceAudit
+		var createdAtStr string
+		var details sql.NullString
+		if err := rows.Scan(&e.ID, &createdAtStr, &e.SilenceID, &e.Action, &e.Actor, &details); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(createdAtStr); ok {
+			e.CreatedAt = parsed
+		}
+		e.Details = details.String
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+/* Записывает действие с тишиной в журнал изменений */
+func saveSilenceAudit(silenceID int64, action, actor string, details interface{}) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	var detailsStr interface{}
+	if details != nil {
+		if data, err := json.Marshal(details); err == nil {
+			detailsStr = string(data)
+		}
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO alert_silence_audit (created_at, silence_id, action, actor, details) VALUES (?, ?, ?, ?, ?)",
+		time.Now().Format("2006-01-02 15:04:05"),
+		silenceID,
+		action,
+		actor,
+		detailsStr,
+	)
+	if err != nil {
+		log.Printf("Ошибка записи журнала тишины %d: %v", silenceID, err)
+	}
+}
+
+/*
+Находит тишину, действующую для алерта в момент now
+
+	Тишина в режиме suppress имеет приоритет над режимом mark, nil означает что алерт не заглушен
+*/
+func findActiveSilence(alertType, processName string, now time.Time) *AlertSilence {
+	var marked *AlertSilence
+	for _, s := range cachedSilences() {
+		if !s.Matches(alertType, processName) || !s.ActiveAt(now) {
+			continue
+		}
+		if s.Mode == "suppress" {
+			return &s
+		}
+		if marked == nil {
+			silence := s
+			marked = &silence
+		}
+	}
+	return marked
+}
+
+/* Возвращает закэшированные тишины, загружая их из базы при первом обращении после изменения */
+func cachedSilences() []AlertSilence {
+	silencesMutex.RLock()
+	if silencesLoaded {
+		silences := silencesCache
+		silencesMutex.RUnlock()
+		return silences
+	}
+	generation := silencesGeneration
+	silencesMutex.RUnlock()
+
+	silences, err := GetAlertSilences(false)
+	if err != nil || silences == nil {
+		return nil
+	}
+
+	// Если кэш сбросили во время чтения, тишины могли устареть: их не кэшируем, следующий вызов прочитает заново
+	silencesMutex.Lock()
+	if silencesGeneration == generation {
+		silencesCache = silences
+		silencesLoaded = true
+	}
+	silencesMutex.Unlock()
+	return silences
+}
+
+/* Сбрасывает кэш тишин после их изменения */
+func invalidateSilences() {
+	silencesMutex.Lock()
+	silencesLoaded = false
+	silencesGeneration++
+	silencesMutex.Unlock()
+}
+
+/* Форматирует время окончания тишины для базы данных */
+func formatSilenceEnd(endsAt *time.Time) interface{} {
+	if endsAt == nil {
+		return nil
+	}
+	return endsAt.Local().Format("2006-01-02 15:04:05")
+}
+
+/* Экранирует спецсимволы шаблона, чтобы имя процесса совпадало только с самим собой */
+func escapeSilencePattern(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+	return replacer.Replace(name)
+}
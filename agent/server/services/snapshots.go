@@ -0,0 +1,471 @@
+/* Сервисы для записи полных снимков системы и бортового самописца */
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/getmetrics"
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Настройки бортового самописца - кольцевого буфера снимков в памяти */
+type FlightRecorderConfig struct {
+	Enabled         bool   `json:"enabled"`
+	IntervalSec     int    `json:"intervalSec"`     // Как часто снимать снимок системы
+	BufferMinutes   int    `json:"bufferMinutes"`   // Сколько последних минут хранить в памяти
+	DumpOnAlert     bool   `json:"dumpOnAlert"`     // Сохранять буфер на диск при срабатывании алерта
+	MinSeverity     string `json:"minSeverity"`     // Минимальная важность алерта для сохранения буфера
+	DumpCooldownSec int    `json:"dumpCooldownSec"` // Минимальная пауза между сохранениями по алертам
+}
+
+/* Состояние бортового самописца */
+type FlightRecorderStatus struct {
+	Running     bool       `json:"running"`
+	Snapshots   int        `json:"snapshots"`
+	BufferBytes int        `json:"bufferBytes"`
+	OldestAt    *time.Time `json:"oldestAt"`
+	NewestAt    *time.Time `json:"newestAt"`
+	LastDumpAt  *time.Time `json:"lastDumpAt"`
+}
+
+/* Сведения о сохраненном снимке без его содержимого */
+type SnapshotInfo struct {
+	ID              int64     `json:"id"`
+	CapturedAt      time.Time `json:"capturedAt"`
+	ProcessCount    int       `json:"processCount"`
+	ConnectionCount int       `json:"connectionCount"`
+	CPUPercent      float64   `json:"cpuPercent"`
+	MemoryPercent   float64   `json:"memoryPercent"`
+	SizeBytes       int       `json:"sizeBytes"`
+}
+
+/* Сжатый снимок, готовый к сохранению в базу данных */
+type encodedSnapshot struct {
+	capturedAt      time.Time
+	processCount    int
+	connectionCount int
+	cpuPercent      float64
+	memoryPercent   float64
+	data            []byte
+}
+
+/* Минимальный интервал между снимками, полный снимок занимает заметное время */
+const minSnapshotIntervalSec = 2
+
+var (
+	flightRecorderConfig = FlightRecorderConfig{
+		IntervalSec:     10,
+		BufferMinutes:   10,
+		DumpOnAlert:     true,
+		MinSeverity:     "warning",
+		DumpCooldownSec: 300,
+	}
+	flightRecorderBuffer []encodedSnapshot
+	flightRecorderCancel context.CancelFunc
+	flightRecorderDumpAt time.Time
+	flightRecorderMutex  sync.Mutex
+)
+
+/* Запускает сессию записи полных снимков системы с указанным интервалом и длительностью */
+func StartSnapshotRecording(intervalSec, durationSec int) (int64, error) {
+	if err := validateSnapshotInterval(intervalSec, durationSec); err != nil {
+		return 0, err
+	}
+
+	session := &RecordingSession{Mode: "snapshot", IntervalSec: intervalSec, Duration: durationSec}
+	return beginRecording(session, func(ctx context.Context, db *sql.DB, sessionID int64, endTime time.Time) {
+		snapshotRecordingLoop(ctx, db, sessionID, intervalSec, endTime)
+	})
+}
+
+/* Проверяет интервал снимков сессии записи: не меньше минимального и меньше длительности записи */
+func validateSnapshotInterval(intervalSec, durationSec int) error {
+	if intervalSec < minSnapshotIntervalSec {
+		return fmt.Errorf("интервал снимков должен быть не меньше %d секунд", minSnapshotIntervalSec)
+	}
+	if intervalSec >= durationSec {
+		return fmt.Errorf("интервал снимков должен быть меньше длительности записи")
+	}
+	return nil
+}
+
+/* Снимает и сохраняет снимки системы до окончания времени сессии, первый снимок сразу после запуска */
+func snapshotRecordingLoop(ctx context.Context, db *sql.DB, sessionID int64, intervalSec int, endTime time.Time) {
+	ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
+	defer ticker.Stop()
+
+	var previous *models.SystemSnapshot
+	record := func() {
+		snapshot, encoded, err := captureSnapshot(previous)
+		if err != nil {
+			log.Printf("Ошибка снятия снимка системы: %v", err)
+			return
+		}
+		previous = &snapshot
+
+		if err := saveSnapshots(db, sessionID, []encodedSnapshot{encoded}); err != nil {
+			log.Printf("Ошибка сохранения снимка системы: %v", err)
+		}
+	}
+
+	record()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Now().After(endTime) {
+				stopRecordingSession(sessionID)
+				return
+			}
+			record()
+		}
+	}
+}
+
+/* Снимает снимок системы, считает скорость трафика интерфейсов по предыдущему снимку и сжимает его */
+func captureSnapshot(previous *models.SystemSnapshot) (models.SystemSnapshot, encodedSnapshot, error) {
+	snapshot, err := getmetrics.CaptureSystemSnapshot()
+	if err != nil {
+		return snapshot, encodedSnapshot{}, err
+	}
+
+	if previous != nil {
+		elapsed := snapshot.CapturedAt.Sub(previous.CapturedAt).Seconds()
+		prevByName := make(map[string]models.SnapshotInterface, len(previous.Interfaces))
+		for _, iface := range previous.Interfaces {
+			prevByName[iface.Name] = iface
+		}
+		for i := range snapshot.Interfaces {
+			prev, ok := prevByName[snapshot.Interfaces[i].Name]
+			if !ok || elapsed <= 0 {
+				continue
+			}
+			if snapshot.Interfaces[i].BytesRecv >= prev.BytesRecv {
+				snapshot.Interfaces[i].RxBps = float64(snapshot.Interfaces[i].BytesRecv-prev.BytesRecv) / elapsed
+			}
+			if snapshot.Interfaces[i].BytesSent >= prev.BytesSent {
+				snapshot.Interfaces[i].TxBps = float64(snapshot.Interfaces[i].BytesSent-prev.BytesSent) / elapsed
+			}
+		}
+	}
+
+	data, err := encodeSnapshot(snapshot)
+	if err != nil {
+		return snapshot, encodedSnapshot{}, err
+	}
+
+	return snapshot, encodedSnapshot{
+		capturedAt:      snapshot.CapturedAt,
+		processCount:    len(snapshot.Processes),
+		connectionCount: len(snapshot.Connections),
+		cpuPercent:      snapshot.CPUPercent,
+		memoryPercent:   snapshot.MemoryPercent,
+		data:            data,
+	}, nil
+}
+
+/* Сериализует снимок в JSON и сжимает gzip */
+func encodeSnapshot(snapshot models.SystemSnapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/* Распаковывает снимок, сохраненный encodeSnapshot */
+func decodeSnapshot(data []byte) (*models.SystemSnapshot, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot models.SystemSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+/* Сохраняет сжатые снимки сессии в базу данных одной транзакцией */
+func saveSnapshots(db *sql.DB, sessionID int64, snapshots []encodedSnapshot) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, s := range snapshots {
+		_, err := tx.Exec(
+			"INSERT INTO recording_snapshots (session_id, captured_at, process_count, connection_count, cpu_percent, memory_percent, data) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			sessionID,
+			s.capturedAt.Format("2006-01-02 15:04:05"),
+			s.processCount,
+			s.connectionCount,
+			s.cpuPercent,
+			s.memoryPercent,
+			s.data,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Возвращает список снимков сессии без их содержимого в порядке снятия */
+func GetRecordingSnapshots(sessionID int64) ([]SnapshotInfo, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	rows, err := db.Query(
+		"SELECT id, captured_at, process_count, connection_count, COALESCE(cpu_percent, 0), COALESCE(memory_percent, 0), LENGTH(data) FROM recording_snapshots WHERE session_id = ? ORDER BY captured_at, id",
+		sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []SnapshotInfo{}
+	for rows.Next() {
+		var s SnapshotInfo
+		var capturedAt string
+		if err := rows.Scan(&s.ID, &capturedAt, &s.ProcessCount, &s.ConnectionCount, &s.CPUPercent, &s.MemoryPercent, &s.SizeBytes); err != nil {
+			return nil, err
+		}
+		if parsed, ok := parseDBTime(capturedAt); ok {
+			s.CapturedAt = parsed
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+/* Возвращает содержимое снимка сессии, nil если снимок не найден */
+func GetRecordingSnapshot(sessionID, snapshotID int64) (*models.SystemSnapshot, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("база данных не инициализирована")
+	}
+
+	var data []byte
+	err := db.QueryRow("SELECT data FROM recording_snapshots WHERE id = ? AND session_id = ?", snapshotID, sessionID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(data)
+}
+
+/* Устанавливает настройки бортового самописца и запускает или останавливает его */
+func SetFlightRecorderConfig(cfg FlightRecorderConfig) error {
+	if cfg.IntervalSec < minSnapshotIntervalSec {
+		return fmt.Errorf("intervalSec должен быть не меньше %d секунд", minSnapshotIntervalSec)
+	}
+	if cfg.BufferMinutes <= 0 || cfg.BufferMinutes > 120 {
+		return fmt.Errorf("bufferMinutes должен быть от 1 до 120 минут")
+	}
+	if cfg.DumpCooldownSec < 0 {
+		return fmt.Errorf("dumpCooldownSec не может быть отрицательным")
+	}
+	if !alertSeverities[cfg.MinSeverity] {
+		return fmt.Errorf("неизвестная важность '%s'", cfg.MinSeverity)
+	}
+
+	flightRecorderMutex.Lock()
+	defer flightRecorderMutex.Unlock()
+
+	restart := cfg.IntervalSec != flightRecorderConfig.IntervalSec
+	flightRecorderConfig = cfg
+
+	if flightRecorderCancel != nil && (!cfg.Enabled || restart) {
+		flightRecorderCancel()
+		flightRecorderCancel = nil
+	}
+	if !cfg.Enabled {
+		flightRecorderBuffer = nil
+		return nil
+	}
+	if flightRecorderCancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		flightRecorderCancel = cancel
+		go flightRecorderLoop(ctx, cfg.IntervalSec)
+	}
+	return nil
+}
+
+/* Возвращает текущие настройки бортового самописца */
+func GetFlightRecorderConfig() FlightRecorderConfig {
+	flightRecorderMutex.Lock()
+	defer flightRecorderMutex.Unlock()
+	return flightRecorderConfig
+}
+
+/* Возвращает количество и объем снимков в буфере бортового самописца */
+func GetFlightRecorderStatus() FlightRecorderStatus {
+	flightRecorderMutex.Lock()
+	defer flightRecorderMutex.Unlock()
+
+	status := FlightRecorderStatus{
+		Running:   flightRecorderCancel != nil,
+		Snapshots: len(flightRecorderBuffer),
+	}
+	for _, s := range flightRecorderBuffer {
+		status.BufferBytes += len(s.data)
+	}
+	if len(flightRecorderBuffer) > 0 {
+		oldest := flightRecorderBuffer[0].capturedAt
+		newest := flightRecorderBuffer[len(flightRecorderBuffer)-1].capturedAt
+		status.OldestAt = &oldest
+		status.NewestAt = &newest
+	}
+	if !flightRecorderDumpAt.IsZero() {
+		lastDump := flightRecorderDumpAt
+		status.LastDumpAt = &lastDump
+	}
+	return status
+}
+
+/* Снимает снимки в кольцевой буфер, отбрасывая снимки старше bufferMinutes */
+func flightRecorderLoop(ctx context.Context, intervalSec int) {
+	ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
+	defer ticker.Stop()
+
+	var previous *models.SystemSnapshot
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot, encoded, err := captureSnapshot(previous)
+			if err != nil {
+				log.Printf("Ошибка снятия снимка бортового самописца: %v", err)
+				continue
+			}
+			previous = &snapshot
+
+			flightRecorderMutex.Lock()
+			if ctx.Err() != nil {
+				flightRecorderMutex.Unlock()
+				return
+			}
+			cutoff := encoded.capturedAt.Add(-time.Duration(flightRecorderConfig.BufferMinutes) * time.Minute)
+			drop := 0
+			for drop < len(flightRecorderBuffer) && flightRecorderBuffer[drop].capturedAt.Before(cutoff) {
+				drop++
+			}
+			flightRecorderBuffer = append(flightRecorderBuffer[drop:], encoded)
+			flightRecorderMutex.Unlock()
+		}
+	}
+}
+
+/*
+Сохраняет содержимое буфера бортового самописца на диск как завершенную сессию записи снимков
+
+	alertID связывает сессию с алертом, по которому она сохранена, 0 - сохранение вручную.
+	Возвращает ID созданной сессии или ошибку
+*/
+func DumpFlightRecorder(alertID int64) (int64, error) {
+	db := GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("база данных не инициализирована")
+	}
+
+	flightRecorderMutex.Lock()
+	snapshots := append([]encodedSnapshot(nil), flightRecorderBuffer...)
+	intervalSec := flightRecorderConfig.IntervalSec
+	if len(snapshots) > 0 {
+		flightRecorderDumpAt = time.Now()
+	}
+	flightRecorderMutex.Unlock()
+
+	if len(snapshots) == 0 {
+		return 0, fmt.Errorf("буфер бортового самописца пуст")
+	}
+
+	startedAt := snapshots[0].capturedAt
+	endedAt := snapshots[len(snapshots)-1].capturedAt
+	var alert interface{}
+	if alertID != 0 {
+		alert = alertID
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO recording_sessions (started_at, ended_at, cpu_threshold, ram_threshold, duration_sec, status, mode, interval_sec, alert_id) VALUES (?, ?, 0, 0, ?, 'stopped', 'snapshot', ?, ?)",
+		startedAt.Format("2006-01-02 15:04:05"),
+		endedAt.Format("2006-01-02 15:04:05"),
+		int(endedAt.Sub(startedAt).Seconds()),
+		intervalSec,
+		alert,
+	)
+	if err != nil {
+		return 0, err
+	}
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := saveSnapshots(db, sessionID, snapshots); err != nil {
+		return 0, err
+	}
+	return sessionID, nil
+}
+
+/* Сохраняет буфер бортового самописца при срабатывании алерта, если это разрешено настройками и прошла пауза */
+func dumpFlightRecorderOnAlert(alertID int64) {
+	alert, err := GetAlertByID(alertID)
+	if err != nil || alert == nil || alert.Silenced {
+		return
+	}
+
+	flightRecorderMutex.Lock()
+	cfg := flightRecorderConfig
+	allowed := flightRecorderCancel != nil && cfg.DumpOnAlert &&
+		severityRank[alert.Severity] >= severityRank[cfg.MinSeverity] &&
+		time.Since(flightRecorderDumpAt) >= time.Duration(cfg.DumpCooldownSec)*time.Second
+	if allowed {
+		flightRecorderDumpAt = time.Now()
+	}
+	flightRecorderMutex.Unlock()
+
+	if !allowed {
+		return
+	}
+
+	sessionID, err := DumpFlightRecorder(alertID)
+	if err != nil {
+		log.Printf("Ошибка сохранения бортового самописца по алерту %d: %v", alertID, err)
+		return
+	}
+	log.Printf("Бортовой самописец сохранен в сессию %d по алерту %d", sessionID, alertID)
+}
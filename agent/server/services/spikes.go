@@ -0,0 +1,386 @@
+/* Сервисы для автоматического обнаружения всплесков потребления ресурсов процессами */
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Структура для хранения записи о всплеске из базы данных */
+type Spike struct {
+	ID          int64      `json:"id"`
+	DetectedAt  time.Time  `json:"detectedAt"`
+	EndedAt     *time.Time `json:"endedAt"`
+	PID         int32      `json:"pid"`
+	Name        string     `json:"name"`
+	CPUPercent  float64    `json:"cpuPercent"`
+	MemoryRSS   uint64     `json:"memoryRss"`
+	MemoryVMS   uint64     `json:"memoryVms"`
+	DurationSec int64      `json:"durationSec"`
+	Reason      string     `json:"reason"`
+	Active      bool       `json:"active"`
+}
+
+/* Настройки детектора всплесков */
+type SpikeConfig struct {
+	Enabled         bool    `json:"enabled"`
+	CPUThreshold    float64 `json:"cpuThreshold"`    // Абсолютный порог CPU в процентах, 0 - отключен
+	RSSThresholdMB  uint64  `json:"rssThresholdMB"`  // Абсолютный порог RSS в мегабайтах, 0 - отключен
+	DeviationFactor float64 `json:"deviationFactor"` // Во сколько раз значение должно превысить базовую линию, 0 - отключено
+	MinDurationSec  int     `json:"minDurationSec"`  // Сколько секунд условие должно держаться до открытия всплеска
+}
+
+/* Фильтр для получения списка всплесков */
+type SpikeFilter struct {
+	PID        int32
+	Name       string
+	Reason     string
+	From       time.Time
+	To         time.Time
+	ActiveOnly bool
+	Limit      int
+}
+
+/* Состояние отслеживания одного процесса между обновлениями кэша */
+type spikeTracker struct {
+	pid          int32
+	samples      int
+	baselineCPU  float64
+	baselineRSS  float64
+	pendingSince time.Time
+	lastAboveAt  time.Time
+	reasons      []string
+	spikeID      int64
+	peakCPU      float64
+	peakRSS      uint64
+	peakVMS      uint64
+}
+
+const (
+	/* Коэффициент сглаживания базовой линии */
+	spikeBaselineAlpha = 0.1
+	/* Количество замеров до начала проверки отклонений от базовой линии */
+	spikeWarmupSamples = 3
+	/* Минимальная разница с базовой линией по CPU в процентных пунктах, чтобы отсечь шум */
+	spikeMinCPUDelta = 20.0
+	/* Минимальная разница с базовой линией по RSS в байтах */
+	spikeMinRSSDelta = 100 * 1024 * 1024
+	/* Пауза между замерами, после которой всплеск считается прерванным */
+	spikeStaleAfter = 30 * time.Second
+)
+
+var (
+	spikeConfig = SpikeConfig{
+		Enabled:         true,
+		CPUThreshold:    80,
+		DeviationFactor: 3,
+		MinDurationSec:  10,
+	}
+	spikeTrackers    = make(map[string]*spikeTracker)
+	spikeMutex       sync.Mutex
+	spikeOrphansOnce sync.Once
+)
+
+/* Устанавливает настройки детектора всплесков */
+func SetSpikeConfig(cfg SpikeConfig) error {
+	if cfg.CPUThreshold < 0 || cfg.DeviationFactor < 0 || cfg.MinDurationSec < 0 {
+		return fmt.Errorf("параметры детектора не могут быть отрицательными")
+	}
+	if cfg.DeviationFactor > 0 && cfg.DeviationFactor <= 1 {
+		return fmt.Errorf("deviationFactor должен быть больше 1")
+	}
+
+	spikeMutex.Lock()
+	defer spikeMutex.Unlock()
+	spikeConfig = cfg
+	return nil
+}
+
+/* Возвращает текущие настройки детектора всплесков */
+func GetSpikeConfig() SpikeConfig {
+	spikeMutex.Lock()
+	defer spikeMutex.Unlock()
+	return spikeConfig
+}
+
+/*
+Обрабатывает очередной снимок процессов и обновляет состояние всплесков
+
+	Вызывается при каждом обновлении кэша процессов, процессам с активным всплеском проставляется поле Spike
+*/
+func DetectSpikes(procs []models.ProcessInfo) {
+	spikeOrphansOnce.Do(closeOrphanSpikes)
+
+	now := time.Now()
+
+	spikeMutex.Lock()
+	defer spikeMutex.Unlock()
+
+	cfg := spikeConfig
+	seen := make(map[string]bool, len(procs))
+
+	for i := range procs {
+		p := &procs[i]
+		key := fmt.Sprintf("%d:%d", p.PID, p.CreateTime)
+		seen[key] = true
+
+		tracker, ok := spikeTrackers[key]
+		if !ok {
+			tracker = &spikeTracker{pid: p.PID}
+			spikeTrackers[key] = tracker
+		}
+
+		if !cfg.Enabled {
+			/* После отключения детектора открытые всплески закрываются, а не остаются открытыми навсегда */
+			if tracker.spikeID != 0 || !tracker.pendingSince.IsZero() {
+				finishSpike(tracker)
+			}
+			continue
+		}
+
+		if !tracker.lastAboveAt.IsZero() && now.Sub(tracker.lastAboveAt) > spikeStaleAfter {
+			finishSpike(tracker)
+		}
+
+		reasons := spikeReasons(cfg, tracker, p)
+		if len(reasons) == 0 {
+			if tracker.spikeID != 0 || !tracker.pendingSince.IsZero() {
+				finishSpike(tracker)
+			}
+			updateSpikeBaseline(tracker, p)
+			continue
+		}
+
+		if tracker.pendingSince.IsZero() {
+			tracker.pendingSince = now
+			tracker.reasons = reasons
+		}
+		tracker.lastAboveAt = now
+		if p.CPUPercent > tracker.peakCPU {
+			tracker.peakCPU = p.CPUPercent
+		}
+		if p.MemoryRSS > tracker.peakRSS {
+			tracker.peakRSS = p.MemoryRSS
+		}
+		if p.MemoryVMS > tracker.peakVMS {
+			tracker.peakVMS = p.MemoryVMS
+		}
+
+		if tracker.spikeID == 0 && now.Sub(tracker.pendingSince) >= time.Duration(cfg.MinDurationSec)*time.Second {
+			openSpike(tracker, p)
+		}
+
+		if tracker.spikeID != 0 {
+			p.Spike = &models.ProcessSpike{
+				ID:          tracker.spikeID,
+				Reason:      strings.Join(tracker.reasons, ","),
+				StartedAt:   tracker.pendingSince.Format("2006-01-02 15:04:05"),
+				DurationSec: int64(now.Sub(tracker.pendingSince).Seconds()),
+				PeakCPU:     tracker.peakCPU,
+				PeakRSS:     tracker.peakRSS,
+			}
+		}
+	}
+
+	for key, tracker := range spikeTrackers {
+		if seen[key] {
+			continue
+		}
+		finishSpike(tracker)
+		delete(spikeTrackers, key)
+	}
+}
+
+/* Определяет причины, по которым текущие значения процесса считаются всплеском */
+func spikeReasons(cfg SpikeConfig, tracker *spikeTracker, p *models.ProcessInfo) []string {
+	var reasons []string
+
+	if cfg.CPUThreshold > 0 && p.CPUPercent > cfg.CPUThreshold {
+		reasons = append(reasons, "cpu_threshold")
+	}
+	if cfg.RSSThresholdMB > 0 && p.MemoryRSS > cfg.RSSThresholdMB*1024*1024 {
+		reasons = append(reasons, "rss_threshold")
+	}
+
+	if cfg.DeviationFactor > 0 && tracker.samples >= spikeWarmupSamples {
+		if p.CPUPercent > tracker.baselineCPU*cfg.DeviationFactor && p.CPUPercent-tracker.baselineCPU > spikeMinCPUDelta {
+			reasons = append(reasons, "cpu_deviation")
+		}
+		rss := float64(p.MemoryRSS)
+		if rss > tracker.baselineRSS*cfg.DeviationFactor && rss-tracker.baselineRSS > spikeMinRSSDelta {
+			reasons = append(reasons, "rss_deviation")
+		}
+	}
+
+	return reasons
+}
+
+/* Обновляет скользящую базовую линию процесса, пока он не находится во всплеске */
+func updateSpikeBaseline(tracker *spikeTracker, p *models.ProcessInfo) {
+	if tracker.samples == 0 {
+		tracker.baselineCPU = p.CPUPercent
+		tracker.baselineRSS = float64(p.MemoryRSS)
+	} else {
+		tracker.baselineCPU += spikeBaselineAlpha * (p.CPUPercent - tracker.baselineCPU)
+		tracker.baselineRSS += spikeBaselineAlpha * (float64(p.MemoryRSS) - tracker.baselineRSS)
+	}
+	tracker.samples++
+}
+
+/* Сохраняет новый всплеск в базу данных */
+func openSpike(tracker *spikeTracker, p *models.ProcessInfo) {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO spikes (detected_at, pid, name, cpu_percent, memory_rss, memory_vms, duration_sec, reason) VALUES (?, ?, ?, ?, ?, ?, 0, ?)",
+		tracker.pendingSince.Format("2006-01-02 15:04:05"),
+		p.PID,
+		p.Name,
+		tracker.peakCPU,
+		tracker.peakRSS,
+		tracker.peakVMS,
+		strings.Join(tracker.reasons, ","),
+	)
+	if err != nil {
+		log.Printf("Ошибка сохранения всплеска PID=%d: %v", p.PID, err)
+		return
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Ошибка получения ID всплеска PID=%d: %v", p.PID, err)
+		return
+	}
+	tracker.spikeID = id
+	log.Printf("Обнаружен всплеск PID=%d (%s): %s", p.PID, p.Name, strings.Join(tracker.reasons, ","))
+}
+
+/* Закрывает всплеск, записывая пиковые значения и измеренную длительность, и сбрасывает состояние */
+func finishSpike(tracker *spikeTracker) {
+	if tracker.spikeID != 0 {
+		if db := GetDB(); db != nil {
+			duration := int64(tracker.lastAboveAt.Sub(tracker.pendingSince).Seconds())
+			_, err := db.Exec(
+				"UPDATE spikes SET cpu_percent = ?, memory_rss = ?, memory_vms = ?, duration_sec = ?, ended_at = ? WHERE id = ?",
+				tracker.peakCPU,
+				tracker.peakRSS,
+				tracker.peakVMS,
+				duration,
+				tracker.lastAboveAt.Format("2006-01-02 15:04:05"),
+				tracker.spikeID,
+			)
+			if err != nil {
+				log.Printf("Ошибка закрытия всплеска PID=%d: %v", tracker.pid, err)
+			}
+		}
+	}
+
+	tracker.spikeID = 0
+	tracker.pendingSince = time.Time{}
+	tracker.lastAboveAt = time.Time{}
+	tracker.reasons = nil
+	tracker.peakCPU = 0
+	tracker.peakRSS = 0
+	tracker.peakVMS = 0
+}
+
+/* Закрывает всплески, оставшиеся открытыми после прошлого запуска агента */
+func closeOrphanSpikes() {
+	db := GetDB()
+	if db == nil {
+		return
+	}
+	_, err := db.Exec("UPDATE spikes SET ended_at = datetime(detected_at, '+' || duration_sec || ' seconds') WHERE ended_at IS NULL")
+	if err != nil {
+		log.Printf("Ошибка закрытия незавершённых всплесков: %v", err)
+	}
+}
+
+/*
+Получает список всплесков с фильтрацией по PID, имени, причине, периоду и активности
+
+	Длительность активных всплесков рассчитывается на момент запроса
+*/
+func GetSpikes(filter SpikeFilter) ([]Spike, error) {
+	db := GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	query := "SELECT id, detected_at, ended_at, pid, name, cpu_percent, memory_rss, memory_vms, duration_sec, reason FROM spikes WHERE 1=1"
+	var args []interface{}
+
+	if filter.PID > 0 {
+		query += " AND pid = ?"
+		args = append(args, filter.PID)
+	}
+	if filter.Name != "" {
+		query += " AND name LIKE ?"
+		args = append(args, "%"+filter.Name+"%")
+	}
+	if filter.Reason != "" {
+		query += " AND reason LIKE ?"
+		args = append(args, "%"+filter.Reason+"%")
+	}
+	if !filter.From.IsZero() {
+		query += " AND detected_at >= ?"
+		args = append(args, filter.From.Format("2006-01-02 15:04:05"))
+	}
+	if !filter.To.IsZero() {
+		query += " AND detected_at <= ?"
+		args = append(args, filter.To.Format("2006-01-02 15:04:05"))
+	}
+	if filter.ActiveOnly {
+		query += " AND ended_at IS NULL"
+	}
+	query += " ORDER BY detected_at DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	spikes := []Spike{}
+	for rows.Next() {
+		var s Spike
+		var detectedAtStr string
+		var endedAtStr *string
+		var memRSS, memVMS int64
+
+		if err := rows.Scan(&s.ID, &detectedAtStr, &endedAtStr, &s.PID, &s.Name, &s.CPUPercent, &memRSS, &memVMS, &s.DurationSec, &s.Reason); err != nil {
+			return nil, err
+		}
+
+		s.MemoryRSS = uint64(memRSS)
+		s.MemoryVMS = uint64(memVMS)
+		if parsed, ok := parseDBTime(detectedAtStr); ok {
+			s.DetectedAt = parsed
+		}
+		if endedAtStr != nil {
+			if parsed, ok := parseDBTime(*endedAtStr); ok {
+				s.EndedAt = &parsed
+			}
+		} else {
+			s.Active = true
+			s.DurationSec = int64(now.Sub(s.DetectedAt).Seconds())
+		}
+
+		spikes = append(spikes, s)
+	}
+
+	return spikes, rows.Err()
+}
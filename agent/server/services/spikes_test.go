@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/RZhurakovskiy/agent/server/models"
+)
+
+/* Подменяет настройки и состояние детектора всплесков на время теста */
+func withSpikeConfig(t *testing.T, cfg SpikeConfig) {
+	t.Helper()
+	spikeMutex.Lock()
+	prevConfig, prevTrackers := spikeConfig, spikeTrackers
+	spikeConfig, spikeTrackers = cfg, make(map[string]*spikeTracker)
+	spikeMutex.Unlock()
+	t.Cleanup(func() {
+		spikeMutex.Lock()
+		spikeConfig, spikeTrackers = prevConfig, prevTrackers
+		spikeMutex.Unlock()
+	})
+}
+
+func TestDetectSpikesClosesOpenSpikesWhenDisabled(t *testing.T) {
+	db := setupTestDB(t)
+	withSpikeConfig(t, SpikeConfig{Enabled: true, CPUThreshold: 50})
+
+	procs := []models.ProcessInfo{{PID: 4242, Name: "busy", CPUPercent: 95, CreateTime: 1}}
+	DetectSpikes(procs)
+	if procs[0].Spike == nil {
+		t.Fatal("всплеск не открыт")
+	}
+
+	if err := SetSpikeConfig(SpikeConfig{Enabled: false, CPUThreshold: 50}); err != nil {
+		t.Fatalf("SetSpikeConfig: %v", err)
+	}
+	procs = []models.ProcessInfo{{PID: 4242, Name: "busy", CPUPercent: 95, CreateTime: 1}}
+	DetectSpikes(procs)
+	if procs[0].Spike != nil {
+		t.Error("при отключенном детекторе процессу проставлен всплеск")
+	}
+
+	var open int
+	if err := db.QueryRow("SELECT COUNT(*) FROM spikes WHERE ended_at IS NULL").Scan(&open); err != nil {
+		t.Fatalf("не удалось прочитать всплески: %v", err)
+	}
+	if open != 0 {
+		t.Errorf("открытых всплесков: %d, ожидалось 0", open)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/RZhurakovskiy/agent/server/models"
 )
 
 var (
@@ -83,32 +85,35 @@ func isValidScript(cwd string, args []string) error {
 	return nil
 }
 
-/* Запускает новый процесс с указанной командой, аргументами и рабочей директорией */
-/* Проверяет разрешенность команды и валидность путей, возвращает результат запуска или ошибку */
-func StartProcess(command, argsStr, cwd string) (*ProcessLaunchResult, error) {
+/* Проверяет разрешенность команды, рабочую директорию и существование скрипта перед запуском */
+func validateProcessLaunch(command string, args []string, cwd string) error {
 	if command == "" {
-		return nil, fmt.Errorf("поле 'command' обязательно")
+		return fmt.Errorf("поле 'command' обязательно")
 	}
 
 	if !AllowedCommands[command] {
-		return nil, fmt.Errorf("команда '%s' не разрешена", command)
-	}
-
-	var args []string
-	if argsStr != "" {
-		args = strings.Fields(argsStr)
+		return fmt.Errorf("команда '%s' не разрешена", command)
 	}
 
 	if cwd != "" {
 		if !filepath.IsAbs(cwd) {
-			return nil, fmt.Errorf("cwd должен быть абсолютным путём")
+			return fmt.Errorf("cwd должен быть абсолютным путём")
 		}
 		if _, err := os.Stat(cwd); os.IsNotExist(err) {
-			return nil, fmt.Errorf("директория cwd не существует: %s", cwd)
+			return fmt.Errorf("директория cwd не существует: %s", cwd)
 		}
 	}
 
-	if err := isValidScript(cwd, args); err != nil {
+	return isValidScript(cwd, args)
+}
+
+/* Запускает новый процесс с указанной командой, аргументами и рабочей директорией */
+/* Проверяет разрешенность команды и валидность путей, применяет параметры запуска, возвращает результат запуска или ошибку */
+func StartProcess(command string, args []string, cwd string, options models.ProcessLaunchOptions) (*ProcessLaunchResult, error) {
+	if err := validateProcessLaunch(command, args, cwd); err != nil {
+		return nil, err
+	}
+	if err := validateLaunchOptions(options); err != nil {
 		return nil, err
 	}
 
@@ -116,16 +121,36 @@ func StartProcess(command, argsStr, cwd string) (*ProcessLaunchResult, error) {
 	if cwd != "" {
 		cmd.Dir = cwd
 	}
+	if options.MaxRuntimeSec > 0 {
+		// Собственная группа, чтобы по истечении времени работы завершались и потомки процесса
+		detachManagedCommand(cmd)
+	}
+	helper, err := applyLaunchOptions(cmd, options)
+	if err != nil {
+		return nil, err
+	}
+	argsStr := strings.Join(args, " ")
 
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	output, err := newProcessLog()
+	if err != nil {
+		helper.abort()
+		return nil, err
+	}
+	cmd.Stdout = output.writer
+	cmd.Stderr = output.writer
 
 	if err := cmd.Start(); err != nil {
+		helper.abort()
+		output.discard()
 		return nil, fmt.Errorf("не удалось запустить: %w", err)
 	}
+	if err := helper.confirm(cmd); err != nil {
+		output.discard()
+		return nil, err
+	}
 
 	pid := int32(cmd.Process.Pid)
+	output.open(pid, strings.TrimSpace("процесс запущен: "+command+" "+argsStr))
 
 	procMutex.Lock()
 	runningProcesses[pid] = cmd
@@ -133,7 +158,12 @@ func StartProcess(command, argsStr, cwd string) (*ProcessLaunchResult, error) {
 
 	port := extractPortFromArgs(args)
 
-	go monitorProcess(cmd, pid, command, argsStr, cwd, port, &stdout, &stderr)
+	stopRuntimeLimit := startRuntimeLimit(pid, time.Now(), options.MaxRuntimeSec, output)
+
+	go func() {
+		monitorProcess(cmd, pid, command, argsStr, cwd, port, output)
+		stopRuntimeLimit()
+	}()
 
 	var msg string
 	if port != "" {
@@ -149,7 +179,8 @@ func StartProcess(command, argsStr, cwd string) (*ProcessLaunchResult, error) {
 }
 
 /* Мониторит запущенный процесс, проверяет доступность порта если указан и логирует результат завершения */
-func monitorProcess(cmd *exec.Cmd, pid int32, command, argsStr, cwd, port string, stdout, stderr *strings.Builder) {
+/* Вывод процесса сохраняется в лог, который остается после завершения */
+func monitorProcess(cmd *exec.Cmd, pid int32, command, argsStr, cwd, port string, output *processLog) {
 	defer func() {
 		procMutex.Lock()
 		delete(runningProcesses, pid)
@@ -164,6 +195,7 @@ func monitorProcess(cmd *exec.Cmd, pid int32, command, argsStr, cwd, port string
 
 	if !stillRunning {
 		cmd.Wait()
+		output.close("процесс завершился: " + cmd.ProcessState.String())
 		return
 	}
 
@@ -183,12 +215,7 @@ func monitorProcess(cmd *exec.Cmd, pid int32, command, argsStr, cwd, port string
 	if err != nil {
 		exitStatus = fmt.Sprintf("с ошибкой: %v", err)
 	}
+	output.close("процесс завершился: " + cmd.ProcessState.String())
 
-	log.Printf("Процесс завершён PID=%d: %s %s (cwd: %s) → %s", pid, command, argsStr, cwd, exitStatus)
-	if stderr.Len() > 0 {
-		log.Printf("stderr PID=%d: %s", pid, strings.TrimSpace(stderr.String()))
-	}
-	if stdout.Len() > 0 {
-		log.Printf("stdout PID=%d: %s", pid, strings.TrimSpace(stdout.String()))
-	}
+	log.Printf("Процесс завершён PID=%d: %s %s (cwd: %s) → %s, вывод: %s", pid, command, argsStr, cwd, exitStatus, output.path)
 }
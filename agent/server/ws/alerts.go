@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+	"github.com/gorilla/websocket"
+)
+
+/*
+Устанавливает ws соединение и передаёт события алертов по мере их появления
+
+	Параметр lastEventId возобновляет поток: сначала отправляются пропущенные события с большим ID, затем новые
+*/
+func StreamAlerts(w http.ResponseWriter, r *http.Request) {
+	lastID, _ := strconv.ParseInt(r.URL.Query().Get("lastEventId"), 10, 64)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Ошибка обновления соединения до WebSocket (алерты): %v", err)
+		return
+	}
+	defer conn.Close()
+
+	missed, events, unsubscribe, err := services.ResumeAlertEvents(lastID)
+	if err != nil {
+		log.Printf("Ошибка получения пропущенных событий алертов: %v", err)
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"Ошибка получения пропущенных событий"}`))
+		return
+	}
+	defer unsubscribe()
+
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range missed {
+		if err := writeAlertEvent(conn, event); err != nil {
+			return
+		}
+		lastID = event.ID
+	}
+
+	pingTicker := time.NewTicker(30 * time.Second)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if err := writeAlertEvent(conn, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* Отправляет одно событие алерта через ws соединение */
+func writeAlertEvent(conn *websocket.Conn, event services.AlertEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Ошибка сериализации события алерта: %v", err)
+		return nil
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return conn.WriteMessage(websocket.TextMessage, b)
+}
@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+	"github.com/gorilla/websocket"
+)
+
+/*
+Устанавливает ws соединение и передаёт вывод процесса, запущенного агентом, по мере появления
+
+	Первое сообщение содержит последние lines строк (по умолчанию 100), следующие - новые строки.
+	После завершения процесса отправляется сообщение с running равным false и соединение закрывается
+*/
+func StreamProcessLogs(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.ParseInt(r.URL.Query().Get("pid"), 10, 32)
+	if err != nil || pid <= 0 {
+		http.Error(w, "Некорректный PID", http.StatusBadRequest)
+		return
+	}
+	lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	tail, updates, err := services.FollowProcessLog(int32(pid), lines, stop)
+	if err != nil {
+		http.Error(w, "Ошибка чтения лога процесса: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tail == nil {
+		http.Error(w, "Лог процесса не найден", http.StatusNotFound)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Ошибка обновления соединения до WebSocket (логи процесса): %v", err)
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := writeProcessLog(conn, *tail); err != nil {
+		return
+	}
+
+	pingTicker := time.NewTicker(30 * time.Second)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case batch, ok := <-updates:
+			if !ok {
+				if tail.Running {
+					writeProcessLog(conn, services.ProcessLogTail{PID: tail.PID, Path: tail.Path, Lines: []string{}})
+				}
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "процесс завершился"), time.Now().Add(10*time.Second))
+				return
+			}
+			if err := writeProcessLog(conn, services.ProcessLogTail{PID: tail.PID, Running: true, Path: tail.Path, Lines: batch}); err != nil {
+				return
+			}
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* Отправляет строки вывода процесса через ws соединение */
+func writeProcessLog(conn *websocket.Conn, tail services.ProcessLogTail) error {
+	b, err := json.Marshal(tail)
+	if err != nil {
+		log.Printf("Ошибка сериализации лога процесса: %v", err)
+		return nil
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return conn.WriteMessage(websocket.TextMessage, b)
+}
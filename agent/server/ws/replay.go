@@ -0,0 +1,320 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RZhurakovskiy/agent/server/services"
+	"github.com/gorilla/websocket"
+)
+
+/* Сообщение воспроизведения, в data те же данные, что отправляют /ws/cpu, /ws/memory и /ws/processes */
+type replayMessage struct {
+	Type      string      `json:"type"` // cpu, memory, processes, status или error
+	Timestamp string      `json:"timestamp,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+/* Состояние воспроизведения, отправляется при старте, после каждой команды и по окончании кадров */
+type replayStatus struct {
+	State    string  `json:"state"` // playing, paused или finished
+	Speed    float64 `json:"speed"` // 0 - максимальная скорость
+	Frame    int     `json:"frame"`
+	Frames   int     `json:"frames"`
+	Position string  `json:"position,omitempty"`
+	From     string  `json:"from,omitempty"`
+	To       string  `json:"to,omitempty"`
+}
+
+/* Команда управления воспроизведением от клиента */
+type replayControl struct {
+	Action    string          `json:"action"` // pause, play, seek или speed
+	Speed     json.RawMessage `json:"speed"`
+	Position  string          `json:"position"`
+	OffsetSec *float64        `json:"offsetSec"`
+}
+
+/* Дольше этого пауза между кадрами не длится, чтобы пропуски в записи не останавливали воспроизведение */
+const replayMaxGap = 10 * time.Second
+
+/*
+Устанавливает ws соединение и воспроизводит сессию записи sessionId в порядке времени
+
+	Параметр speed задает скорость: 1 (по умолчанию), 10 или max. Клиент управляет воспроизведением командами
+	{"action":"pause"}, {"action":"play"}, {"action":"speed","speed":10} и {"action":"seek","position":"..."}
+	или {"action":"seek","offsetSec":120} относительно начала сессии
+*/
+func StreamReplay(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(r.URL.Query().Get("sessionId"), 10, 64)
+	if err != nil || sessionID <= 0 {
+		http.Error(w, "Некорректный sessionId", http.StatusBadRequest)
+		return
+	}
+
+	speed := 1.0
+	if value := r.URL.Query().Get("speed"); value != "" {
+		parsed, ok := parseReplaySpeed(value)
+		if !ok {
+			http.Error(w, "Некорректная скорость: "+value, http.StatusBadRequest)
+			return
+		}
+		speed = parsed
+	}
+
+	timeline, err := services.LoadReplayTimeline(sessionID)
+	if err != nil {
+		http.Error(w, "Ошибка загрузки сессии записи: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if timeline == nil {
+		http.Error(w, "Сессия записи не найдена", http.StatusNotFound)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Ошибка обновления соединения до WebSocket (воспроизведение): %v", err)
+		return
+	}
+	defer conn.Close()
+
+	controls := make(chan replayControl)
+	closed := make(chan struct{})
+	// done закрывается при выходе из основного цикла, чтобы читатель не заблокировался на отправке в controls
+	done := make(chan struct{})
+	defer close(done)
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+	go func() {
+		defer close(closed)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+			var control replayControl
+			if err := json.Unmarshal(data, &control); err != nil {
+				control = replayControl{}
+			}
+			select {
+			case controls <- control:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	frames := len(timeline.Frames)
+	index := 0
+	paused := false
+	status := func() replayStatus {
+		s := replayStatus{State: "playing", Speed: speed, Frame: index, Frames: frames}
+		if paused {
+			s.State = "paused"
+		}
+		if index >= frames {
+			s.State = "finished"
+		}
+		if frames > 0 {
+			s.From = timeline.Frames[0].At.Format("2006-01-02 15:04:05")
+			s.To = timeline.Frames[frames-1].At.Format("2006-01-02 15:04:05")
+			if index < frames {
+				s.Position = timeline.Frames[index].At.Format("2006-01-02 15:04:05")
+			}
+		}
+		return s
+	}
+
+	/* Таймер следующего кадра, nil когда воспроизведение на паузе или кадры закончились */
+	var next <-chan time.Time
+	var timer *time.Timer
+	schedule := func(wait time.Duration) {
+		if timer != nil {
+			timer.Stop()
+			next = nil
+		}
+		if paused || index >= frames {
+			return
+		}
+		timer = time.NewTimer(wait)
+		next = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	if err := writeReplayMessage(conn, replayMessage{Type: "status", Data: status()}); err != nil {
+		return
+	}
+	schedule(0)
+
+	pingTicker := time.NewTicker(30 * time.Second)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-next:
+			next = nil
+			if err := writeReplayFrame(conn, timeline, index); err != nil {
+				return
+			}
+			index++
+			if index >= frames {
+				if err := writeReplayMessage(conn, replayMessage{Type: "status", Data: status()}); err != nil {
+					return
+				}
+				continue
+			}
+			schedule(replayWait(timeline.Frames[index].At.Sub(timeline.Frames[index-1].At), speed))
+		case control := <-controls:
+			switch control.Action {
+			case "pause":
+				paused = true
+				schedule(0)
+			case "play":
+				paused = false
+				schedule(0)
+			case "speed":
+				parsed, ok := parseReplaySpeed(strings.Trim(string(control.Speed), `"`))
+				if !ok {
+					writeReplayError(conn, "Некорректная скорость: "+string(control.Speed))
+					continue
+				}
+				speed = parsed
+				schedule(0)
+			case "seek":
+				if frames == 0 {
+					continue
+				}
+				var position time.Time
+				switch {
+				case control.OffsetSec != nil:
+					position = timeline.Frames[0].At.Add(time.Duration(*control.OffsetSec * float64(time.Second)))
+				case control.Position != "":
+					parsed, ok := parseReplayPosition(control.Position)
+					if !ok {
+						writeReplayError(conn, "Некорректная позиция: "+control.Position)
+						continue
+					}
+					position = parsed
+				default:
+					writeReplayError(conn, "Для перемотки укажите position или offsetSec")
+					continue
+				}
+				index = timeline.Seek(position)
+				schedule(0)
+			case "":
+				writeReplayError(conn, "Некорректная команда воспроизведения")
+				continue
+			default:
+				writeReplayError(conn, "Неизвестная команда: "+control.Action)
+				continue
+			}
+			if err := writeReplayMessage(conn, replayMessage{Type: "status", Data: status()}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* Отправляет кадр сессии в виде сообщений cpu, memory и processes */
+func writeReplayFrame(conn *websocket.Conn, timeline *services.ReplayTimeline, index int) error {
+	frame, err := timeline.Frame(index)
+	if err != nil {
+		log.Printf("Ошибка чтения кадра воспроизведения: %v", err)
+		return writeReplayError(conn, "Ошибка чтения кадра: "+err.Error())
+	}
+
+	timestamp := frame.At.Format("2006-01-02 15:04:05")
+	if frame.CPU != nil {
+		data := cpuPayload{CPU: *frame.CPU, Timestamp: timestamp}
+		if err := writeReplayMessage(conn, replayMessage{Type: "cpu", Timestamp: timestamp, Data: data}); err != nil {
+			return err
+		}
+	}
+	if frame.Memory != nil {
+		data := memoryPayload{
+			MemoryUsage: frame.Memory.Percent,
+			UsedMB:      frame.Memory.UsedMB,
+			TotalMemory: frame.Memory.TotalMB,
+			Timestamp:   timestamp,
+		}
+		if err := writeReplayMessage(conn, replayMessage{Type: "memory", Timestamp: timestamp, Data: data}); err != nil {
+			return err
+		}
+	}
+	if frame.Processes != nil {
+		if err := writeReplayMessage(conn, replayMessage{Type: "processes", Timestamp: timestamp, Data: frame.Processes}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Отправляет одно сообщение воспроизведения через ws соединение */
+func writeReplayMessage(conn *websocket.Conn, message replayMessage) error {
+	b, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Ошибка сериализации сообщения воспроизведения: %v", err)
+		return nil
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return conn.WriteMessage(websocket.TextMessage, b)
+}
+
+/* Отправляет клиенту сообщение об ошибке, воспроизведение продолжается */
+func writeReplayError(conn *websocket.Conn, message string) error {
+	return writeReplayMessage(conn, replayMessage{Type: "error", Data: map[string]string{"message": message}})
+}
+
+/* Разбирает скорость воспроизведения: положительное число или max, которому соответствует 0 */
+func parseReplaySpeed(value string) (float64, bool) {
+	if value == "max" {
+		return 0, true
+	}
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, false
+	}
+	return speed, true
+}
+
+/* Разбирает позицию перемотки в формате RFC3339 или 2006-01-02 15:04:05 по местному времени */
+func parseReplayPosition(value string) (time.Time, bool) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return parsed, true
+	}
+	return time.Time{}, false
+}
+
+/* Считает паузу до следующего кадра с учетом скорости, при максимальной скорости кадры идут без пауз */
+func replayWait(gap time.Duration, speed float64) time.Duration {
+	if speed == 0 || gap <= 0 {
+		return 0
+	}
+	wait := time.Duration(float64(gap) / speed)
+	if wait > replayMaxGap {
+		return replayMaxGap
+	}
+	return wait
+}
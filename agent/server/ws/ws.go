@@ -108,7 +108,7 @@ func updateCPUMetrics() {
 	}
 }
 
-/* Обновляет кэш метрик памяти каждые 3 секунды и сохраняет в историю если активна запись */
+/* Обновляет кэш метрик памяти каждые 3 секунды, сохраняет в историю если активна запись, проверяет алерты, аномалии и прогноз заполнения дисков */
 func updateMemoryMetrics() {
 	if usage, total, used, err := getmetrics.UsageMemory(); err == nil {
 		cacheMutex.Lock()
@@ -126,7 +126,7 @@ func updateMemoryMetrics() {
 			cpuVal := cpuCache.CPU
 			cacheMutex.RUnlock()
 
-			shouldSave := cpuVal > session.CPUThreshold && usage > session.RAMThreshold
+			shouldSave := session.Criteria.MatchesHost(cpuVal, usage)
 			if shouldSave {
 				if err := saveMetricsHistory(cpuVal, usage, used, total); err != nil {
 					log.Printf("Ошибка сохранения истории метрик: %v", err)
@@ -142,6 +142,8 @@ func updateMemoryMetrics() {
 			cpuVal := cpuCache.CPU
 			cacheMutex.RUnlock()
 			services.CheckAlerts(cpuVal, usage)
+			services.CheckAnomalies(cpuVal, usage)
+			services.CheckDiskForecast()
 		}
 	} else {
 		log.Printf("Ошибка обновления кэша памяти: %v", err)
@@ -158,7 +160,7 @@ func saveMetricsHistory(cpuPercent, memoryPercent float64, memoryUsedMB, memoryT
 	return nil
 }
 
-/* Обновляет кэш списка процессов каждые 5 секунд */
+/* Обновляет кэш списка процессов каждые 5 секунд и передаёт снимок детектору всплесков, правилам алертов и детектору утечек памяти */
 func updateProcessMetrics() {
 
 	allConnections, err := net.Connections("all")
@@ -168,6 +170,12 @@ func updateProcessMetrics() {
 	}
 
 	if procs, err := getmetrics.UsageProcess(allConnections); err == nil {
+		services.DetectSpikes(procs)
+		services.BufferRecentProcesses(procs)
+		services.CheckProcessAlerts(procs)
+		services.TrackProcessMemory(procs)
+		services.ApplyProcessTuningRules(procs)
+
 		cacheMutex.Lock()
 		procsCache = procs
 		cacheMutex.Unlock()
@@ -338,8 +346,13 @@ func writeMemory(conn *websocket.Conn) error {
 	return conn.WriteMessage(websocket.TextMessage, b)
 }
 
-/* Устанавливает ws соединение и начинает потоковую передачу списка процессов каждые 5 секунд */
+/*
+Устанавливает ws соединение и начинает потоковую передачу списка процессов каждые 5 секунд
+
+	С параметром view=tree вместо плоского списка передается дерево процессов с показателями поддеревьев
+*/
 func StreamProcesses(w http.ResponseWriter, r *http.Request) {
+	tree := r.URL.Query().Get("view") == "tree"
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Ошибка обновления соединения до WebSocket (процессы): %v", err)
@@ -360,7 +373,7 @@ func StreamProcesses(w http.ResponseWriter, r *http.Request) {
 
 	if enabled {
 
-		if err := writeProcesses(conn); err != nil {
+		if err := writeProcesses(conn, tree); err != nil {
 			log.Printf("Ошибка отправки первого сообщения процессов: %v", err)
 			return
 		}
@@ -378,15 +391,15 @@ func StreamProcesses(w http.ResponseWriter, r *http.Request) {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if err := writeProcesses(conn); err != nil {
+		if err := writeProcesses(conn, tree); err != nil {
 			return
 		}
 	}
 }
 
-/* Отправляет кэшированный список процессов через ws соединение */
+/* Отправляет кэшированный список процессов через ws соединение, при tree - в виде дерева */
 /* Проверяет состояние мониторинга перед отправкой данных */
-func writeProcesses(conn *websocket.Conn) error {
+func writeProcesses(conn *websocket.Conn, tree bool) error {
 
 	monitoringMutex.RLock()
 	enabled := monitoringEnabled
@@ -403,7 +416,12 @@ func writeProcesses(conn *websocket.Conn) error {
 	data := procsCache
 	cacheMutex.RUnlock()
 
-	b, err := json.Marshal(data)
+	var payload interface{} = data
+	if tree {
+		payload = services.BuildProcessTree(data)
+	}
+
+	b, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Ошибка сериализации списка процессов: %v", err)
 		return conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"Ошибка сериализации данных"}`))
@@ -435,6 +453,13 @@ func SetMonitoringEnabled(enabled bool) {
 	}
 }
 
+/* Возвращает последний список процессов из кэша, пустой список если кэш еще не заполнен */
+func GetCachedProcesses() []models.ProcessInfo {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return procsCache
+}
+
 /* Возвращает текущее состояние мониторинга */
 func GetMonitoringEnabled() bool {
 	monitoringMutex.RLock()
@@ -26,6 +26,19 @@ func ViewMenu() int {
 	return getUserInput()
 }
 
+func ProcessViewMenu() int {
+	menu := []MenuItem{
+		{1, "Списком"},
+		{2, "Деревом (как pstree)"},
+	}
+	fmt.Println("\nКак показать процессы:")
+	for _, item := range menu {
+		fmt.Printf(" [%d] %s\n", item.ID, item.Text)
+	}
+
+	return getUserInput()
+}
+
 func CompletionMenu() (int, int32, string) {
 	var action int
 	var pid int32
@@ -35,6 +48,9 @@ func CompletionMenu() (int, int32, string) {
 		{2, "Завершить процесс по названию"},
 		{3, "Найти процессы по PID"},
 		{4, "Найти процессы по названию"},
+		{5, "Отправить сигнал процессу"},
+		{6, "Мягко завершить процесс (SIGTERM, затем SIGKILL)"},
+		{7, "Завершить дерево, группу или сессию процесса"},
 		{0, "Вернуться в главное меню"},
 	}
 
@@ -58,6 +74,16 @@ func CompletionMenu() (int, int32, string) {
 	case 4:
 		fmt.Print("\nВведите название процесса для поиска: ")
 		name = readName()
+	case 5:
+		fmt.Print("\nВведите PID процесса: ")
+		pid = readPID()
+		if pid != 0 {
+			fmt.Print("Введите сигнал (TERM, INT, HUP, STOP, CONT, USR1, USR2, KILL): ")
+			name = readName()
+		}
+	case 6, 7:
+		fmt.Print("\nВведите PID процесса для завершения: ")
+		pid = readPID()
 	}
 
 	return action, pid, name